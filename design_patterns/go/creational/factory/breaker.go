@@ -0,0 +1,326 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is matched by every *CircuitOpenError
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitOpenError is returned without calling the vendor while its
+// circuit is open
+type CircuitOpenError struct {
+	Key        string
+	RetryAfter time.Duration // until the next trial call is allowed
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker open for %s, retry after %v", e.Key, e.RetryAfter)
+}
+
+// Is makes every CircuitOpenError match ErrCircuitOpen
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// Temporary reports true: the circuit closes again once the vendor recovers
+func (e *CircuitOpenError) Temporary() bool {
+	return true
+}
+
+// CircuitState is the state of a circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets every call through and counts failures
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects every call until the cool-down ends
+	CircuitOpen
+	// CircuitHalfOpen lets a few trial calls through to probe the vendor
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("circuit(%d)", int(s))
+}
+
+// BreakerPolicy configures a CircuitBreaker
+type BreakerPolicy struct {
+	FailureThreshold int           // consecutive failures that open the circuit, defaults to 5
+	SuccessThreshold int           // trial successes that close it again, defaults to 1
+	CoolDown         time.Duration // time open before trials are allowed, defaults to 30s
+	HalfOpenMax      int           // concurrent trial calls, defaults to SuccessThreshold
+
+	// IsFailure decides whether an error counts against the vendor.
+	// Defaults to vendorFault: retryable errors other than cancellation,
+	// local rate limiting and open circuits.
+	IsFailure func(error) bool
+
+	// OnStateChange is called after every transition, outside the
+	// breaker's lock
+	OnStateChange func(key string, from, to CircuitState)
+
+	Clock Clock // defaults to SystemClock
+}
+
+// vendorFault reports whether err says something about the vendor's health.
+// Invalid messages and other permanent errors mean the vendor answered.
+func vendorFault(err error) bool {
+	return IsRetryable(err) &&
+		!isContextError(err) &&
+		!errors.Is(err, ErrRateLimited) &&
+		!errors.Is(err, ErrCircuitOpen)
+}
+
+func (p BreakerPolicy) withDefaults() BreakerPolicy {
+	if p.FailureThreshold <= 0 {
+		p.FailureThreshold = 5
+	}
+	if p.SuccessThreshold <= 0 {
+		p.SuccessThreshold = 1
+	}
+	if p.CoolDown <= 0 {
+		p.CoolDown = 30 * time.Second
+	}
+	if p.HalfOpenMax <= 0 {
+		p.HalfOpenMax = p.SuccessThreshold
+	}
+	if p.IsFailure == nil {
+		p.IsFailure = vendorFault
+	}
+	if p.Clock == nil {
+		p.Clock = SystemClock
+	}
+	return p
+}
+
+// CircuitBreaker is the closed/open/half-open state machine for one key.
+// It is safe for concurrent use.
+type CircuitBreaker struct {
+	key    string
+	policy BreakerPolicy
+
+	mu         sync.Mutex
+	state      CircuitState
+	generation uint64 // bumped on every transition so stale results are ignored
+	failures   int    // consecutive failures while closed
+	successes  int    // trial successes while half-open
+	trials     int    // trial calls in flight while half-open
+	openedAt   time.Time
+}
+
+// NewCircuitBreaker creates a closed breaker for key, filling in defaults
+func NewCircuitBreaker(key string, policy BreakerPolicy) *CircuitBreaker {
+	return &CircuitBreaker{key: key, policy: policy.withDefaults()}
+}
+
+// Key returns the name the breaker reports in errors and callbacks
+func (b *CircuitBreaker) Key() string {
+	return b.key
+}
+
+// State returns the current state. An open breaker whose cool-down has
+// passed reports half-open.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && !b.policy.Clock.Now().Before(b.openedAt.Add(b.policy.CoolDown)) {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// Do runs fn if the circuit allows it and records the outcome. It returns
+// *CircuitOpenError without running fn while the circuit is open.
+func (b *CircuitBreaker) Do(fn func() error) error {
+	generation, err := b.acquire()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	b.record(generation, err)
+	return err
+}
+
+// transition moves to state and returns the change to report; the caller
+// holds b.mu
+func (b *CircuitBreaker) transition(to CircuitState) func() {
+	from := b.state
+	b.state = to
+	b.generation++
+	b.failures, b.successes, b.trials = 0, 0, 0
+	if to == CircuitOpen {
+		b.openedAt = b.policy.Clock.Now()
+	}
+	if b.policy.OnStateChange == nil {
+		return func() {}
+	}
+	key, hook := b.key, b.policy.OnStateChange
+	return func() { hook(key, from, to) }
+}
+
+func (b *CircuitBreaker) acquire() (uint64, error) {
+	b.mu.Lock()
+	notify := func() {}
+	defer func() {
+		b.mu.Unlock()
+		notify()
+	}()
+
+	if b.state == CircuitOpen {
+		reopen := b.openedAt.Add(b.policy.CoolDown)
+		now := b.policy.Clock.Now()
+		if now.Before(reopen) {
+			return 0, &CircuitOpenError{Key: b.key, RetryAfter: reopen.Sub(now)}
+		}
+		notify = b.transition(CircuitHalfOpen)
+	}
+	if b.state == CircuitHalfOpen {
+		if b.trials >= b.policy.HalfOpenMax {
+			return 0, &CircuitOpenError{Key: b.key}
+		}
+		b.trials++
+	}
+	return b.generation, nil
+}
+
+func (b *CircuitBreaker) record(generation uint64, err error) {
+	b.mu.Lock()
+	notify := func() {}
+	defer func() {
+		b.mu.Unlock()
+		notify()
+	}()
+
+	if generation != b.generation {
+		return
+	}
+	failed := err != nil && b.policy.IsFailure(err)
+	neutral := err != nil && !failed && isContextError(err)
+
+	switch b.state {
+	case CircuitClosed:
+		if !failed {
+			if !neutral {
+				b.failures = 0
+			}
+			return
+		}
+		b.failures++
+		if b.failures >= b.policy.FailureThreshold {
+			notify = b.transition(CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		b.trials--
+		switch {
+		case failed:
+			notify = b.transition(CircuitOpen)
+		case neutral:
+		default:
+			b.successes++
+			if b.successes >= b.policy.SuccessThreshold {
+				notify = b.transition(CircuitClosed)
+			}
+		}
+	}
+}
+
+// CircuitBreakerNotifier stops calling a failing vendor until it recovers
+type CircuitBreakerNotifier struct {
+	next    Notifier
+	breaker *CircuitBreaker
+}
+
+// NewCircuitBreakerNotifier wraps next with its own breaker keyed by its vendor
+func NewCircuitBreakerNotifier(next Notifier, policy BreakerPolicy) *CircuitBreakerNotifier {
+	return &CircuitBreakerNotifier{next: next, breaker: NewCircuitBreaker(vendorName(next), policy)}
+}
+
+// Breaker returns the breaker guarding the wrapped notifier
+func (n *CircuitBreakerNotifier) Breaker() *CircuitBreaker {
+	return n.breaker
+}
+
+// VendorName passes through the wrapped notifier's vendor
+func (n *CircuitBreakerNotifier) VendorName() string {
+	return vendorName(n.next)
+}
+
+// Send sends msg unless the circuit is open
+func (n *CircuitBreakerNotifier) Send(msg string) error {
+	return n.SendContext(context.Background(), msg)
+}
+
+// SendContext is Send bounded by ctx
+func (n *CircuitBreakerNotifier) SendContext(ctx context.Context, msg string) error {
+	return n.SendMessage(ctx, Message{Body: msg})
+}
+
+// SendMessage sends a structured message unless the circuit is open
+func (n *CircuitBreakerNotifier) SendMessage(ctx context.Context, msg Message) error {
+	return n.breaker.Do(func() error {
+		return SendMessage(ctx, n.next, msg)
+	})
+}
+
+// CircuitBreakers hands out one shared breaker per channel and vendor, so
+// every notifier for a failing vendor sees the same open circuit while
+// other vendors are unaffected
+type CircuitBreakers struct {
+	policy BreakerPolicy
+
+	mu       sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+// NewCircuitBreakers creates a set of breakers sharing one policy
+func NewCircuitBreakers(policy BreakerPolicy) *CircuitBreakers {
+	return &CircuitBreakers{policy: policy, breakers: make(map[string]*CircuitBreaker)}
+}
+
+// Breaker returns the breaker for a channel/vendor pair, creating it on first use
+func (c *CircuitBreakers) Breaker(channel, vendor string) *CircuitBreaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := rateLimitKey(channel, vendor)
+	breaker, ok := c.breakers[key]
+	if !ok {
+		breaker = NewCircuitBreaker(key, c.policy)
+		c.breakers[key] = breaker
+	}
+	return breaker
+}
+
+// Wrap guards n with the breaker for channel and n's vendor
+func (c *CircuitBreakers) Wrap(channel string, n Notifier) *CircuitBreakerNotifier {
+	return &CircuitBreakerNotifier{next: n, breaker: c.Breaker(channel, vendorName(n))}
+}
+
+// States returns the state of every breaker, keyed "channel/vendor"
+func (c *CircuitBreakers) States() map[string]CircuitState {
+	c.mu.Lock()
+	breakers := make([]*CircuitBreaker, 0, len(c.breakers))
+	for _, breaker := range c.breakers {
+		breakers = append(breakers, breaker)
+	}
+	c.mu.Unlock()
+
+	states := make(map[string]CircuitState, len(breakers))
+	for _, breaker := range breakers {
+		states[breaker.key] = breaker.State()
+	}
+	return states
+}
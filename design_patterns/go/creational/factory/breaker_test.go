@@ -0,0 +1,226 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stateLog records breaker transitions
+type stateLog struct {
+	mu      sync.Mutex
+	changes []string
+}
+
+func (l *stateLog) record(key string, from, to CircuitState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changes = append(l.changes, fmt.Sprintf("%s: %s -> %s", key, from, to))
+}
+
+func (l *stateLog) Changes() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.changes...)
+}
+
+// ============================================================================
+// CIRCUIT BREAKER TESTS
+// ============================================================================
+
+func TestCircuitBreakerNotifier_OpensAfterThreshold(t *testing.T) {
+	clock := newFakeClock()
+	inner := &scriptedNotifier{vendor: "twilio", errs: []error{errVendorDown, errVendorDown, errVendorDown}}
+	notifier := NewCircuitBreakerNotifier(inner, BreakerPolicy{FailureThreshold: 3, CoolDown: time.Minute, Clock: clock})
+
+	for i := 0; i < 3; i++ {
+		if err := notifier.Send("hello"); !errors.Is(err, errVendorDown) {
+			t.Fatalf("attempt %d: expected vendor error, got %v", i+1, err)
+		}
+	}
+	clock.Advance(10 * time.Second)
+	err := notifier.Send("hello")
+
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if openErr.Key != "twilio" || openErr.RetryAfter != 50*time.Second {
+		t.Errorf("expected twilio retry after 50s, got %+v", openErr)
+	}
+	if inner.Calls() != 3 {
+		t.Errorf("expected the open circuit to skip the vendor, got %d calls", inner.Calls())
+	}
+	if !IsRetryable(err) {
+		t.Error("expected an open circuit to be retryable")
+	}
+}
+
+func TestCircuitBreakerNotifier_HalfOpenRecovers(t *testing.T) {
+	clock := newFakeClock()
+	log := &stateLog{}
+	inner := &scriptedNotifier{vendor: "twilio", errs: []error{errVendorDown}}
+	notifier := NewCircuitBreakerNotifier(inner, BreakerPolicy{
+		FailureThreshold: 1,
+		SuccessThreshold: 2,
+		CoolDown:         time.Minute,
+		OnStateChange:    log.record,
+		Clock:            clock,
+	})
+
+	notifier.Send("fails")
+	clock.Advance(time.Minute)
+	if notifier.Breaker().State() != CircuitHalfOpen {
+		t.Errorf("expected half-open after cool-down, got %s", notifier.Breaker().State())
+	}
+	notifier.Send("trial 1")
+	notifier.Send("trial 2")
+
+	want := []string{"twilio: closed -> open", "twilio: open -> half-open", "twilio: half-open -> closed"}
+	if got := log.Changes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if notifier.Breaker().State() != CircuitClosed {
+		t.Errorf("expected closed, got %s", notifier.Breaker().State())
+	}
+}
+
+func TestCircuitBreaker_SuccessResetsFailureCount(t *testing.T) {
+	breaker := NewCircuitBreaker("k", BreakerPolicy{FailureThreshold: 2})
+	fail := func() error { return errVendorDown }
+	ok := func() error { return nil }
+
+	breaker.Do(fail)
+	breaker.Do(ok)
+	breaker.Do(fail)
+	if breaker.State() != CircuitClosed {
+		t.Errorf("expected non-consecutive failures to keep the circuit closed, got %s", breaker.State())
+	}
+}
+
+func TestCircuitBreaker_IgnoresNonVendorErrors(t *testing.T) {
+	breaker := NewCircuitBreaker("k", BreakerPolicy{FailureThreshold: 1})
+
+	for _, err := range []error{
+		Permanent(errors.New("bad number")),
+		&ValidationError{Channel: "sms", Field: "To[0]", Reason: "is not an E.164 phone number"},
+		context.Canceled,
+		&RateLimitError{Key: "k", RetryAfter: time.Second},
+	} {
+		breaker.Do(func() error { return err })
+	}
+	if breaker.State() != CircuitClosed {
+		t.Errorf("expected errors about the message or caller not to open the circuit, got %s", breaker.State())
+	}
+}
+
+func TestCircuitBreakers_KeyedPerVendor(t *testing.T) {
+	clock := newFakeClock()
+	breakers := NewCircuitBreakers(BreakerPolicy{FailureThreshold: 1, Clock: clock})
+	down := breakers.Wrap("sms", &scriptedNotifier{vendor: "twilio", errs: []error{errVendorDown}})
+	sameVendor := breakers.Wrap("sms", &scriptedNotifier{vendor: "twilio"})
+	otherVendor := breakers.Wrap("sms", &scriptedNotifier{vendor: "nexmo"})
+
+	down.Send("fails")
+	if err := sameVendor.Send("hello"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected notifiers for the same vendor to share the circuit, got %v", err)
+	}
+	if err := otherVendor.Send("hello"); err != nil {
+		t.Errorf("expected other vendors to be unaffected, got %v", err)
+	}
+
+	want := map[string]CircuitState{"sms/twilio": CircuitOpen, "sms/nexmo": CircuitClosed}
+	if got := breakers.States(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestCircuitBreaker_FailoverSkipsOpenVendor(t *testing.T) {
+	breakers := NewCircuitBreakers(BreakerPolicy{FailureThreshold: 1, Clock: newFakeClock()})
+	primary := &scriptedNotifier{vendor: "twilio", errs: []error{errVendorDown}}
+	secondary := &scriptedNotifier{vendor: "nexmo"}
+	failover, _ := NewFailoverNotifier(breakers.Wrap("sms", primary), breakers.Wrap("sms", secondary))
+
+	for i := 0; i < 3; i++ {
+		if err := failover.Send("hello"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if primary.Calls() != 1 {
+		t.Errorf("expected the open circuit to shield the primary vendor, got %d calls", primary.Calls())
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestCircuitBreaker_FailedTrialReopens(t *testing.T) {
+	clock := newFakeClock()
+	breaker := NewCircuitBreaker("k", BreakerPolicy{FailureThreshold: 1, CoolDown: time.Minute, Clock: clock})
+
+	breaker.Do(func() error { return errVendorDown })
+	clock.Advance(time.Minute)
+	breaker.Do(func() error { return errVendorDown })
+
+	var openErr *CircuitOpenError
+	if err := breaker.Do(func() error { return nil }); !errors.As(err, &openErr) || openErr.RetryAfter != time.Minute {
+		t.Errorf("expected a fresh cool-down after the failed trial, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenLimitsTrials(t *testing.T) {
+	clock := newFakeClock()
+	breaker := NewCircuitBreaker("k", BreakerPolicy{FailureThreshold: 1, CoolDown: time.Minute, Clock: clock})
+	breaker.Do(func() error { return errVendorDown })
+	clock.Advance(time.Minute)
+
+	inTrial := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- breaker.Do(func() error {
+			close(inTrial)
+			<-release
+			return nil
+		})
+	}()
+	<-inTrial
+
+	if err := breaker.Do(func() error { return nil }); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected a second concurrent trial to be rejected, got %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if breaker.State() != CircuitClosed {
+		t.Errorf("expected closed after the trial succeeded, got %s", breaker.State())
+	}
+}
+
+func TestCircuitBreaker_StaleResultIgnored(t *testing.T) {
+	breaker := NewCircuitBreaker("k", BreakerPolicy{FailureThreshold: 1, Clock: newFakeClock()})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		breaker.Do(func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	breaker.Do(func() error { return errVendorDown })
+	close(release)
+	<-done
+
+	if breaker.State() != CircuitOpen {
+		t.Errorf("expected a success that started before the circuit opened to be ignored, got %s", breaker.State())
+	}
+}
@@ -0,0 +1,184 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BroadcastPolicy decides when a broadcast counts as successful
+type BroadcastPolicy int
+
+const (
+	// BroadcastAll fails if any channel fails
+	BroadcastAll BroadcastPolicy = iota
+	// BroadcastAny succeeds if at least one channel succeeds
+	BroadcastAny
+)
+
+func (p BroadcastPolicy) String() string {
+	if p == BroadcastAny {
+		return "at least one"
+	}
+	return "all"
+}
+
+// BroadcastTarget is one channel of a broadcast
+type BroadcastTarget struct {
+	Channel  string
+	Notifier Notifier
+	// To replaces the message recipients for this channel, so one broadcast
+	// can carry an email address, a phone number and a device token
+	To []string
+}
+
+// ChannelResult is the outcome of one channel in a broadcast
+type ChannelResult struct {
+	Channel  string
+	Vendor   string
+	Err      error
+	Duration time.Duration
+}
+
+// BroadcastError is returned when a broadcast does not satisfy its policy.
+// Results holds every channel, including those that succeeded.
+type BroadcastError struct {
+	Policy  BroadcastPolicy
+	Results []ChannelResult
+}
+
+func (e *BroadcastError) Error() string {
+	var failed []string
+	for _, r := range e.Results {
+		if r.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%s): %v", r.Channel, r.Vendor, r.Err))
+		}
+	}
+	return fmt.Sprintf("broadcast failed (%s must succeed): %d of %d channel(s) failed: %s",
+		e.Policy, len(failed), len(e.Results), strings.Join(failed, "; "))
+}
+
+// Unwrap exposes each channel error to errors.Is / errors.As
+func (e *BroadcastError) Unwrap() []error {
+	var errs []error
+	for _, r := range e.Results {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+		}
+	}
+	return errs
+}
+
+// Failed returns the channels that failed
+func (e *BroadcastError) Failed() []string {
+	var channels []string
+	for _, r := range e.Results {
+		if r.Err != nil {
+			channels = append(channels, r.Channel)
+		}
+	}
+	return channels
+}
+
+// BroadcastNotifier sends every message to several channels concurrently
+type BroadcastNotifier struct {
+	targets []BroadcastTarget
+	policy  BroadcastPolicy
+	clock   Clock
+}
+
+// NewBroadcastNotifier creates a BroadcastNotifier over the given targets
+func NewBroadcastNotifier(policy BroadcastPolicy, targets ...BroadcastTarget) (*BroadcastNotifier, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("broadcast: at least one target is required")
+	}
+	for i, target := range targets {
+		if target.Notifier == nil {
+			return nil, fmt.Errorf("broadcast: target %d (%s) has no notifier", i, target.Channel)
+		}
+	}
+	return &BroadcastNotifier{
+		targets: append([]BroadcastTarget(nil), targets...),
+		policy:  policy,
+		clock:   SystemClock,
+	}, nil
+}
+
+// NewBroadcastFromFactory builds one notifier per channel with NotifierFactory.
+// vendors maps channel type to vendor; targets are ordered by channel name.
+func NewBroadcastFromFactory(policy BroadcastPolicy, vendors map[string]string) (*BroadcastNotifier, error) {
+	channels := make([]string, 0, len(vendors))
+	for channel := range vendors {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+
+	targets := make([]BroadcastTarget, 0, len(channels))
+	for _, channel := range channels {
+		n, err := NotifierFactory(channel, vendors[channel])
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, BroadcastTarget{Channel: channel, Notifier: n})
+	}
+	return NewBroadcastNotifier(policy, targets...)
+}
+
+// Send broadcasts msg to every channel
+func (b *BroadcastNotifier) Send(msg string) error {
+	return b.SendContext(context.Background(), msg)
+}
+
+// SendContext broadcasts msg, passing ctx to every channel
+func (b *BroadcastNotifier) SendContext(ctx context.Context, msg string) error {
+	return b.SendMessage(ctx, Message{Body: msg})
+}
+
+// SendMessage broadcasts a structured message
+func (b *BroadcastNotifier) SendMessage(ctx context.Context, msg Message) error {
+	_, err := b.Broadcast(ctx, msg)
+	return err
+}
+
+// Broadcast sends msg to every channel concurrently and waits for all of
+// them. It returns the per-channel results in target order, and a
+// *BroadcastError if the policy was not met.
+func (b *BroadcastNotifier) Broadcast(ctx context.Context, msg Message) ([]ChannelResult, error) {
+	results := make([]ChannelResult, len(b.targets))
+	var wg sync.WaitGroup
+	for i, target := range b.targets {
+		wg.Add(1)
+		go func(i int, target BroadcastTarget) {
+			defer wg.Done()
+
+			channelMsg := msg
+			if target.To != nil {
+				channelMsg.To = target.To
+			}
+			start := b.clock.Now()
+			err := SendMessage(ctx, target.Notifier, channelMsg)
+			results[i] = ChannelResult{
+				Channel:  target.Channel,
+				Vendor:   vendorName(target.Notifier),
+				Err:      err,
+				Duration: b.clock.Now().Sub(start),
+			}
+		}(i, target)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	if failed == 0 || (b.policy == BroadcastAny && failed < len(results)) {
+		return results, nil
+	}
+	return results, &BroadcastError{Policy: b.policy, Results: results}
+}
@@ -0,0 +1,157 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// BROADCAST TESTS
+// ============================================================================
+
+func TestBroadcastNotifier_SendsToEveryChannel(t *testing.T) {
+	email := &messageRecorder{scriptedNotifier: scriptedNotifier{vendor: "sendgrid"}}
+	sms := &messageRecorder{scriptedNotifier: scriptedNotifier{vendor: "twilio"}}
+	notifier, err := NewBroadcastNotifier(BroadcastAll,
+		BroadcastTarget{Channel: "email", Notifier: email, To: []string{"alice@example.com"}},
+		BroadcastTarget{Channel: "sms", Notifier: sms, To: []string{"+14155552671"}},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	results, err := notifier.Broadcast(context.Background(), Message{Body: "deploy finished"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 || results[0].Channel != "email" || results[1].Vendor != "twilio" {
+		t.Errorf("expected results in target order, got %+v", results)
+	}
+	if got := email.Messages(); len(got) != 1 || !reflect.DeepEqual(got[0].To, []string{"alice@example.com"}) {
+		t.Errorf("expected email recipient override, got %+v", got)
+	}
+	if got := sms.Messages(); len(got) != 1 || !reflect.DeepEqual(got[0].To, []string{"+14155552671"}) {
+		t.Errorf("expected sms recipient override, got %+v", got)
+	}
+}
+
+func TestBroadcastNotifier_SendsConcurrently(t *testing.T) {
+	first, second := newGatedNotifier(), newGatedNotifier()
+	notifier, _ := NewBroadcastNotifier(BroadcastAll,
+		BroadcastTarget{Channel: "email", Notifier: first},
+		BroadcastTarget{Channel: "push", Notifier: second},
+	)
+
+	done := make(chan error, 1)
+	go func() { done <- notifier.Send("hello") }()
+
+	// Both sends must be in flight before either is released
+	<-first.started
+	<-second.started
+	close(first.gate)
+	close(second.gate)
+	if err := <-done; err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestBroadcastNotifier_AnyToleratesPartialFailure(t *testing.T) {
+	down := &scriptedNotifier{errs: []error{errVendorDown}}
+	up := &scriptedNotifier{}
+	notifier, _ := NewBroadcastNotifier(BroadcastAny,
+		BroadcastTarget{Channel: "sms", Notifier: down},
+		BroadcastTarget{Channel: "push", Notifier: up},
+	)
+
+	results, err := notifier.Broadcast(context.Background(), Message{Body: "hello"})
+	if err != nil {
+		t.Fatalf("expected at-least-one policy to succeed, got %v", err)
+	}
+	if !errors.Is(results[0].Err, errVendorDown) || results[1].Err != nil {
+		t.Errorf("expected per-channel results to record the failure, got %+v", results)
+	}
+}
+
+func TestNewBroadcastFromFactory(t *testing.T) {
+	notifier, err := NewBroadcastFromFactory(BroadcastAll, map[string]string{
+		"sms":   "twilio",
+		"email": "sendgrid",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	results, err := notifier.Broadcast(context.Background(), Message{Body: "hello"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].Channel != "email" || results[0].Vendor != "sendgrid" || results[1].Channel != "sms" {
+		t.Errorf("expected channels sorted by name, got %+v", results)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestBroadcastNotifier_AllNamesFailedChannels(t *testing.T) {
+	notifier, _ := NewBroadcastNotifier(BroadcastAll,
+		BroadcastTarget{Channel: "email", Notifier: &scriptedNotifier{vendor: "sendgrid"}},
+		BroadcastTarget{Channel: "sms", Notifier: &scriptedNotifier{vendor: "twilio", errs: []error{errVendorDown}}},
+		BroadcastTarget{Channel: "push", Notifier: &scriptedNotifier{vendor: "fcm", errs: []error{Permanent(errors.New("token expired"))}}},
+	)
+
+	err := notifier.Send("hello")
+	var broadcastErr *BroadcastError
+	if !errors.As(err, &broadcastErr) {
+		t.Fatalf("expected *BroadcastError, got %v", err)
+	}
+	if got := broadcastErr.Failed(); !reflect.DeepEqual(got, []string{"sms", "push"}) {
+		t.Errorf("expected failed channels [sms push], got %v", got)
+	}
+	if !errors.Is(err, errVendorDown) {
+		t.Error("expected channel errors to be unwrappable")
+	}
+	for _, want := range []string{"sms (twilio): vendor down", "push (fcm)", "2 of 3"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %q", want, err.Error())
+		}
+	}
+	if strings.Contains(err.Error(), "email") {
+		t.Errorf("expected successful channel to be left out, got %q", err.Error())
+	}
+}
+
+func TestBroadcastNotifier_AnyFailsWhenEveryChannelFails(t *testing.T) {
+	notifier, _ := NewBroadcastNotifier(BroadcastAny,
+		BroadcastTarget{Channel: "sms", Notifier: &scriptedNotifier{errs: []error{errVendorDown}}},
+		BroadcastTarget{Channel: "push", Notifier: &scriptedNotifier{errs: []error{errVendorDown}}},
+	)
+
+	var broadcastErr *BroadcastError
+	if err := notifier.Send("hello"); !errors.As(err, &broadcastErr) || len(broadcastErr.Failed()) != 2 {
+		t.Errorf("expected both channels to be reported, got %v", err)
+	}
+}
+
+func TestBroadcastNotifier_ValidationPerChannel(t *testing.T) {
+	notifier, _ := NewBroadcastFromFactory(BroadcastAll, map[string]string{"sms": "twilio"})
+
+	err := notifier.SendMessage(context.Background(), Message{To: []string{"alice@example.com"}, Body: "hi"})
+	if !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("expected ErrInvalidMessage from the sms channel, got %v", err)
+	}
+}
+
+func TestNewBroadcastNotifier_InvalidTargets(t *testing.T) {
+	if _, err := NewBroadcastNotifier(BroadcastAll); err == nil {
+		t.Error("expected error for no targets, got nil")
+	}
+	if _, err := NewBroadcastNotifier(BroadcastAll, BroadcastTarget{Channel: "email"}); err == nil {
+		t.Error("expected error for nil notifier, got nil")
+	}
+	if _, err := NewBroadcastFromFactory(BroadcastAll, map[string]string{"fax": "acme"}); !errors.Is(err, ErrUnknownNotifier) {
+		t.Errorf("expected ErrUnknownNotifier, got %v", err)
+	}
+}
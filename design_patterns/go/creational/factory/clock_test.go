@@ -0,0 +1,105 @@
+package factory
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually driven Clock for tests.
+// With autoAdvance set, After fires immediately and moves time forward.
+type fakeClock struct {
+	mu          sync.Mutex
+	now         time.Time
+	autoAdvance bool
+	sleeps      []time.Duration
+	waiters     []fakeWaiter
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sleeps = append(c.sleeps, d)
+	ch := make(chan time.Time, 1)
+	if c.autoAdvance || d <= 0 {
+		c.now = c.now.Add(max(d, 0))
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{deadline: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves time forward and fires any timers that are now due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if !w.deadline.After(c.now) {
+			w.ch <- c.now
+		} else {
+			pending = append(pending, w)
+		}
+	}
+	c.waiters = pending
+}
+
+// Sleeps returns every duration passed to After
+func (c *fakeClock) Sleeps() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.sleeps...)
+}
+
+// Waiters returns how many timers are still pending
+func (c *fakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+func TestSystemClock_After(t *testing.T) {
+	start := SystemClock.Now()
+	<-SystemClock.After(time.Millisecond)
+	if SystemClock.Now().Sub(start) < time.Millisecond {
+		t.Error("expected After to wait at least the given duration")
+	}
+}
+
+func TestFakeClock_Advance(t *testing.T) {
+	clock := newFakeClock()
+	ch := clock.After(time.Second)
+
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-ch:
+		t.Fatal("timer fired too early")
+	default:
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-ch:
+	default:
+		t.Fatal("expected timer to fire after advancing past deadline")
+	}
+}
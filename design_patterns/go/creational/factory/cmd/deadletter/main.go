@@ -0,0 +1,194 @@
+// Command deadletter lists, inspects, purges and replays the messages in a
+// dead-letter store.
+//
+// Usage:
+//
+//	deadletter [-store path] list
+//	deadletter [-store path] show ID
+//	deadletter [-store path] purge (ID... | -all)
+//	deadletter [-store path] -config path replay (ID... | -all)
+//
+// Replay rebuilds each message's pipeline from the notifier config, so it
+// goes out through the same SMTP server or push service it failed on.
+// Delivered messages are removed from the store; messages no pipeline in
+// the config matches are left alone.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/drive-deep/interview_preparation/design_patterns/go/creational/factory"
+)
+
+const usage = `usage: deadletter [-store path] [-config path] <command> [args]
+
+commands:
+  list                    list dead letters, oldest first
+  show ID                 print one dead letter as JSON
+  purge (ID... | -all)    delete dead letters
+  replay (ID... | -all)   send dead letters again through -config and remove the delivered ones
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes one command and returns the process exit code
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("deadletter", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	defaultStore := os.Getenv("DEADLETTER_STORE")
+	if defaultStore == "" {
+		defaultStore = "deadletters.log"
+	}
+	storePath := flags.String("store", defaultStore, "dead-letter store file (env DEADLETTER_STORE)")
+	configPath := flags.String("config", os.Getenv("DEADLETTER_CONFIG"), "notifier config to replay through (env DEADLETTER_CONFIG)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	store, err := factory.OpenDeadLetters(*storePath, nil)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer store.Close()
+
+	command, rest := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "list":
+		return list(store, stdout)
+	case "show":
+		if len(rest) != 1 {
+			fmt.Fprintln(stderr, "usage: deadletter show ID")
+			return 2
+		}
+		return show(store, rest[0], stdout, stderr)
+	case "purge", "replay":
+		ids, err := selectIDs(store, rest)
+		if err != nil {
+			fmt.Fprintf(stderr, "deadletter %s: %v\n", command, err)
+			return 2
+		}
+		if command == "purge" {
+			return purge(store, ids, stdout, stderr)
+		}
+		if *configPath == "" {
+			fmt.Fprintln(stderr, "deadletter replay: -config is required to rebuild the original pipelines")
+			return 2
+		}
+		config, err := factory.LoadConfig(*configPath)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return replay(ctx, store, ids, factory.ConfigReplayBuilder(config, nil), stdout, stderr)
+	}
+	fmt.Fprintf(stderr, "deadletter: unknown command %q\n", command)
+	flags.Usage()
+	return 2
+}
+
+func list(store *factory.DeadLetterStore, stdout io.Writer) int {
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCHANNEL\tVENDOR\tATTEMPTS\tLAST ATTEMPT\tERROR")
+	for _, letter := range store.List() {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n", letter.ID, letter.Channel, letter.Vendor, letter.Attempts,
+			letter.LastAttemptAt.Format(time.RFC3339), truncate(letter.LastError, 60))
+	}
+	w.Flush()
+	return 0
+}
+
+func show(store *factory.DeadLetterStore, arg string, stdout, stderr io.Writer) int {
+	id, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		fmt.Fprintf(stderr, "deadletter show: invalid ID %q\n", arg)
+		return 2
+	}
+	letter, err := store.Get(id)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(letter)
+	return 0
+}
+
+// selectIDs parses ID arguments, or takes every ID for -all
+func selectIDs(store *factory.DeadLetterStore, args []string) ([]uint64, error) {
+	if len(args) == 1 && (args[0] == "-all" || args[0] == "--all") {
+		var ids []uint64
+		for _, letter := range store.List() {
+			ids = append(ids, letter.ID)
+		}
+		return ids, nil
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("give IDs or -all")
+	}
+	ids := make([]uint64, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q", arg)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func purge(store *factory.DeadLetterStore, ids []uint64, stdout, stderr io.Writer) int {
+	before := store.Len()
+	err := store.Purge(ids...)
+	fmt.Fprintf(stdout, "purged %d dead letter(s)\n", before-store.Len())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func replay(ctx context.Context, store *factory.DeadLetterStore, ids []uint64, build factory.ReplayBuilder, stdout, stderr io.Writer) int {
+	failed := 0
+	for _, id := range ids {
+		if err := store.Replay(ctx, id, build); err != nil {
+			fmt.Fprintln(stderr, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(stdout, "replayed %d\n", id)
+	}
+	fmt.Fprintf(stdout, "%d replayed, %d failed\n", len(ids)-failed, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/drive-deep/interview_preparation/design_patterns/go/creational/factory"
+)
+
+// newStore writes dead letters to a fresh store file and returns its path
+func newStore(t *testing.T, letters ...factory.DeadLetter) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "deadletters.log")
+	store, err := factory.OpenDeadLetters(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	for _, letter := range letters {
+		if _, err := store.Add(letter); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+// newConfig writes a notifier config with an SMS pipeline and returns its path
+func newConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notifiers.json")
+	config := `{"notifiers": {"alerts": {"channel": "sms", "vendors": ["twilio", "nexmo"]}}}`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runCLI(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+var (
+	smsLetter = factory.DeadLetter{
+		Channel:   "sms",
+		Vendor:    "twilio",
+		Message:   factory.Message{To: []string{"+15551234567"}, Body: "Your code is 1234"},
+		LastError: "send failed after 3 attempt(s): vendor down",
+		Attempts:  3,
+	}
+	pagerLetter = factory.DeadLetter{
+		Channel:   "pager",
+		Vendor:    "acme",
+		Message:   factory.Message{Body: "disk full"},
+		LastError: "unknown notifier type",
+	}
+)
+
+// ============================================================================
+// DEAD-LETTER CLI TESTS
+// ============================================================================
+
+func TestRun_List(t *testing.T) {
+	path := newStore(t, smsLetter, pagerLetter)
+
+	code, stdout, _ := runCLI(t, "-store", path, "list")
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if code != 0 || len(lines) != 3 {
+		t.Fatalf("expected a header and 2 rows, got %d:\n%s", code, stdout)
+	}
+	if fields := strings.Fields(lines[1]); fields[0] != "1" || fields[1] != "sms" || fields[2] != "twilio" || fields[3] != "3" {
+		t.Errorf("unexpected row %q", lines[1])
+	}
+}
+
+func TestRun_Show(t *testing.T) {
+	path := newStore(t, smsLetter)
+
+	code, stdout, _ := runCLI(t, "-store", path, "show", "1")
+	var letter factory.DeadLetter
+	if err := json.Unmarshal([]byte(stdout), &letter); err != nil || code != 0 {
+		t.Fatalf("expected JSON output, got %d %v:\n%s", code, err, stdout)
+	}
+	if letter.Message.Body != "Your code is 1234" || letter.Attempts != 3 {
+		t.Errorf("unexpected letter %+v", letter)
+	}
+}
+
+func TestRun_ReplayAll(t *testing.T) {
+	path := newStore(t, smsLetter, pagerLetter)
+
+	code, stdout, stderr := runCLI(t, "-store", path, "-config", newConfig(t), "replay", "-all")
+	if code != 1 || !strings.Contains(stdout, "replayed 1\n") || !strings.Contains(stdout, "1 replayed, 1 failed") {
+		t.Errorf("expected the SMS to replay and the pager letter to fail, got %d:\n%s", code, stdout)
+	}
+	if !strings.Contains(stderr, "cannot rebuild the original transport") {
+		t.Errorf("expected the pager letter refused on stderr, got %q", stderr)
+	}
+	if _, stdout, _ := runCLI(t, "-store", path, "list"); strings.Contains(stdout, "twilio") || !strings.Contains(stdout, "pager") {
+		t.Errorf("expected only the pager letter left, got:\n%s", stdout)
+	}
+}
+
+func TestRun_Purge(t *testing.T) {
+	path := newStore(t, smsLetter, pagerLetter, smsLetter)
+
+	if code, stdout, _ := runCLI(t, "-store", path, "purge", "1", "3"); code != 0 || stdout != "purged 2 dead letter(s)\n" {
+		t.Errorf("expected 2 purged, got %d %q", code, stdout)
+	}
+	if code, stdout, _ := runCLI(t, "-store", path, "purge", "-all"); code != 0 || stdout != "purged 1 dead letter(s)\n" {
+		t.Errorf("expected the last letter purged, got %d %q", code, stdout)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestRun_BadArguments(t *testing.T) {
+	path := newStore(t, smsLetter)
+	config := newConfig(t)
+
+	testCases := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"no command", []string{"-store", path}, 2},
+		{"unknown command", []string{"-store", path, "resend"}, 2},
+		{"show without ID", []string{"-store", path, "show"}, 2},
+		{"invalid ID", []string{"-store", path, "purge", "abc"}, 2},
+		{"replay without IDs", []string{"-store", path, "-config", config, "replay"}, 2},
+		{"replay without config", []string{"-store", path, "replay", "1"}, 2},
+		{"missing config", []string{"-store", path, "-config", config + ".missing", "replay", "1"}, 1},
+		{"unknown ID", []string{"-store", path, "show", "99"}, 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if code, _, stderr := runCLI(t, tc.args...); code != tc.code || stderr == "" {
+				t.Errorf("expected exit %d with a message, got %d %q", tc.code, code, stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+// Command notify sends one notification, so a test alert needs no Go code.
+//
+// Usage:
+//
+//	notify -channel sms -vendor twilio -to +15551234567 "Your code is 1234"
+//	echo "disk full" | notify -config notifiers.yaml -notifier ops
+//	notify -channel email -vendor ses -to ops@example.com -template deploy -var Service=api
+//
+// The result, with its delivery ID, goes to stdout; notifiers that only log
+// print to stderr, so -json output stays parseable. An SMS -dry-run also
+// reports the segments, and their cost at -sms-price.
+//
+// Without -config the notifier comes from NotifierFactory; with it the named
+// pipeline is built from the config file, retries and failover included.
+// The body is taken from the arguments, or from stdin when there are none
+// or the only argument is "-". A -template is rendered from -templates
+// instead, laid out as Templates.LoadFS expects.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/drive-deep/interview_preparation/design_patterns/go/creational/factory"
+)
+
+const usage = `usage: notify [flags] [body... | -]
+
+flags:
+  -channel name       notifier type, e.g. email, sms, push or webhook
+  -vendor name        vendor passed to NotifierFactory (the URL for webhook)
+  -config path        build the notifier from a JSON or YAML config instead
+  -notifier name      pipeline in -config, optional when it defines only one
+  -to addr            recipient, repeatable or comma-separated
+  -subject text       subject line
+  -priority p         bulk, normal (default), high or critical
+  -template name      render this template instead of reading a body
+  -templates dir      template directory (env NOTIFY_TEMPLATES, default templates)
+  -locale tag         template locale (default en)
+  -var key=value      template variable, repeatable
+  -timeout d          give up after d (default 30s)
+  -dry-run            build and validate everything, plan SMS segments, but do not send
+  -sms-price p        price per SMS segment for the -dry-run cost, overriding -config
+  -json               print the result as JSON
+`
+
+// result is the outcome of one notify run, printed by -json
+type result struct {
+	Channel  string   `json:"channel"`
+	Vendor   string   `json:"vendor,omitempty"`
+	Notifier string   `json:"notifier,omitempty"` // config pipeline name
+	To       []string `json:"to,omitempty"`
+	Subject  string   `json:"subject,omitempty"`
+	Body     string   `json:"body"`
+	Priority string   `json:"priority"`
+	DryRun   bool     `json:"dry_run,omitempty"`
+	Sent     bool     `json:"sent"`
+	Attempts int      `json:"attempts,omitempty"` // reported by retrying pipelines on failure
+	Duration string   `json:"duration,omitempty"`
+	Error    string   `json:"error,omitempty"`
+
+	DeliveryID string   `json:"delivery_id,omitempty"`
+	State      string   `json:"state,omitempty"` // tracked delivery state: sent or failed
+	SMS        *smsPlan `json:"sms,omitempty"`   // how an SMS dry run would be split
+}
+
+// smsPlan summarises factory.SMSPlan
+type smsPlan struct {
+	Encoding string  `json:"encoding"`
+	Segments int     `json:"segments"`
+	Units    int     `json:"units"`
+	Cost     float64 `json:"cost"`
+}
+
+// listFlag collects a flag given several times or as a comma-separated list
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// varsFlag collects -var key=value pairs
+type varsFlag map[string]any
+
+func (v varsFlag) String() string {
+	return fmt.Sprint(map[string]any(v))
+}
+
+func (v varsFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("want key=value, got %q", value)
+	}
+	v[key] = val
+	return nil
+}
+
+type options struct {
+	channel, vendor    string
+	configPath, name   string
+	to                 listFlag
+	subject, priority  string
+	template, dir      string
+	locale             string
+	vars               varsFlag
+	timeout            time.Duration
+	smsPrice           float64
+	dryRun, jsonOutput bool
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run sends one notification and returns the process exit code
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opts := options{vars: varsFlag{}}
+	flags := flag.NewFlagSet("notify", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	defaultTemplates := os.Getenv("NOTIFY_TEMPLATES")
+	if defaultTemplates == "" {
+		defaultTemplates = "templates"
+	}
+	flags.StringVar(&opts.channel, "channel", "", "notifier type")
+	flags.StringVar(&opts.vendor, "vendor", "", "vendor passed to NotifierFactory")
+	flags.StringVar(&opts.configPath, "config", "", "JSON or YAML notifier config")
+	flags.StringVar(&opts.name, "notifier", "", "pipeline name in -config")
+	flags.Var(&opts.to, "to", "recipient, repeatable or comma-separated")
+	flags.StringVar(&opts.subject, "subject", "", "subject line")
+	flags.StringVar(&opts.priority, "priority", "normal", "bulk, normal, high or critical")
+	flags.StringVar(&opts.template, "template", "", "template name")
+	flags.StringVar(&opts.dir, "templates", defaultTemplates, "template directory (env NOTIFY_TEMPLATES)")
+	flags.StringVar(&opts.locale, "locale", "en", "template locale")
+	flags.Var(opts.vars, "var", "template variable as key=value, repeatable")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "give up after this long")
+	flags.Float64Var(&opts.smsPrice, "sms-price", 0, "price per SMS segment for the dry-run cost")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "validate without sending")
+	flags.BoolVar(&opts.jsonOutput, "json", false, "print the result as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	// Notifiers without a transport print their sends; keep stdout for the result
+	defer factory.SetLogOutput(factory.SetLogOutput(stderr))
+
+	priority, err := parsePriority(opts.priority)
+	if err != nil {
+		fmt.Fprintf(stderr, "notify: %v\n", err)
+		return 2
+	}
+	if opts.template != "" && flags.NArg() > 0 {
+		fmt.Fprintln(stderr, "notify: give a body or -template, not both")
+		return 2
+	}
+	if err := checkSource(opts); err != nil {
+		fmt.Fprintf(stderr, "notify: %v\n", err)
+		flags.Usage()
+		return 2
+	}
+
+	res := &result{Priority: priority.String(), DryRun: opts.dryRun}
+	notifier, spec, err := buildNotifier(opts, res)
+	if err != nil {
+		return fail(res, err, opts.jsonOutput, stdout, stderr)
+	}
+	msg, err := buildMessage(opts, res.Channel, flags.Args(), stdin)
+	if err != nil {
+		return fail(res, err, opts.jsonOutput, stdout, stderr)
+	}
+	msg.Priority = priority
+	res.To, res.Subject, res.Body = msg.To, msg.Subject, msg.Body
+
+	start := time.Now()
+	switch {
+	case opts.dryRun && res.Channel == "sms":
+		res.SMS, err = planSMS(notifier, spec, res.Vendor, opts.smsPrice, msg)
+	case opts.dryRun:
+		err = msg.Validate(res.Channel)
+	default:
+		ctx, cancel := context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+		tracker := factory.NewDeliveryTracker(nil)
+		res.DeliveryID, err = factory.NewTrackingNotifier(notifier, res.Channel, tracker).Track(ctx, msg)
+		if status, getErr := tracker.Get(res.DeliveryID); getErr == nil {
+			res.State = status.State.String()
+		}
+	}
+	res.Duration = time.Since(start).Round(time.Microsecond).String()
+	if err != nil {
+		var retryErr *factory.RetryError
+		if errors.As(err, &retryErr) {
+			res.Attempts = retryErr.Attempts
+		}
+		return fail(res, err, opts.jsonOutput, stdout, stderr)
+	}
+	res.Sent = !opts.dryRun
+	report(res, opts.jsonOutput, stdout)
+	return 0
+}
+
+// checkSource rejects flag combinations that leave the notifier ambiguous
+func checkSource(opts options) error {
+	if opts.configPath != "" {
+		if opts.channel != "" || opts.vendor != "" {
+			return errors.New("-channel and -vendor cannot be combined with -config")
+		}
+		return nil
+	}
+	if opts.name != "" {
+		return errors.New("-notifier needs -config")
+	}
+	if opts.channel == "" || opts.vendor == "" {
+		return errors.New("give -channel and -vendor, or -config")
+	}
+	return nil
+}
+
+// buildNotifier creates the notifier and fills in where it sends to. The
+// config pipeline's spec is returned too, nil without -config.
+func buildNotifier(opts options, res *result) (factory.Notifier, *factory.NotifierSpec, error) {
+	if opts.configPath == "" {
+		res.Channel, res.Vendor = opts.channel, opts.vendor
+		notifier, err := factory.NotifierFactory(opts.channel, opts.vendor)
+		return notifier, nil, err
+	}
+
+	config, err := factory.LoadConfig(opts.configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	name := opts.name
+	if name == "" {
+		if len(config.Notifiers) != 1 {
+			names := make([]string, 0, len(config.Notifiers))
+			for n := range config.Notifiers {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return nil, nil, fmt.Errorf("%s defines %d notifiers, choose one with -notifier: %s",
+				opts.configPath, len(names), strings.Join(names, ", "))
+		}
+		for n := range config.Notifiers {
+			name = n
+		}
+	}
+	res.Notifier = name
+	spec, ok := config.Notifiers[name]
+	if ok {
+		res.Channel = spec.Channel
+		res.Vendor = spec.Vendor
+		if len(spec.Vendors) > 0 {
+			res.Vendor = strings.Join(spec.Vendors, ",")
+		}
+	}
+	notifier, err := config.BuildNotifier(name, nil)
+	return notifier, &spec, err
+}
+
+// buildMessage renders the template or reads the body from args or stdin
+func buildMessage(opts options, channel string, args []string, stdin io.Reader) (factory.Message, error) {
+	var msg factory.Message
+	if opts.template != "" {
+		templates := factory.NewTemplates(opts.locale)
+		if err := templates.LoadFS(os.DirFS(opts.dir)); err != nil {
+			return msg, err
+		}
+		rendered, err := templates.Render(opts.template, channel, opts.locale, map[string]any(opts.vars))
+		if err != nil {
+			return msg, err
+		}
+		msg = rendered
+	} else {
+		body := strings.Join(args, " ")
+		if len(args) == 0 || (len(args) == 1 && args[0] == "-") {
+			data, err := io.ReadAll(stdin)
+			if err != nil {
+				return msg, fmt.Errorf("read stdin: %w", err)
+			}
+			body = strings.TrimRight(string(data), "\r\n")
+		}
+		msg.Body = body
+	}
+	msg.To = opts.to
+	if opts.subject != "" {
+		msg.Subject = opts.subject
+	}
+	return msg, nil
+}
+
+// planSMS reports how an SMS would be split and what it would cost. A
+// config pipeline is planned with its first vendor and sms settings, since
+// the built notifier is wrapped; a price given with -sms-price wins.
+func planSMS(notifier factory.Notifier, spec *factory.NotifierSpec, vendor string, price float64, msg factory.Message) (*smsPlan, error) {
+	sms, ok := notifier.(*factory.SmsNotifier)
+	switch {
+	case spec != nil:
+		var err error
+		if sms, err = spec.SMSNotifier(); err != nil {
+			return nil, err
+		}
+	case !ok:
+		sms = factory.NewSmsNotifier(vendor)
+	}
+	if price > 0 {
+		sms.PricePerSegment = price
+	}
+	plan, err := sms.Plan(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &smsPlan{Encoding: plan.Encoding.String(), Segments: len(plan.Segments), Units: plan.Units, Cost: plan.Cost}, nil
+}
+
+func parsePriority(s string) (factory.Priority, error) {
+	for _, p := range []factory.Priority{factory.PriorityBulk, factory.PriorityNormal, factory.PriorityHigh, factory.PriorityCritical} {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid -priority %q, want bulk, normal, high or critical", s)
+}
+
+func fail(res *result, err error, jsonOutput bool, stdout, stderr io.Writer) int {
+	res.Error = err.Error()
+	if jsonOutput {
+		report(res, true, stdout)
+	} else {
+		fmt.Fprintf(stderr, "notify: %v\n", err)
+	}
+	return 1
+}
+
+func report(res *result, jsonOutput bool, stdout io.Writer) {
+	if jsonOutput {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(res)
+		return
+	}
+	target := res.Channel + " via " + res.Vendor
+	if len(res.To) > 0 {
+		target += " to " + strings.Join(res.To, ", ")
+	}
+	if res.DryRun {
+		fmt.Fprintf(stdout, "dry run: would send %s\n", target)
+		if res.SMS != nil {
+			fmt.Fprintf(stdout, "SMS: %d %s segment(s), %d units, cost %.4f\n", res.SMS.Segments, res.SMS.Encoding, res.SMS.Units, res.SMS.Cost)
+		}
+		if res.Subject != "" {
+			fmt.Fprintf(stdout, "Subject: %s\n", res.Subject)
+		}
+		fmt.Fprintf(stdout, "\n%s\n", res.Body)
+		return
+	}
+	fmt.Fprintf(stdout, "sent %s in %s, delivery %s\n", target, res.Duration, res.DeliveryID)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/drive-deep/interview_preparation/design_patterns/go/creational/factory"
+	"github.com/drive-deep/interview_preparation/design_patterns/go/creational/factory/notifiertest"
+)
+
+func runCLI(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func decodeResult(t *testing.T, stdout string) result {
+	t.Helper()
+	var res result
+	if err := json.Unmarshal([]byte(stdout), &res); err != nil {
+		t.Fatalf("expected JSON output, got %v:\n%s", err, stdout)
+	}
+	return res
+}
+
+// writeFiles creates files under a temporary directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// ============================================================================
+// NOTIFY CLI TESTS
+// ============================================================================
+
+func TestRun_SendsThroughFactory(t *testing.T) {
+	recorder := notifiertest.Install(t, nil, "sms")
+
+	code, stdout, stderr := runCLI(t, "", "-channel", "sms", "-vendor", "twilio", "-to", "+15551234567",
+		"-priority", "high", "-json", "Your", "code", "is", "1234")
+	if code != 0 {
+		t.Fatalf("expected success, got %d: %s", code, stderr)
+	}
+	res := decodeResult(t, stdout)
+	if !res.Sent || res.Channel != "sms" || res.Vendor != "twilio" || res.Priority != "high" || res.Duration == "" {
+		t.Errorf("unexpected result %+v", res)
+	}
+	sent := recorder.Sent()
+	if len(sent) != 1 || sent[0].Vendor != "twilio" || sent[0].Message.Priority != factory.PriorityHigh {
+		t.Fatalf("expected one high-priority send through twilio, got %+v", sent)
+	}
+	notifiertest.AssertSent(t, recorder, "Your code is 1234")
+	notifiertest.AssertSentTo(t, recorder, "+15551234567")
+}
+
+func TestRun_BodyFromStdin(t *testing.T) {
+	recorder := notifiertest.Install(t, nil, "email")
+
+	code, stdout, _ := runCLI(t, "disk full on db-1\n", "-channel", "email", "-vendor", "ses",
+		"-to", "ops@example.com,oncall@example.com", "-subject", "Alert", "-")
+	if code != 0 || !strings.HasPrefix(stdout, "sent email via ses to ops@example.com, oncall@example.com in ") {
+		t.Errorf("unexpected output %d %q", code, stdout)
+	}
+	notifiertest.AssertSent(t, recorder, "disk full on db-1")
+	notifiertest.AssertSentTo(t, recorder, "oncall@example.com")
+}
+
+func TestRun_ConfigPipeline(t *testing.T) {
+	recorder := notifiertest.Install(t, nil, "sms")
+	dir := writeFiles(t, map[string]string{"notifiers.yaml": `notifiers:
+  alerts:
+    channel: sms
+    vendors: [twilio, nexmo]
+    retry:
+      max_attempts: 2
+      base_delay: 1ms
+`})
+
+	code, stdout, stderr := runCLI(t, "", "-config", filepath.Join(dir, "notifiers.yaml"), "-json", "-to", "+15551234567", "deploy done")
+	if code != 0 {
+		t.Fatalf("expected success, got %d: %s", code, stderr)
+	}
+	if res := decodeResult(t, stdout); res.Notifier != "alerts" || res.Channel != "sms" || res.Vendor != "twilio,nexmo" {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if sent := recorder.Sent(); len(sent) != 1 || sent[0].Vendor != "twilio" {
+		t.Errorf("expected the first vendor to deliver, got %+v", sent)
+	}
+}
+
+func TestRun_TemplateDryRun(t *testing.T) {
+	recorder := notifiertest.Install(t, nil, "email")
+	dir := writeFiles(t, map[string]string{
+		"email/en/deploy.subject.tmpl": "{{.Service}} deployed",
+		"email/en/deploy.txt.tmpl":     "{{.Service}} {{.Version}} is live.",
+	})
+
+	code, stdout, stderr := runCLI(t, "", "-channel", "email", "-vendor", "ses", "-to", "ops@example.com",
+		"-templates", dir, "-template", "deploy", "-var", "Service=api", "-var", "Version=1.4.2", "-dry-run")
+	if code != 0 {
+		t.Fatalf("expected success, got %d: %s", code, stderr)
+	}
+	want := "dry run: would send email via ses to ops@example.com\nSubject: api deployed\n\napi 1.4.2 is live.\n"
+	if stdout != want {
+		t.Errorf("expected %q, got %q", want, stdout)
+	}
+	notifiertest.AssertNoSends(t, recorder)
+}
+
+func TestRun_JSONStaysValidWithLoggingNotifier(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "", "-channel", "sms", "-vendor", "twilio", "-to", "+15551234567", "-json", "hello")
+	if code != 0 {
+		t.Fatalf("expected success, got %d: %s", code, stderr)
+	}
+	res := decodeResult(t, stdout)
+	if !res.Sent || res.DeliveryID == "" || res.State != "sent" {
+		t.Errorf("expected a tracked delivery, got %+v", res)
+	}
+	if !strings.Contains(stderr, "Msg 'hello' sent from SMS vendor: twilio") {
+		t.Errorf("expected the logged send on stderr, got %q", stderr)
+	}
+}
+
+func TestRun_SMSDryRunReportsPlan(t *testing.T) {
+	recorder := notifiertest.Install(t, nil, "sms")
+	body := strings.Repeat("a", 200)
+
+	code, stdout, stderr := runCLI(t, "", "-channel", "sms", "-vendor", "twilio", "-to", "+15551234567", "-dry-run", "-json", "-sms-price", "0.0075", body)
+	if code != 0 {
+		t.Fatalf("expected success, got %d: %s", code, stderr)
+	}
+	res := decodeResult(t, stdout)
+	if res.SMS == nil || res.SMS.Encoding != "GSM-7" || res.SMS.Segments != 2 || res.SMS.Cost != 0.015 {
+		t.Errorf("expected a two-segment GSM-7 plan costing 0.015, got %+v", res.SMS)
+	}
+	if res.Sent || res.DeliveryID != "" {
+		t.Errorf("expected nothing sent, got %+v", res)
+	}
+
+	_, stdout, _ = runCLI(t, "", "-channel", "sms", "-vendor", "twilio", "-to", "+15551234567", "-dry-run", body)
+	if !strings.Contains(stdout, "SMS: 2 GSM-7 segment(s), ") {
+		t.Errorf("expected the plan in the text output, got %q", stdout)
+	}
+	notifiertest.AssertNoSends(t, recorder)
+}
+
+func TestRun_SMSDryRunUsesConfigSettings(t *testing.T) {
+	recorder := notifiertest.Install(t, nil, "sms")
+	dir := writeFiles(t, map[string]string{"notifiers.yaml": `notifiers:
+  alerts:
+    channel: sms
+    vendors: [twilio, nexmo]
+    retry:
+      max_attempts: 2
+    sms:
+      unicode: transliterate
+      max_segments: 1
+      price_per_segment: 0.01
+`})
+	config := filepath.Join(dir, "notifiers.yaml")
+
+	code, stdout, stderr := runCLI(t, "", "-config", config, "-to", "+15551234567", "-dry-run", "-json", "Deploy “done”")
+	if code != 0 {
+		t.Fatalf("expected success, got %d: %s", code, stderr)
+	}
+	if res := decodeResult(t, stdout); res.SMS == nil || res.SMS.Encoding != "GSM-7" || res.SMS.Segments != 1 || res.SMS.Cost != 0.01 {
+		t.Errorf("expected a transliterated one-segment plan at the configured price, got %+v", res.SMS)
+	}
+
+	code, stdout, _ = runCLI(t, "", "-config", config, "-to", "+15551234567", "-dry-run", "-json", strings.Repeat("a", 200))
+	if res := decodeResult(t, stdout); code != 1 || !strings.Contains(res.Error, "limit is 1") {
+		t.Errorf("expected the configured segment limit to reject the body, got %d %+v", code, res)
+	}
+	notifiertest.AssertNoSends(t, recorder)
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestRun_BadArguments(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"notifiers.json": `{"notifiers": {
+		"a": {"channel": "sms", "vendor": "twilio"},
+		"b": {"channel": "email", "vendor": "ses"}}}`})
+	config := filepath.Join(dir, "notifiers.json")
+
+	testCases := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"no notifier", []string{"hello"}, 2},
+		{"channel without vendor", []string{"-channel", "sms", "hello"}, 2},
+		{"config with channel", []string{"-config", config, "-channel", "sms", "hello"}, 2},
+		{"notifier without config", []string{"-notifier", "a", "hello"}, 2},
+		{"template and body", []string{"-channel", "sms", "-vendor", "twilio", "-template", "x", "hello"}, 2},
+		{"invalid var", []string{"-channel", "sms", "-vendor", "twilio", "-var", "novalue", "hello"}, 2},
+		{"invalid priority", []string{"-channel", "sms", "-vendor", "twilio", "-priority", "urgent", "hello"}, 2},
+		{"unknown channel", []string{"-channel", "pager", "-vendor", "acme", "hello"}, 1},
+		{"ambiguous config", []string{"-config", config, "hello"}, 1},
+		{"unknown pipeline", []string{"-config", config, "-notifier", "c", "hello"}, 1},
+		{"missing template", []string{"-channel", "sms", "-vendor", "twilio", "-templates", dir, "-template", "x"}, 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if code, _, stderr := runCLI(t, "", tc.args...); code != tc.code || stderr == "" {
+				t.Errorf("expected exit %d with a message, got %d %q", tc.code, code, stderr)
+			}
+		})
+	}
+}
+
+func TestRun_InvalidMessageReportedAsJSON(t *testing.T) {
+	recorder := notifiertest.Install(t, nil, "sms")
+
+	code, stdout, _ := runCLI(t, "", "-channel", "sms", "-vendor", "twilio", "-to", "5551234", "-dry-run", "-json", "hello")
+	res := decodeResult(t, stdout)
+	if code != 1 || res.Sent || !strings.Contains(res.Error, "E.164") {
+		t.Errorf("expected the invalid number reported, got %d %+v", code, res)
+	}
+	notifiertest.AssertNoSends(t, recorder)
+}
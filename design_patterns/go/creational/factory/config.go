@@ -0,0 +1,709 @@
+package factory
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidConfig is matched by every *ConfigError
+var ErrInvalidConfig = errors.New("invalid config")
+
+// ConfigError points at the config value that is wrong, e.g.
+// "notifiers.alerts.retry.max_attempts"
+type ConfigError struct {
+	Path   string
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	if e.Path == "" {
+		return "config: " + e.Reason
+	}
+	return fmt.Sprintf("config: %s: %s", e.Path, e.Reason)
+}
+
+// Is makes every ConfigError match ErrInvalidConfig
+func (e *ConfigError) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
+// Duration is a time.Duration written as a string such as "250ms" or "2s"
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalText writes the duration in time.Duration notation
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText parses time.Duration notation
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Config declares named notifier pipelines. In YAML:
+//
+//	notifiers:
+//	  alerts:
+//	    channel: sms
+//	    vendors: [twilio, nexmo]      # failover order
+//	    retry:
+//	      max_attempts: 4
+//	      base_delay: 200ms
+//	    rate_limit:
+//	      limit: 10
+//	      per: 1s
+//	    sms:
+//	      unicode: transliterate
+//	      max_segments: 3
+//	  ops:
+//	    channel: email
+//	    vendor: sendgrid
+//	    smtp:
+//	      host: smtp.example.com:587
+//	      username: alerts
+//	      password_env: SMTP_PASSWORD
+//	      from: alerts@example.com
+//
+// Each vendor is rate limited on its own, the vendors are combined with
+// failover, and retry wraps the result.
+type Config struct {
+	Notifiers map[string]NotifierSpec `json:"notifiers"`
+}
+
+// NotifierSpec declares one notifier pipeline
+type NotifierSpec struct {
+	Channel string   `json:"channel"`
+	Vendor  string   `json:"vendor,omitempty"`
+	Vendors []string `json:"vendors,omitempty"` // tried in order with failover
+
+	SMTP    *SMTPSpec    `json:"smtp,omitempty"`    // email only
+	Webhook *WebhookSpec `json:"webhook,omitempty"` // required for webhook
+	SMS     *SMSSpec     `json:"sms,omitempty"`     // sms only, applied to the built-in SmsNotifier
+
+	Retry     *RetrySpec     `json:"retry,omitempty"`
+	RateLimit *RateLimitSpec `json:"rate_limit,omitempty"`
+}
+
+// SMTPSpec configures delivery through an SMTP server.
+// Secrets may be given inline or, preferably, as an environment variable.
+type SMTPSpec struct {
+	Host        string   `json:"host"` // host:port
+	Username    string   `json:"username,omitempty"`
+	Password    string   `json:"password,omitempty"`
+	PasswordEnv string   `json:"password_env,omitempty"`
+	From        string   `json:"from"`
+	To          []string `json:"to,omitempty"`
+	Subject     string   `json:"subject,omitempty"`
+	StartTLS    bool     `json:"start_tls,omitempty"`
+	Timeout     Duration `json:"timeout,omitempty"`
+}
+
+// WebhookSpec configures a webhook endpoint. A signing secret is required,
+// inline or as a non-empty environment variable.
+type WebhookSpec struct {
+	URL       string `json:"url"`
+	Secret    string `json:"secret,omitempty"`
+	SecretEnv string `json:"secret_env,omitempty"`
+}
+
+// SMSSpec sets the SmsNotifier encoding, segment and pricing options
+type SMSSpec struct {
+	Unicode         string  `json:"unicode,omitempty"`      // allow (default), transliterate or reject
+	MaxSegments     int     `json:"max_segments,omitempty"` // 0 for no limit
+	PricePerSegment float64 `json:"price_per_segment,omitempty"`
+}
+
+// RetrySpec mirrors RetryPolicy; zero values take its defaults
+type RetrySpec struct {
+	MaxAttempts int      `json:"max_attempts,omitempty"`
+	BaseDelay   Duration `json:"base_delay,omitempty"`
+	MaxDelay    Duration `json:"max_delay,omitempty"`
+	Multiplier  float64  `json:"multiplier,omitempty"`
+}
+
+// RateLimitSpec configures a limiter per vendor
+type RateLimitSpec struct {
+	Algorithm string   `json:"algorithm,omitempty"` // token_bucket (default), leaky_bucket or sliding_window
+	Limit     int      `json:"limit"`
+	Per       Duration `json:"per,omitempty"`   // defaults to 1s
+	Burst     int      `json:"burst,omitempty"` // bucket size, defaults to limit
+	Mode      string   `json:"mode,omitempty"`  // wait (default) or reject
+	MaxWait   Duration `json:"max_wait,omitempty"`
+}
+
+// LoadConfig reads a JSON (.json) or YAML (.yaml, .yml) config file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if format == "yml" {
+		format = "yaml"
+	}
+	return ParseConfig(data, format)
+}
+
+// ParseConfig decodes a "json" or "yaml" document. Unknown keys and values
+// of the wrong type are reported with their config path.
+func ParseConfig(data []byte, format string) (*Config, error) {
+	var tree any
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&tree); err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+		if _, err := dec.Token(); err != io.EOF {
+			return nil, &ConfigError{Reason: "unexpected data after the JSON document"}
+		}
+	case "yaml":
+		var err error
+		if tree, err = parseYAML(data); err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("config: unsupported format %q, want json or yaml", format)
+	}
+
+	var config Config
+	if err := decodeConfig("", tree, reflect.ValueOf(&config).Elem()); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*interface{ UnmarshalText([]byte) error })(nil)).Elem()
+
+// decodeConfig stores a decoded JSON/YAML tree into target, naming the
+// path of the first value that does not fit
+func decodeConfig(path string, node any, target reflect.Value) error {
+	if node == nil {
+		return nil
+	}
+	mismatch := func(want string) error {
+		return &ConfigError{Path: path, Reason: fmt.Sprintf("expected %s, got %s", want, describeConfigValue(node))}
+	}
+
+	if reflect.PointerTo(target.Type()).Implements(textUnmarshalerType) {
+		s, ok := node.(string)
+		if !ok {
+			return mismatch("a string")
+		}
+		if err := target.Addr().Interface().(interface{ UnmarshalText([]byte) error }).UnmarshalText([]byte(s)); err != nil {
+			return &ConfigError{Path: path, Reason: err.Error()}
+		}
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.Pointer:
+		value := reflect.New(target.Type().Elem())
+		if err := decodeConfig(path, node, value.Elem()); err != nil {
+			return err
+		}
+		target.Set(value)
+	case reflect.Struct:
+		m, ok := node.(map[string]any)
+		if !ok {
+			return mismatch("a mapping")
+		}
+		fields := make(map[string]int)
+		for i := 0; i < target.NumField(); i++ {
+			name, _, _ := strings.Cut(target.Type().Field(i).Tag.Get("json"), ",")
+			fields[name] = i
+		}
+		for _, key := range sortedKeys(m) {
+			i, ok := fields[key]
+			if !ok {
+				return &ConfigError{Path: joinConfigPath(path, key), Reason: "unknown field"}
+			}
+			if err := decodeConfig(joinConfigPath(path, key), m[key], target.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		m, ok := node.(map[string]any)
+		if !ok {
+			return mismatch("a mapping")
+		}
+		target.Set(reflect.MakeMapWithSize(target.Type(), len(m)))
+		for _, key := range sortedKeys(m) {
+			value := reflect.New(target.Type().Elem()).Elem()
+			if err := decodeConfig(joinConfigPath(path, key), m[key], value); err != nil {
+				return err
+			}
+			target.SetMapIndex(reflect.ValueOf(key), value)
+		}
+	case reflect.Slice:
+		items, ok := node.([]any)
+		if !ok {
+			return mismatch("a list")
+		}
+		slice := reflect.MakeSlice(target.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeConfig(fmt.Sprintf("%s[%d]", path, i), item, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		target.Set(slice)
+	case reflect.String:
+		s, ok := node.(string)
+		if !ok {
+			return mismatch("a string")
+		}
+		target.SetString(s)
+	case reflect.Bool:
+		b, ok := node.(bool)
+		if !ok {
+			return mismatch("true or false")
+		}
+		target.SetBool(b)
+	case reflect.Int:
+		n, ok := node.(json.Number)
+		if !ok {
+			return mismatch("an integer")
+		}
+		i, err := strconv.Atoi(string(n))
+		if err != nil {
+			return mismatch("an integer")
+		}
+		target.SetInt(int64(i))
+	case reflect.Float64:
+		n, ok := node.(json.Number)
+		if !ok {
+			return mismatch("a number")
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return mismatch("a number")
+		}
+		target.SetFloat(f)
+	default:
+		return &ConfigError{Path: path, Reason: fmt.Sprintf("unsupported field type %s", target.Type())}
+	}
+	return nil
+}
+
+func describeConfigValue(node any) string {
+	switch v := node.(type) {
+	case map[string]any:
+		return "a mapping"
+	case []any:
+		return "a list"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case json.Number:
+		return "number " + string(v)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+func joinConfigPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Validate checks the config against the default registry and the
+// environment, returning every problem found, joined
+func (c *Config) Validate() error {
+	return c.validate(defaultRegistry, os.LookupEnv)
+}
+
+func (c *Config) validate(registry *Registry, lookupEnv func(string) (string, bool)) error {
+	var errs []error
+	invalid := func(path, format string, args ...any) {
+		errs = append(errs, &ConfigError{Path: path, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	if len(c.Notifiers) == 0 {
+		invalid("notifiers", "at least one notifier is required")
+	}
+	for _, name := range sortedKeys(c.Notifiers) {
+		spec := c.Notifiers[name]
+		path := joinConfigPath("notifiers", name)
+
+		switch {
+		case spec.Channel == "":
+			invalid(path+".channel", "is required")
+		default:
+			if _, ok := registry.Lookup(spec.Channel); !ok {
+				invalid(path+".channel", "unknown channel %q, registered: %s", spec.Channel, strings.Join(registry.List(), ", "))
+			}
+		}
+
+		switch {
+		case spec.Vendor != "" && len(spec.Vendors) > 0:
+			invalid(path, "set vendor or vendors, not both")
+		case spec.Vendor == "" && len(spec.Vendors) == 0 && spec.Channel != "webhook":
+			invalid(path+".vendor", "is required")
+		}
+		for i, vendor := range spec.Vendors {
+			if strings.TrimSpace(vendor) == "" {
+				invalid(fmt.Sprintf("%s.vendors[%d]", path, i), "must not be empty")
+			}
+		}
+
+		if spec.SMTP != nil {
+			if spec.Channel != "email" {
+				invalid(path+".smtp", "is only valid for the email channel")
+			}
+			if len(spec.Vendors) > 1 {
+				invalid(path+".smtp", "cannot be shared by several vendors")
+			}
+			spec.SMTP.validate(path+".smtp", invalid, lookupEnv)
+		}
+		switch {
+		case spec.Webhook != nil && spec.Channel != "webhook":
+			invalid(path+".webhook", "is only valid for the webhook channel")
+		case spec.Webhook == nil && spec.Channel == "webhook":
+			invalid(path+".webhook", "is required for the webhook channel")
+		case spec.Webhook != nil:
+			if len(spec.Vendors) > 1 {
+				invalid(path+".webhook", "cannot be shared by several vendors")
+			}
+			spec.Webhook.validate(path+".webhook", invalid, lookupEnv)
+		}
+
+		if spec.SMS != nil {
+			if spec.Channel != "sms" {
+				invalid(path+".sms", "is only valid for the sms channel")
+			}
+			spec.SMS.validate(path+".sms", invalid)
+		}
+
+		if spec.Retry != nil {
+			spec.Retry.validate(path+".retry", invalid)
+		}
+		if spec.RateLimit != nil {
+			spec.RateLimit.validate(path+".rate_limit", invalid)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type invalidFunc func(path, format string, args ...any)
+
+func (s *SMTPSpec) validate(path string, invalid invalidFunc, lookupEnv func(string) (string, bool)) {
+	if s.Host == "" {
+		invalid(path+".host", "is required")
+	}
+	if s.From == "" {
+		invalid(path+".from", "is required")
+	}
+	if s.Timeout < 0 {
+		invalid(path+".timeout", "must not be negative")
+	}
+	validateSecret(path, "password", s.Password, s.PasswordEnv, invalid, lookupEnv)
+}
+
+func (w *WebhookSpec) validate(path string, invalid invalidFunc, lookupEnv func(string) (string, bool)) {
+	parsed, err := url.Parse(w.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		invalid(path+".url", "must be an http or https URL, got %q", w.URL)
+	}
+	validateSecret(path, "secret", w.Secret, w.SecretEnv, invalid, lookupEnv)
+	// Factory-built webhooks are always signed, as with WebhookSecretEnv
+	if w.Secret == "" {
+		if w.SecretEnv == "" {
+			invalid(path+".secret", "is required, inline or as secret_env")
+		} else if value, ok := lookupEnv(w.SecretEnv); ok && value == "" {
+			invalid(path+".secret", "is required, but environment variable %s is empty", w.SecretEnv)
+		}
+	}
+}
+
+// validateSecret checks that at most one of an inline secret and its _env
+// variant is set, and that the variable exists
+func validateSecret(path, field, inline, env string, invalid invalidFunc, lookupEnv func(string) (string, bool)) {
+	if inline != "" && env != "" {
+		invalid(path, "set %s or %s_env, not both", field, field)
+		return
+	}
+	if env != "" {
+		if _, ok := lookupEnv(env); !ok {
+			invalid(path+"."+field+"_env", "environment variable %s is not set", env)
+		}
+	}
+}
+
+func (s *SMSSpec) validate(path string, invalid invalidFunc) {
+	switch s.Unicode {
+	case "", "allow", "transliterate", "reject":
+	default:
+		invalid(path+".unicode", "must be allow, transliterate or reject, got %q", s.Unicode)
+	}
+	if s.MaxSegments < 0 {
+		invalid(path+".max_segments", "must not be negative")
+	}
+	if s.PricePerSegment < 0 {
+		invalid(path+".price_per_segment", "must not be negative")
+	}
+}
+
+func (r *RetrySpec) validate(path string, invalid invalidFunc) {
+	if r.MaxAttempts < 0 {
+		invalid(path+".max_attempts", "must not be negative (0 means the default of 3)")
+	}
+	if r.BaseDelay < 0 {
+		invalid(path+".base_delay", "must not be negative")
+	}
+	if r.MaxDelay < 0 {
+		invalid(path+".max_delay", "must not be negative")
+	}
+	if r.MaxDelay > 0 && r.BaseDelay > r.MaxDelay {
+		invalid(path+".max_delay", "must not be less than base_delay (%s)", r.BaseDelay)
+	}
+	if r.Multiplier != 0 && r.Multiplier < 1 {
+		invalid(path+".multiplier", "must be at least 1")
+	}
+}
+
+func (r *RateLimitSpec) validate(path string, invalid invalidFunc) {
+	switch r.Algorithm {
+	case "", "token_bucket", "leaky_bucket", "sliding_window":
+	default:
+		invalid(path+".algorithm", "must be token_bucket, leaky_bucket or sliding_window, got %q", r.Algorithm)
+	}
+	if r.Limit <= 0 {
+		invalid(path+".limit", "must be at least 1")
+	}
+	if r.Per < 0 {
+		invalid(path+".per", "must not be negative")
+	}
+	if r.Burst < 0 {
+		invalid(path+".burst", "must not be negative")
+	}
+	switch r.Mode {
+	case "", "wait", "reject":
+	default:
+		invalid(path+".mode", "must be wait or reject, got %q", r.Mode)
+	}
+	if r.MaxWait < 0 {
+		invalid(path+".max_wait", "must not be negative")
+	}
+}
+
+// Build validates the config and constructs every notifier, keyed by name.
+// Vendors are built with registry, or the default registry when nil.
+func (c *Config) Build(registry *Registry) (map[string]Notifier, error) {
+	if registry == nil {
+		registry = defaultRegistry
+	}
+	if err := c.validate(registry, os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	notifiers := make(map[string]Notifier, len(c.Notifiers))
+	for _, name := range sortedKeys(c.Notifiers) {
+		n, err := c.Notifiers[name].build(joinConfigPath("notifiers", name), registry)
+		if err != nil {
+			return nil, err
+		}
+		notifiers[name] = n
+	}
+	return notifiers, nil
+}
+
+// BuildNotifier validates the config and constructs the named notifier
+func (c *Config) BuildNotifier(name string, registry *Registry) (Notifier, error) {
+	spec, ok := c.Notifiers[name]
+	if !ok {
+		return nil, &ConfigError{Path: joinConfigPath("notifiers", name), Reason: "not defined"}
+	}
+	if registry == nil {
+		registry = defaultRegistry
+	}
+	single := &Config{Notifiers: map[string]NotifierSpec{name: spec}}
+	if err := single.validate(registry, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return spec.build(joinConfigPath("notifiers", name), registry)
+}
+
+func (s NotifierSpec) build(path string, registry *Registry) (Notifier, error) {
+	vendors := s.Vendors
+	if s.Vendor != "" || len(vendors) == 0 {
+		vendors = []string{s.Vendor}
+	}
+
+	chain := make([]Notifier, 0, len(vendors))
+	for i, vendor := range vendors {
+		vendorPath := path + ".vendor"
+		if len(s.Vendors) > 0 {
+			vendorPath = fmt.Sprintf("%s.vendors[%d]", path, i)
+		}
+
+		n, err := s.newVendor(vendor, registry)
+		if err != nil {
+			return nil, &ConfigError{Path: vendorPath, Reason: err.Error()}
+		}
+		if s.RateLimit != nil {
+			n, err = NewRateLimitNotifier(n, RateLimitPolicy{
+				Limiter: s.RateLimit.limiter(),
+				Mode:    s.RateLimit.mode(),
+				MaxWait: time.Duration(s.RateLimit.MaxWait),
+				Key:     rateLimitKey(s.Channel, vendorName(n)),
+			})
+			if err != nil {
+				return nil, &ConfigError{Path: path + ".rate_limit", Reason: err.Error()}
+			}
+		}
+		chain = append(chain, n)
+	}
+
+	n := chain[0]
+	if len(chain) > 1 {
+		failover, err := NewFailoverNotifier(chain...)
+		if err != nil {
+			return nil, &ConfigError{Path: path + ".vendors", Reason: err.Error()}
+		}
+		n = failover
+	}
+	if s.Retry != nil {
+		n = NewRetryNotifier(n, RetryPolicy{
+			MaxAttempts: s.Retry.MaxAttempts,
+			BaseDelay:   time.Duration(s.Retry.BaseDelay),
+			MaxDelay:    time.Duration(s.Retry.MaxDelay),
+			Multiplier:  s.Retry.Multiplier,
+		})
+	}
+	return n, nil
+}
+
+// vendorNames returns the vendors the built pipeline reports, in order
+func (s NotifierSpec) vendorNames() []string {
+	if s.Vendor == "" && len(s.Vendors) > 0 {
+		return s.Vendors
+	}
+	if s.Vendor == "" && s.Webhook != nil {
+		if parsed, err := url.Parse(s.Webhook.URL); err == nil {
+			return []string{parsed.Host}
+		}
+	}
+	return []string{s.Vendor}
+}
+
+func (s NotifierSpec) newVendor(vendor string, registry *Registry) (Notifier, error) {
+	switch {
+	case s.SMTP != nil:
+		return NewSMTPEmailNotifier(vendor, SMTPConfig{
+			Host:     s.SMTP.Host,
+			Username: s.SMTP.Username,
+			Password: secretValue(s.SMTP.Password, s.SMTP.PasswordEnv),
+			From:     s.SMTP.From,
+			To:       s.SMTP.To,
+			Subject:  s.SMTP.Subject,
+			StartTLS: s.SMTP.StartTLS,
+			Timeout:  time.Duration(s.SMTP.Timeout),
+		}), nil
+	case s.Webhook != nil:
+		if vendor == "" {
+			parsed, _ := url.Parse(s.Webhook.URL)
+			vendor = parsed.Host
+		}
+		return NewWebhookNotifier(vendor, s.Webhook.URL, secretValue(s.Webhook.Secret, s.Webhook.SecretEnv)), nil
+	}
+	n, err := registry.New(s.Channel, vendor)
+	if sms, ok := n.(*SmsNotifier); ok && s.SMS != nil {
+		s.SMS.apply(sms)
+	}
+	return n, err
+}
+
+// SMSNotifier returns an SmsNotifier for the pipeline's first vendor with
+// its sms settings, so a message can be planned without sending it
+func (s NotifierSpec) SMSNotifier() (*SmsNotifier, error) {
+	if s.Channel != "sms" {
+		return nil, fmt.Errorf("config: channel %q is not sms", s.Channel)
+	}
+	vendor := s.Vendor
+	if vendor == "" && len(s.Vendors) > 0 {
+		vendor = s.Vendors[0]
+	}
+	n := NewSmsNotifier(vendor)
+	if s.SMS != nil {
+		s.SMS.apply(n)
+	}
+	return n, nil
+}
+
+// apply copies the settings onto n
+func (s *SMSSpec) apply(n *SmsNotifier) {
+	switch s.Unicode {
+	case "transliterate":
+		n.Unicode = SMSTransliterate
+	case "reject":
+		n.Unicode = SMSRejectUnicode
+	default:
+		n.Unicode = SMSAllowUnicode
+	}
+	n.MaxSegments = s.MaxSegments
+	n.PricePerSegment = s.PricePerSegment
+}
+
+func secretValue(inline, env string) string {
+	if env != "" {
+		return os.Getenv(env)
+	}
+	return inline
+}
+
+func (r *RateLimitSpec) limiter() Limiter {
+	per := time.Duration(r.Per)
+	if per == 0 {
+		per = time.Second
+	}
+	burst := r.Burst
+	if burst == 0 {
+		burst = r.Limit
+	}
+	switch r.Algorithm {
+	case "leaky_bucket":
+		return NewLeakyBucket(r.Limit, per, burst, nil)
+	case "sliding_window":
+		return NewSlidingWindow(r.Limit, per, nil)
+	}
+	return NewTokenBucket(r.Limit, per, burst, nil)
+}
+
+func (r *RateLimitSpec) mode() RateLimitMode {
+	if r.Mode == "reject" {
+		return RateLimitReject
+	}
+	return RateLimitWait
+}
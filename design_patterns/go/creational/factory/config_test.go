@@ -0,0 +1,405 @@
+package factory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfigYAML = `
+# Notifier pipelines
+notifiers:
+  alerts:
+    channel: sms
+    vendors: [twilio, nexmo]   # failover order
+    retry:
+      max_attempts: 4
+      base_delay: 200ms
+      max_delay: 5s
+    rate_limit:
+      algorithm: sliding_window
+      limit: 10
+      per: 1s
+      mode: reject
+  ops:
+    channel: email
+    vendor: sendgrid
+    smtp:
+      host: "smtp.example.com:587"
+      username: alerts
+      password_env: TEST_SMTP_PASSWORD
+      from: alerts@example.com
+      to:
+        - oncall@example.com
+        - 'ops # team@example.com'
+      start_tls: true
+  hooks:
+    channel: webhook
+    webhook:
+      url: https://hooks.example.com/notify
+      secret_env: TEST_WEBHOOK_SECRET
+`
+
+const testConfigJSON = `{
+  "notifiers": {
+    "alerts": {
+      "channel": "sms",
+      "vendors": ["twilio", "nexmo"],
+      "retry": {"max_attempts": 4, "base_delay": "200ms", "max_delay": "5s"},
+      "rate_limit": {"algorithm": "sliding_window", "limit": 10, "per": "1s", "mode": "reject"}
+    },
+    "ops": {
+      "channel": "email",
+      "vendor": "sendgrid",
+      "smtp": {
+        "host": "smtp.example.com:587",
+        "username": "alerts",
+        "password_env": "TEST_SMTP_PASSWORD",
+        "from": "alerts@example.com",
+        "to": ["oncall@example.com", "ops # team@example.com"],
+        "start_tls": true
+      }
+    },
+    "hooks": {
+      "channel": "webhook",
+      "webhook": {"url": "https://hooks.example.com/notify", "secret_env": "TEST_WEBHOOK_SECRET"}
+    }
+  }
+}`
+
+func setTestConfigEnv(t *testing.T) {
+	t.Setenv("TEST_SMTP_PASSWORD", "smtp-secret")
+	t.Setenv("TEST_WEBHOOK_SECRET", "hook-secret")
+}
+
+// configErrorPaths returns the path of every ConfigError in err
+func configErrorPaths(err error) []string {
+	var paths []string
+	var walk func(error)
+	walk = func(err error) {
+		var configErr *ConfigError
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				walk(e)
+			}
+		} else if errors.As(err, &configErr) {
+			paths = append(paths, configErr.Path)
+		}
+	}
+	walk(err)
+	return paths
+}
+
+// ============================================================================
+// CONFIG TESTS
+// ============================================================================
+
+func TestParseConfig_YAMLMatchesJSON(t *testing.T) {
+	fromYAML, err := ParseConfig([]byte(testConfigYAML), "yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fromJSON, err := ParseConfig([]byte(testConfigJSON), "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		a, _ := json.Marshal(fromYAML)
+		b, _ := json.Marshal(fromJSON)
+		t.Errorf("expected YAML and JSON to decode alike:\n%s\n%s", a, b)
+	}
+	if got := fromYAML.Notifiers["alerts"].Retry.BaseDelay; time.Duration(got) != 200*time.Millisecond {
+		t.Errorf("expected base_delay 200ms, got %v", got)
+	}
+}
+
+func TestConfig_BuildWrapsPipeline(t *testing.T) {
+	setTestConfigEnv(t)
+	config, _ := ParseConfig([]byte(testConfigYAML), "yaml")
+
+	notifiers, err := config.Build(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	retry, ok := notifiers["alerts"].(*RetryNotifier)
+	if !ok {
+		t.Fatalf("expected alerts to be *RetryNotifier, got %T", notifiers["alerts"])
+	}
+	if retry.policy.MaxAttempts != 4 || retry.policy.MaxDelay != 5*time.Second {
+		t.Errorf("unexpected retry policy: %+v", retry.policy)
+	}
+	failover, ok := retry.next.(*FailoverNotifier)
+	if !ok || !reflect.DeepEqual(failover.names, []string{"twilio", "nexmo"}) {
+		t.Fatalf("expected failover over twilio and nexmo, got %T", retry.next)
+	}
+	limited, ok := failover.vendors[1].(*RateLimitNotifier)
+	if !ok || limited.policy.Key != "sms/nexmo" || limited.policy.Mode != RateLimitReject {
+		t.Errorf("expected a rejecting limiter per vendor, got %T", failover.vendors[1])
+	}
+	if _, ok := limited.policy.Limiter.(*SlidingWindow); !ok {
+		t.Errorf("expected sliding window limiter, got %T", limited.policy.Limiter)
+	}
+
+	email, ok := notifiers["ops"].(*EmailNotifier)
+	if !ok || email.SMTP == nil || email.SMTP.Password != "smtp-secret" || !email.SMTP.StartTLS {
+		t.Errorf("expected SMTP email notifier with password from env, got %+v", notifiers["ops"])
+	}
+
+	hook, ok := notifiers["hooks"].(*WebhookNotifier)
+	if !ok || hook.Secret != "hook-secret" || hook.VendorName() != "hooks.example.com" {
+		t.Errorf("expected signed webhook notifier, got %+v", notifiers["hooks"])
+	}
+}
+
+func TestLoadConfig_ByExtension(t *testing.T) {
+	setTestConfigEnv(t)
+	dir := t.TempDir()
+	for name, content := range map[string]string{"notify.yml": testConfigYAML, "notify.json": testConfigJSON} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0o644)
+
+		config, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if _, err := config.BuildNotifier("alerts", nil); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+}
+
+func TestConfig_BuildNotifierIgnoresOthers(t *testing.T) {
+	// hooks needs TEST_WEBHOOK_SECRET, which is not set
+	config, _ := ParseConfig([]byte(testConfigYAML), "yaml")
+
+	if _, err := config.BuildNotifier("alerts", nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestConfig_CustomRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("slack", func(vendor string) (Notifier, error) { return &scriptedNotifier{vendor: vendor}, nil })
+	config, err := ParseConfig([]byte(`{"notifiers": {"team": {"channel": "slack", "vendor": "acme"}}}`), "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	notifiers, err := config.Build(registry)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vendorName(notifiers["team"]) != "acme" {
+		t.Errorf("expected acme, got %s", vendorName(notifiers["team"]))
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestParseConfig_DecodeErrorsNamePath(t *testing.T) {
+	testCases := []struct {
+		name   string
+		format string
+		doc    string
+		path   string
+		reason string
+	}{
+		{"unknown field", "yaml", "notifiers:\n  a:\n    channel: sms\n    retri:\n      max_attempts: 3\n", "notifiers.a.retri", "unknown field"},
+		{"wrong type", "json", `{"notifiers": {"a": {"retry": {"max_attempts": "three"}}}}`, "notifiers.a.retry.max_attempts", `expected an integer, got string "three"`},
+		{"bad duration", "yaml", "notifiers:\n  a:\n    rate_limit:\n      per: soon\n", "notifiers.a.rate_limit.per", "invalid duration"},
+		{"list item", "yaml", "notifiers:\n  a:\n    vendors:\n      - twilio\n      - 42\n", "notifiers.a.vendors[1]", "expected a string, got number 42"},
+		{"not a mapping", "json", `{"notifiers": ["a"]}`, "notifiers", "expected a mapping, got a list"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(tc.doc), tc.format)
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) {
+				t.Fatalf("expected *ConfigError, got %v", err)
+			}
+			if configErr.Path != tc.path || !strings.Contains(configErr.Reason, tc.reason) {
+				t.Errorf("expected %s: %s, got %v", tc.path, tc.reason, err)
+			}
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Error("expected error to match ErrInvalidConfig")
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateReportsEveryPath(t *testing.T) {
+	doc := `
+notifiers:
+  a:
+    channel: fax
+    vendor: acme
+  b:
+    channel: sms
+    vendor: twilio
+    vendors: [nexmo]
+  c:
+    channel: sms
+    smtp:
+      host: smtp.example.com:25
+      from: a@example.com
+    retry:
+      max_attempts: -1
+      base_delay: 2s
+      max_delay: 1s
+    rate_limit:
+      algorithm: fixed_window
+      mode: drop
+  d:
+    channel: webhook
+    webhook:
+      url: ftp://example.com
+      secret: inline
+      secret_env: ALSO
+  e:
+    channel: email
+    vendor: ses
+    smtp:
+      host: smtp.example.com:25
+      from: a@example.com
+      password_env: TEST_CONFIG_UNSET_VARIABLE
+`
+	config, err := ParseConfig([]byte(doc), "yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = config.Validate()
+	want := []string{
+		"notifiers.a.channel",
+		"notifiers.b",
+		"notifiers.c.vendor",
+		"notifiers.c.smtp",
+		"notifiers.c.retry.max_attempts",
+		"notifiers.c.retry.max_delay",
+		"notifiers.c.rate_limit.algorithm",
+		"notifiers.c.rate_limit.limit",
+		"notifiers.c.rate_limit.mode",
+		"notifiers.d.webhook.url",
+		"notifiers.d.webhook",
+		"notifiers.e.smtp.password_env",
+	}
+	if got := configErrorPaths(err); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected paths:\n%v\ngot:\n%v\n(%v)", want, got, err)
+	}
+	if !strings.Contains(err.Error(), "environment variable TEST_CONFIG_UNSET_VARIABLE is not set") {
+		t.Errorf("expected missing variable to be named, got %v", err)
+	}
+
+	if _, err := config.Build(nil); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected Build to validate, got %v", err)
+	}
+}
+
+func TestParseConfig_SyntaxErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		format string
+		doc    string
+		want   string
+	}{
+		{"bad indentation", "yaml", "notifiers:\n  a:\n    channel: sms\n      vendor: x\n", "line 4: unexpected indentation"},
+		{"duplicate key", "yaml", "notifiers:\n  a:\n    channel: sms\n    channel: email\n", `line 4: duplicate key "channel"`},
+		{"tab", "yaml", "notifiers:\n\ta: {}\n", "line 2: tabs"},
+		{"block scalar", "yaml", "notifiers:\n  a:\n    channel: |\n", "line 3: block scalars"},
+		{"trailing json", "json", `{"notifiers": {}} {}`, "unexpected data"},
+		{"format", "toml", "", `unsupported format "toml"`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(tc.doc), tc.format)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestConfig_WebhookSecretRequired(t *testing.T) {
+	t.Setenv("TEST_EMPTY_WEBHOOK_SECRET", "")
+	testCases := []struct {
+		name    string
+		webhook string
+		want    string
+	}{
+		{"no secret", `{"url": "https://hooks.example.com"}`, "is required, inline or as secret_env"},
+		{"empty variable", `{"url": "https://hooks.example.com", "secret_env": "TEST_EMPTY_WEBHOOK_SECRET"}`, "TEST_EMPTY_WEBHOOK_SECRET is empty"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := ParseConfig([]byte(`{"notifiers": {"hooks": {"channel": "webhook", "webhook": `+tc.webhook+`}}}`), "json")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, err = config.BuildNotifier("hooks", nil)
+			if got := configErrorPaths(err); !reflect.DeepEqual(got, []string{"notifiers.hooks.webhook.secret"}) || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected notifiers.hooks.webhook.secret to be required, got %v", err)
+			}
+		})
+	}
+}
+
+func TestConfig_SMSSettings(t *testing.T) {
+	config, err := ParseConfig([]byte(`notifiers:
+  alerts:
+    channel: sms
+    vendors: [twilio, nexmo]
+    sms:
+      unicode: reject
+      max_segments: 2
+      price_per_segment: 0.0075
+`), "yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	notifier, err := config.BuildNotifier("alerts", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := SendMessage(context.Background(), notifier, Message{To: []string{"+15551234567"}, Body: "Hi 😀"}); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("expected every vendor to reject unicode, got %v", err)
+	}
+
+	sms, err := config.Notifiers["alerts"].SMSNotifier()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sms.Vendor != "twilio" || sms.Unicode != SMSRejectUnicode || sms.MaxSegments != 2 || sms.PricePerSegment != 0.0075 {
+		t.Errorf("expected twilio with the sms settings, got %+v", sms)
+	}
+}
+
+func TestConfig_InvalidSMSSettings(t *testing.T) {
+	config, err := ParseConfig([]byte(`{"notifiers": {
+		"a": {"channel": "sms", "vendor": "twilio", "sms": {"unicode": "drop", "max_segments": -1, "price_per_segment": -0.1}},
+		"b": {"channel": "email", "vendor": "ses", "sms": {}}}}`), "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"notifiers.a.sms.unicode", "notifiers.a.sms.max_segments", "notifiers.a.sms.price_per_segment", "notifiers.b.sms"}
+	if got := configErrorPaths(config.Validate()); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected paths %v, got %v", want, got)
+	}
+	if _, err := config.Notifiers["b"].SMSNotifier(); err == nil {
+		t.Error("expected SMSNotifier to refuse an email pipeline")
+	}
+}
+
+func TestConfig_BuildNotifierUndefined(t *testing.T) {
+	config, _ := ParseConfig([]byte(testConfigYAML), "yaml")
+
+	var configErr *ConfigError
+	if _, err := config.BuildNotifier("missing", nil); !errors.As(err, &configErr) || configErr.Path != "notifiers.missing" {
+		t.Errorf("expected notifiers.missing to be reported, got %v", err)
+	}
+}
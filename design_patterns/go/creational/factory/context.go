@@ -0,0 +1,73 @@
+package factory
+
+import (
+	"context"
+	"errors"
+)
+
+// ContextNotifier is a Notifier that honours deadlines and cancellation.
+// All built-in notifiers and wrappers implement it.
+type ContextNotifier interface {
+	Notifier
+	SendContext(ctx context.Context, message string) error
+}
+
+// WithContext adapts a legacy Notifier to ContextNotifier.
+// Notifiers that already implement ContextNotifier are returned unchanged.
+func WithContext(n Notifier) ContextNotifier {
+	if cn, ok := n.(ContextNotifier); ok {
+		return cn
+	}
+	return &contextAdapter{next: n}
+}
+
+// contextAdapter runs a legacy Send in a goroutine so the caller can stop
+// waiting when ctx ends. The legacy send itself cannot be interrupted and
+// finishes in the background.
+type contextAdapter struct {
+	next Notifier
+}
+
+func (a *contextAdapter) Send(msg string) error {
+	return a.next.Send(msg)
+}
+
+func (a *contextAdapter) SendContext(ctx context.Context, msg string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- a.next.Send(msg)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// VendorName passes through the wrapped notifier's vendor
+func (a *contextAdapter) VendorName() string {
+	return vendorName(a.next)
+}
+
+// SendWithContext sends msg through n, using SendContext when n supports it.
+// Legacy notifiers are called directly once ctx has been checked.
+func SendWithContext(ctx context.Context, n Notifier, msg string) error {
+	if cn, ok := n.(ContextNotifier); ok {
+		return cn.SendContext(ctx, msg)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return n.Send(msg)
+}
+
+// isContextError reports whether err came from a cancelled or expired context
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
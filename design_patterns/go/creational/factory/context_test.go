@@ -0,0 +1,203 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// blockingNotifier blocks in SendContext until ctx ends and reports why it stopped
+type blockingNotifier struct {
+	started  chan string
+	released chan error
+}
+
+func newBlockingNotifier() *blockingNotifier {
+	return &blockingNotifier{started: make(chan string, 10), released: make(chan error, 10)}
+}
+
+func (b *blockingNotifier) Send(msg string) error {
+	return b.SendContext(context.Background(), msg)
+}
+
+func (b *blockingNotifier) SendContext(ctx context.Context, msg string) error {
+	b.started <- msg
+	<-ctx.Done()
+	b.released <- ctx.Err()
+	return ctx.Err()
+}
+
+// Compile-time checks that built-ins and wrappers honour context
+var (
+	_ ContextNotifier = (*EmailNotifier)(nil)
+	_ ContextNotifier = (*SmsNotifier)(nil)
+	_ ContextNotifier = (*PushNotifier)(nil)
+	_ ContextNotifier = (*WebhookNotifier)(nil)
+	_ ContextNotifier = (*RetryNotifier)(nil)
+	_ ContextNotifier = (*FailoverNotifier)(nil)
+	_ ContextNotifier = (*Dispatcher)(nil)
+)
+
+// ============================================================================
+// CONTEXT TESTS
+// ============================================================================
+
+func TestWithContext_ReturnsContextNotifierUnchanged(t *testing.T) {
+	sms := &SmsNotifier{Vendor: "twilio"}
+	if got := WithContext(sms); got != ContextNotifier(sms) {
+		t.Errorf("expected the notifier itself, got %T", got)
+	}
+}
+
+func TestWithContext_AdapterDelegates(t *testing.T) {
+	inner := &scriptedNotifier{vendor: "legacy"}
+	adapted := WithContext(inner)
+
+	if err := adapted.SendContext(context.Background(), "hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if inner.Calls() != 1 {
+		t.Errorf("expected 1 call, got %d", inner.Calls())
+	}
+	if name := vendorName(adapted); name != "legacy" {
+		t.Errorf("expected vendor 'legacy', got '%s'", name)
+	}
+}
+
+func TestWithContext_AdapterStopsWaitingOnCancel(t *testing.T) {
+	inner := newGatedNotifier()
+	defer close(inner.gate)
+	adapted := WithContext(inner)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- adapted.SendContext(ctx, "slow") }()
+
+	<-inner.started
+	cancel()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected SendContext to return after cancel")
+	}
+}
+
+func TestSendWithContext_PrefersSendContext(t *testing.T) {
+	inner := newBlockingNotifier()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := SendWithContext(ctx, inner, "hello"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestWebhookNotifier_CancelAbortsRequest(t *testing.T) {
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		close(arrived)
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	notifier := NewWebhookNotifier("billing", server.URL, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-arrived
+		cancel()
+	}()
+
+	err := notifier.SendContext(ctx, "invoice paid")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestEmailNotifier_CancelAbortsSMTPSession(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	// Accept the connection but never send a greeting
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	notifier := NewSMTPEmailNotifier("postfix", SMTPConfig{
+		Host: listener.Addr().String(),
+		From: "alerts@example.com",
+		To:   []string{"ops@example.com"},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		conn := <-accepted
+		defer conn.Close()
+		cancel()
+	}()
+
+	result := make(chan error, 1)
+	go func() { result <- notifier.SendContext(ctx, "disk full") }()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected cancel to abort the stalled SMTP session")
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestBuiltins_RejectCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	notifiers := []ContextNotifier{
+		&EmailNotifier{Vendor: "gmail"},
+		&SmsNotifier{Vendor: "twilio"},
+		&PushNotifier{Vendor: "firebase"},
+		NewWebhookNotifier("billing", "http://127.0.0.1:1", ""),
+	}
+	for _, n := range notifiers {
+		if err := n.SendContext(ctx, "hello"); !errors.Is(err, context.Canceled) {
+			t.Errorf("%T: expected context.Canceled, got %v", n, err)
+		}
+	}
+}
+
+func TestSendWithContext_LegacySkippedWhenCancelled(t *testing.T) {
+	inner := &scriptedNotifier{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := SendWithContext(ctx, inner, "hello"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if inner.Calls() != 0 {
+		t.Errorf("expected legacy notifier not to be called, got %d calls", inner.Calls())
+	}
+}
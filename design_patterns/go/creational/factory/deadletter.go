@@ -0,0 +1,572 @@
+package factory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDeadLetterNotFound is returned for an ID that is not in the store
+	ErrDeadLetterNotFound = errors.New("deadletter: not found")
+	// ErrDeadLettersClosed is returned by a closed DeadLetterStore
+	ErrDeadLettersClosed = errors.New("deadletter: closed")
+	// ErrInvalidDeadLetter is returned by Add for a letter that could never
+	// be replayed, such as one whose vendor is a Go type name
+	ErrInvalidDeadLetter = errors.New("deadletter: invalid dead letter")
+	// ErrNotReplayable is returned by Replay when the letter's original
+	// transport cannot be rebuilt
+	ErrNotReplayable = errors.New("deadletter: cannot rebuild the original transport")
+	// ErrReplayInProgress is returned by Replay for a letter another
+	// Replay is already sending
+	ErrReplayInProgress = errors.New("deadletter: replay already in progress")
+)
+
+// goTypeName matches the "%T" names vendorName falls back to, such as
+// "*factory.FailoverNotifier"
+var goTypeName = regexp.MustCompile(`^[*\[\]]*[a-z_][a-zA-Z0-9_]*\.[A-Z][a-zA-Z0-9_]*$`)
+
+// DeadLetter is a message that could not be delivered
+type DeadLetter struct {
+	ID       uint64  `json:"id"`
+	Channel  string  `json:"channel"`
+	Vendor   string  `json:"vendor"`             // the vendor that failed, empty when unknown
+	Pipeline string  `json:"pipeline,omitempty"` // Config notifier name the message was sent through
+	Message  Message `json:"message"`
+
+	LastError      string    `json:"last_error"`
+	Attempts       int       `json:"attempts"` // including replays
+	FirstAttemptAt time.Time `json:"first_attempt_at"`
+	LastAttemptAt  time.Time `json:"last_attempt_at"`
+}
+
+// deadLetterRecord is one line of the store: a new dead letter, a failed
+// replay or a removal
+type deadLetterRecord struct {
+	Op     string      `json:"op"` // "add", "fail" or "del"
+	ID     uint64      `json:"id"`
+	Letter *DeadLetter `json:"letter,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	At     time.Time   `json:"at,omitempty"`
+}
+
+// DeadLetterStore is a file-backed store of undeliverable messages. Like
+// the Outbox it is an append-only JSON-lines log, compacted on open.
+type DeadLetterStore struct {
+	path  string
+	clock Clock
+
+	mu        sync.Mutex
+	file      *os.File
+	letters   map[uint64]DeadLetter
+	replaying map[uint64]bool // letters being sent by Replay
+	nextID    uint64
+}
+
+// OpenDeadLetters loads the store at path, creating it if needed
+func OpenDeadLetters(path string, clock Clock) (*DeadLetterStore, error) {
+	if clock == nil {
+		clock = SystemClock
+	}
+	s := &DeadLetterStore{path: path, clock: clock, letters: make(map[uint64]DeadLetter), replaying: make(map[uint64]bool), nextID: 1}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compactLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *DeadLetterStore) load() error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("deadletter: open %s: %w", s.path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	var torn error
+	for line := 1; scanner.Scan(); line++ {
+		if torn != nil {
+			return torn
+		}
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record deadLetterRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// Only a torn final line is forgiven, as in the outbox
+			torn = fmt.Errorf("deadletter: %s line %d: %w", s.path, line, err)
+			continue
+		}
+		switch record.Op {
+		case "add":
+			if record.Letter == nil {
+				return fmt.Errorf("deadletter: %s line %d: add without letter", s.path, line)
+			}
+			s.letters[record.ID] = *record.Letter
+		case "fail":
+			if letter, ok := s.letters[record.ID]; ok {
+				s.letters[record.ID] = letter.failed(record.Error, record.At)
+			}
+		case "del":
+			delete(s.letters, record.ID)
+		default:
+			return fmt.Errorf("deadletter: %s line %d: unknown op %q", s.path, line, record.Op)
+		}
+		if record.ID >= s.nextID {
+			s.nextID = record.ID + 1
+		}
+	}
+	return scanner.Err()
+}
+
+// failed returns the letter updated with another failed attempt
+func (d DeadLetter) failed(errText string, at time.Time) DeadLetter {
+	d.Attempts++
+	d.LastError = errText
+	d.LastAttemptAt = at
+	return d
+}
+
+func (s *DeadLetterStore) write(record deadLetterRecord, sync bool) error {
+	if s.file == nil {
+		return ErrDeadLettersClosed
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("deadletter: encode record: %w", err)
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("deadletter: write %s: %w", s.path, err)
+	}
+	if sync {
+		if err := s.file.Sync(); err != nil {
+			return fmt.Errorf("deadletter: sync %s: %w", s.path, err)
+		}
+	}
+	return nil
+}
+
+// Add durably stores a dead letter and returns its assigned ID. Zero
+// timestamps default to now and a zero attempt count to 1. A vendor that
+// is a Go type name is rejected with ErrInvalidDeadLetter.
+func (s *DeadLetterStore) Add(letter DeadLetter) (uint64, error) {
+	if goTypeName.MatchString(letter.Vendor) {
+		return 0, fmt.Errorf("%w: vendor %q is a Go type, not a vendor", ErrInvalidDeadLetter, letter.Vendor)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	letter.ID = s.nextID
+	if letter.Attempts <= 0 {
+		letter.Attempts = 1
+	}
+	if letter.LastAttemptAt.IsZero() {
+		letter.LastAttemptAt = now
+	}
+	if letter.FirstAttemptAt.IsZero() {
+		letter.FirstAttemptAt = letter.LastAttemptAt
+	}
+	if err := s.write(deadLetterRecord{Op: "add", ID: letter.ID, Letter: &letter}, true); err != nil {
+		return 0, err
+	}
+	s.nextID++
+	s.letters[letter.ID] = letter
+	return letter.ID, nil
+}
+
+// List returns every dead letter, oldest first
+func (s *DeadLetterStore) List() []DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letters := make([]DeadLetter, 0, len(s.letters))
+	for _, letter := range s.letters {
+		letters = append(letters, letter)
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i].ID < letters[j].ID })
+	return letters
+}
+
+// Get returns one dead letter
+func (s *DeadLetterStore) Get(id uint64) (DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letter, ok := s.letters[id]
+	if !ok {
+		return DeadLetter{}, fmt.Errorf("%w: %d", ErrDeadLetterNotFound, id)
+	}
+	return letter, nil
+}
+
+// Len returns the number of dead letters
+func (s *DeadLetterStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.letters)
+}
+
+// Purge deletes the given dead letters. Unknown IDs are reported after the
+// known ones are deleted.
+func (s *DeadLetterStore) Purge(ids ...uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return ErrDeadLettersClosed
+	}
+	var errs []error
+	for _, id := range ids {
+		if _, ok := s.letters[id]; !ok {
+			errs = append(errs, fmt.Errorf("%w: %d", ErrDeadLetterNotFound, id))
+			continue
+		}
+		if err := s.write(deadLetterRecord{Op: "del", ID: id}, false); err != nil {
+			return err
+		}
+		delete(s.letters, id)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("deadletter: sync %s: %w", s.path, err)
+	}
+	return errors.Join(errs...)
+}
+
+// PurgeAll deletes every dead letter and returns how many there were
+func (s *DeadLetterStore) PurgeAll() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return 0, ErrDeadLettersClosed
+	}
+	n := len(s.letters)
+	clear(s.letters)
+	return n, s.compactLocked()
+}
+
+// ReplayBuilder rebuilds the notifier a dead letter should be sent through
+// again. It must return ErrNotReplayable rather than a notifier that would
+// not reach the original transport.
+type ReplayBuilder func(letter DeadLetter) (Notifier, error)
+
+// ConfigReplayBuilder rebuilds letters through config: the letter's
+// Pipeline, or else the one pipeline on its channel that uses its vendor.
+// Vendors are built with registry, or the default registry when nil.
+func ConfigReplayBuilder(config *Config, registry *Registry) ReplayBuilder {
+	return func(letter DeadLetter) (Notifier, error) {
+		name := letter.Pipeline
+		if name == "" {
+			var matches []string
+			for _, candidate := range sortedKeys(config.Notifiers) {
+				spec := config.Notifiers[candidate]
+				if spec.Channel == letter.Channel && (letter.Vendor == "" || slices.Contains(spec.vendorNames(), letter.Vendor)) {
+					matches = append(matches, candidate)
+				}
+			}
+			if len(matches) != 1 {
+				return nil, fmt.Errorf("%w: %d config pipelines match %s/%s", ErrNotReplayable, len(matches), letter.Channel, letter.Vendor)
+			}
+			name = matches[0]
+		}
+		if spec, ok := config.Notifiers[name]; ok && spec.Channel != letter.Channel {
+			return nil, fmt.Errorf("%w: pipeline %q sends %s, not %s", ErrNotReplayable, name, spec.Channel, letter.Channel)
+		}
+		return config.BuildNotifier(name, registry)
+	}
+}
+
+// RegistryReplayBuilder rebuilds letters from their channel and vendor with
+// registry, or the default registry when nil. It refuses the built-in
+// notifiers that only log without their SMTP or push configuration, since
+// that configuration is not part of the letter.
+func RegistryReplayBuilder(registry *Registry) ReplayBuilder {
+	if registry == nil {
+		registry = defaultRegistry
+	}
+	return func(letter DeadLetter) (Notifier, error) {
+		if letter.Vendor == "" {
+			return nil, fmt.Errorf("%w: no vendor recorded", ErrNotReplayable)
+		}
+		n, err := registry.New(letter.Channel, letter.Vendor)
+		if err != nil {
+			return nil, err
+		}
+		if stub, ok := n.(interface{ logsOnly() bool }); ok && stub.logsOnly() {
+			return nil, fmt.Errorf("%w: %s/%s would only be logged, replay through its config pipeline",
+				ErrNotReplayable, letter.Channel, letter.Vendor)
+		}
+		return n, nil
+	}
+}
+
+// Replay sends a dead letter again through the notifier build returns for
+// it. A delivered letter is removed; a failed one stays with its attempt
+// count, error and timestamp updated. A letter build cannot rebuild is
+// left untouched. A letter is replayed by one caller at a time; the others
+// get ErrReplayInProgress.
+func (s *DeadLetterStore) Replay(ctx context.Context, id uint64, build ReplayBuilder) error {
+	letter, err := s.claim(id)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		s.mu.Lock()
+		delete(s.replaying, id)
+		s.mu.Unlock()
+	}()
+	if build == nil {
+		return fmt.Errorf("deadletter: replay %d: %w: no builder", id, ErrNotReplayable)
+	}
+	n, err := build(letter)
+	if err != nil {
+		return fmt.Errorf("deadletter: replay %d: %w", id, err)
+	}
+	sendErr := SendMessage(ctx, n, letter.Message)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.letters[id]; !ok {
+		return sendErr // purged while sending
+	}
+	if sendErr == nil {
+		if err := s.write(deadLetterRecord{Op: "del", ID: id}, true); err != nil {
+			return err
+		}
+		delete(s.letters, id)
+		return nil
+	}
+	at := s.clock.Now()
+	if err := s.write(deadLetterRecord{Op: "fail", ID: id, Error: sendErr.Error(), At: at}, true); err != nil {
+		return errors.Join(sendErr, err)
+	}
+	s.letters[id] = letter.failed(sendErr.Error(), at)
+	return fmt.Errorf("deadletter: replay %d: %w", id, sendErr)
+}
+
+// claim returns a letter and marks it as being replayed
+func (s *DeadLetterStore) claim(id uint64) (DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letter, ok := s.letters[id]
+	if !ok {
+		return DeadLetter{}, fmt.Errorf("%w: %d", ErrDeadLetterNotFound, id)
+	}
+	if s.replaying[id] {
+		return DeadLetter{}, fmt.Errorf("%w: %d", ErrReplayInProgress, id)
+	}
+	s.replaying[id] = true
+	return letter, nil
+}
+
+func (s *DeadLetterStore) compactLocked() error {
+	ids := make([]uint64, 0, len(s.letters))
+	for id := range s.letters {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("deadletter: compact: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, id := range ids {
+		letter := s.letters[id]
+		if err := enc.Encode(deadLetterRecord{Op: "add", ID: id, Letter: &letter}); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return fmt.Errorf("deadletter: compact: %w", err)
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("deadletter: compact: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("deadletter: compact: %w", err)
+	}
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("deadletter: reopen %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Close closes the store file
+func (s *DeadLetterStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// DeadLetterError is returned by DeadLetterNotifier once a failed message
+// has been stored. It is marked permanent so outer layers, such as a
+// Dispatcher with an Outbox, do not retry it as well.
+type DeadLetterError struct {
+	ID  uint64
+	Err error
+}
+
+func (e *DeadLetterError) Error() string {
+	return fmt.Sprintf("dead-lettered as %d: %v", e.ID, e.Err)
+}
+
+func (e *DeadLetterError) Unwrap() error {
+	return e.Err
+}
+
+// DeadLetterNotifier stores messages its wrapped notifier fails to send.
+// Wrap it around a RetryNotifier so only messages that exhausted their
+// retries, or failed permanently, are stored.
+type DeadLetterNotifier struct {
+	// Pipeline is recorded with each letter so ConfigReplayBuilder can
+	// rebuild the whole pipeline; set it when next was built from a Config
+	Pipeline string
+
+	next    Notifier
+	channel string
+	store   *DeadLetterStore
+}
+
+// NewDeadLetterNotifier wraps next, which sends on channel, with store
+func NewDeadLetterNotifier(next Notifier, channel string, store *DeadLetterStore) *DeadLetterNotifier {
+	return &DeadLetterNotifier{next: next, channel: channel, store: store}
+}
+
+// VendorName passes through the wrapped notifier's vendor
+func (n *DeadLetterNotifier) VendorName() string {
+	return vendorName(n.next)
+}
+
+// Send sends msg, dead-lettering it on failure
+func (n *DeadLetterNotifier) Send(msg string) error {
+	return n.SendContext(context.Background(), msg)
+}
+
+// SendContext is Send bounded by ctx
+func (n *DeadLetterNotifier) SendContext(ctx context.Context, msg string) error {
+	return n.SendMessage(ctx, Message{Body: msg})
+}
+
+// SendMessage sends msg and stores it if the send fails. Only the
+// recipients a PartialFailure reports as undelivered are stored, so a
+// replay does not reach the others twice. Cancellation is returned as is:
+// the caller gave up, the message did not fail.
+func (n *DeadLetterNotifier) SendMessage(ctx context.Context, msg Message) error {
+	start := n.store.clock.Now()
+	err := SendMessage(ctx, n.next, msg)
+	if err == nil || isContextError(err) {
+		return err
+	}
+
+	attempts := 1
+	var retryErr *RetryError
+	if errors.As(err, &retryErr) {
+		attempts = retryErr.Attempts
+	}
+	if failed, ok := undelivered(err); ok {
+		msg.To = narrowRecipients(msg.To, failed)
+	}
+	id, storeErr := n.store.Add(DeadLetter{
+		Channel:        n.channel,
+		Vendor:         failedVendor(n.next, err),
+		Pipeline:       n.Pipeline,
+		Message:        msg,
+		LastError:      err.Error(),
+		Attempts:       attempts,
+		FirstAttemptAt: start,
+	})
+	if storeErr != nil {
+		return errors.Join(err, storeErr)
+	}
+	return Permanent(&DeadLetterError{ID: id, Err: err})
+}
+
+// undelivered collects the recipients of every PartialFailure in err. It
+// returns false when any part of err is a failure of the whole send, such
+// as a RetryError whose last attempt failed outright.
+func undelivered(err error) ([]string, bool) {
+	switch e := err.(type) {
+	case PartialFailure:
+		return e.Failed(), true
+	case interface{ Unwrap() []error }:
+		var failed []string
+		for _, inner := range e.Unwrap() {
+			recipients, ok := undelivered(inner)
+			if !ok {
+				return nil, false
+			}
+			failed = append(failed, recipients...)
+		}
+		return failed, true
+	case interface{ Unwrap() error }:
+		return undelivered(e.Unwrap())
+	}
+	return nil, false
+}
+
+// narrowRecipients keeps the recipients in to that are also in failed,
+// in their original order. to is returned unchanged when nothing matches.
+func narrowRecipients(to, failed []string) []string {
+	var narrowed []string
+	for _, recipient := range to {
+		if slices.Contains(failed, recipient) && !slices.Contains(narrowed, recipient) {
+			narrowed = append(narrowed, recipient)
+		}
+	}
+	if len(narrowed) == 0 {
+		return to
+	}
+	return narrowed
+}
+
+// failedVendor names the vendor a failed send went through: the first
+// vendor a FailoverError tried, else the notifier's own vendor. It is
+// empty when neither names a real vendor.
+func failedVendor(n Notifier, err error) string {
+	var failover *FailoverError
+	if errors.As(err, &failover) && len(failover.Outcomes) > 0 {
+		return failover.Outcomes[0].Vendor
+	}
+	if vendor := vendorName(n); !goTypeName.MatchString(vendor) {
+		return vendor
+	}
+	return ""
+}
@@ -0,0 +1,347 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func openTestDeadLetters(t *testing.T, clock Clock) (*DeadLetterStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "deadletters.log")
+	store, err := OpenDeadLetters(path, clock)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, path
+}
+
+// ============================================================================
+// DEAD-LETTER TESTS
+// ============================================================================
+
+func TestDeadLetterNotifier_StoresExhaustedRetries(t *testing.T) {
+	clock := newFakeClock()
+	clock.autoAdvance = true
+	store, _ := openTestDeadLetters(t, clock)
+	inner := &scriptedNotifier{vendor: "twilio", errs: []error{errVendorDown, errVendorDown, errVendorDown}}
+	retry := NewRetryNotifier(inner, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, Clock: clock, Rand: noJitter})
+	notifier := NewDeadLetterNotifier(retry, "sms", store)
+
+	start := clock.Now()
+	msg := Message{To: []string{"+15551234567"}, Body: "Your code is 1234"}
+	err := notifier.SendMessage(context.Background(), msg)
+
+	var deadErr *DeadLetterError
+	if !errors.As(err, &deadErr) || !errors.Is(err, errVendorDown) || !IsPermanent(err) {
+		t.Fatalf("expected a permanent DeadLetterError wrapping the vendor error, got %v", err)
+	}
+	letter, err := store.Get(deadErr.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if letter.Channel != "sms" || letter.Vendor != "twilio" || letter.Attempts != 3 || letter.Message.Body != msg.Body {
+		t.Errorf("unexpected dead letter %+v", letter)
+	}
+	if !letter.FirstAttemptAt.Equal(start) || letter.LastAttemptAt.Sub(start) != 3*time.Second {
+		t.Errorf("expected attempts from %v to %v, got %v to %v",
+			start, start.Add(3*time.Second), letter.FirstAttemptAt, letter.LastAttemptAt)
+	}
+	if letter.LastError != "send failed after 3 attempt(s): vendor down" {
+		t.Errorf("unexpected last error %q", letter.LastError)
+	}
+}
+
+func TestDeadLetterNotifier_SuccessStoresNothing(t *testing.T) {
+	store, _ := openTestDeadLetters(t, newFakeClock())
+	notifier := NewDeadLetterNotifier(&scriptedNotifier{vendor: "twilio"}, "sms", store)
+
+	if err := notifier.Send("hello"); err != nil || store.Len() != 0 {
+		t.Errorf("expected no dead letters after a success, got %d (%v)", store.Len(), err)
+	}
+}
+
+func TestDeadLetterStore_SurvivesRestart(t *testing.T) {
+	clock := newFakeClock()
+	store, path := openTestDeadLetters(t, clock)
+	first, _ := store.Add(DeadLetter{Channel: "sms", Vendor: "twilio", Message: Message{Body: "one"}, LastError: "down"})
+	second, _ := store.Add(DeadLetter{Channel: "email", Vendor: "sendgrid", Message: Message{Body: "two"}, LastError: "down"})
+	store.Purge(first)
+	store.Close()
+
+	reopened, err := OpenDeadLetters(path, clock)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer reopened.Close()
+	letters := reopened.List()
+	if len(letters) != 1 || letters[0].ID != second || letters[0].Message.Body != "two" {
+		t.Fatalf("expected only the second letter after restart, got %+v", letters)
+	}
+	if id, _ := reopened.Add(DeadLetter{Message: Message{Body: "three"}}); id != second+1 {
+		t.Errorf("expected IDs to continue from %d, got %d", second+1, id)
+	}
+}
+
+func TestDeadLetterStore_Replay(t *testing.T) {
+	clock := newFakeClock()
+	store, path := openTestDeadLetters(t, clock)
+	registry := NewRegistry()
+	outcomes := []error{errVendorDown, nil}
+	var sent []string
+	registry.Register("sms", func(vendor string) (Notifier, error) {
+		err := outcomes[0]
+		outcomes = outcomes[1:]
+		n := &scriptedNotifier{vendor: vendor, errs: []error{err}}
+		return &sendHook{Notifier: n, after: func(msg string) { sent = append(sent, vendor+": "+msg) }}, nil
+	})
+	id, _ := store.Add(DeadLetter{Channel: "sms", Vendor: "twilio", Message: Message{Body: "retry me"}, Attempts: 3})
+
+	clock.Advance(time.Hour)
+	if err := store.Replay(context.Background(), id, RegistryReplayBuilder(registry)); !errors.Is(err, errVendorDown) {
+		t.Fatalf("expected the first replay to fail, got %v", err)
+	}
+	letter, _ := store.Get(id)
+	if letter.Attempts != 4 || !letter.LastAttemptAt.Equal(clock.Now()) || letter.LastError != "vendor down" {
+		t.Errorf("expected the failed replay to be recorded, got %+v", letter)
+	}
+
+	if err := store.Replay(context.Background(), id, RegistryReplayBuilder(registry)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.Len() != 0 || len(sent) != 1 || sent[0] != "twilio: retry me" {
+		t.Errorf("expected the letter delivered through twilio and removed, got %d left, sent %v", store.Len(), sent)
+	}
+
+	store.Close()
+	reopened, _ := OpenDeadLetters(path, clock)
+	defer reopened.Close()
+	if reopened.Len() != 0 {
+		t.Errorf("expected the replayed letter to stay removed after restart, got %d", reopened.Len())
+	}
+}
+
+// sendHook reports successful sends
+type sendHook struct {
+	Notifier
+	after func(msg string)
+}
+
+func (h *sendHook) Send(msg string) error {
+	if err := h.Notifier.Send(msg); err != nil {
+		return err
+	}
+	h.after(msg)
+	return nil
+}
+
+func TestDeadLetterNotifier_RecordsFailedVendorOfFailover(t *testing.T) {
+	store, _ := openTestDeadLetters(t, newFakeClock())
+	failover, _ := NewFailoverNotifier(
+		&scriptedNotifier{vendor: "twilio", errs: []error{errVendorDown}},
+		&scriptedNotifier{vendor: "nexmo", errs: []error{errVendorDown}},
+	)
+	retry := NewRetryNotifier(failover, RetryPolicy{MaxAttempts: 1})
+	notifier := NewDeadLetterNotifier(retry, "sms", store)
+	notifier.Pipeline = "alerts"
+
+	var deadErr *DeadLetterError
+	if err := notifier.Send("hello"); !errors.As(err, &deadErr) {
+		t.Fatalf("expected a DeadLetterError, got %v", err)
+	}
+	if letter, _ := store.Get(deadErr.ID); letter.Vendor != "twilio" || letter.Pipeline != "alerts" {
+		t.Errorf("expected vendor twilio in pipeline alerts, got %q in %q", letter.Vendor, letter.Pipeline)
+	}
+}
+
+func TestDeadLetterStore_ReplayThroughConfig(t *testing.T) {
+	store, _ := openTestDeadLetters(t, newFakeClock())
+	config, err := ParseConfig([]byte(`{"notifiers": {
+		"ops": {"channel": "email", "vendor": "ses", "smtp": {"host": "127.0.0.1:1", "from": "alerts@example.com"}},
+		"alerts": {"channel": "sms", "vendors": ["twilio", "nexmo"]}}}`), "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	email, _ := store.Add(DeadLetter{Channel: "email", Vendor: "ses", Message: Message{To: []string{"ops@example.com"}, Body: "hi"}})
+	sms, _ := store.Add(DeadLetter{Channel: "sms", Vendor: "nexmo", Message: Message{Body: "hi"}})
+
+	// The SMTP pipeline is rebuilt, so the unreachable server fails the
+	// replay instead of the logging stub swallowing it
+	if err := store.Replay(context.Background(), email, ConfigReplayBuilder(config, nil)); err == nil || errors.Is(err, ErrNotReplayable) {
+		t.Errorf("expected the SMTP send to fail, got %v", err)
+	}
+	if letter, _ := store.Get(email); letter.Attempts != 2 {
+		t.Errorf("expected the email letter kept with 2 attempts, got %+v", letter)
+	}
+	if err := store.Replay(context.Background(), sms, ConfigReplayBuilder(config, nil)); err != nil {
+		t.Errorf("expected the alerts pipeline to deliver, got %v", err)
+	}
+	if store.Len() != 1 {
+		t.Errorf("expected only the email letter left, got %d", store.Len())
+	}
+}
+
+func TestDeadLetterNotifier_StoresOnlyUndeliveredTokens(t *testing.T) {
+	store, _ := openTestDeadLetters(t, newFakeClock())
+	server := newPushServer(t, map[string]func(w http.ResponseWriter){
+		"stale": func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusGone)
+			io.WriteString(w, `{"reason":"Unregistered"}`)
+		},
+		"busy": func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"reason":"TooManyRequests"}`)
+		},
+	})
+	clock := newFakeClock()
+	clock.autoAdvance = true
+	apns := NewAPNsPushNotifier("apple", APNsConfig{
+		Endpoint: server.URL, Topic: "com.example.app", TeamID: "T", KeyID: "K", Key: newTestAPNsKey(t),
+	})
+	notifier := NewDeadLetterNotifier(NewRetryNotifier(apns, RetryPolicy{MaxAttempts: 2, Clock: clock, Rand: noJitter}), "push", store)
+
+	err := notifier.SendMessage(context.Background(), Message{To: []string{"stale", "ok", "busy"}, Body: "hi"})
+	var deadErr *DeadLetterError
+	if !errors.As(err, &deadErr) {
+		t.Fatalf("expected a DeadLetterError, got %v", err)
+	}
+	letter, _ := store.Get(deadErr.ID)
+	if want := []string{"stale", "busy"}; !reflect.DeepEqual(letter.Message.To, want) {
+		t.Errorf("expected only the undelivered tokens %v stored, got %v", want, letter.Message.To)
+	}
+
+	replayed := &messageRecorder{scriptedNotifier: scriptedNotifier{vendor: "apple"}}
+	build := func(DeadLetter) (Notifier, error) { return replayed, nil }
+	if err := store.Replay(context.Background(), deadErr.ID, build); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := replayed.Messages(); len(got) != 1 || !reflect.DeepEqual(got[0].To, []string{"stale", "busy"}) {
+		t.Errorf("expected the replay to skip the delivered token, got %+v", got)
+	}
+}
+
+func TestDeadLetterStore_PurgeAll(t *testing.T) {
+	store, path := openTestDeadLetters(t, newFakeClock())
+	for i := 0; i < 3; i++ {
+		store.Add(DeadLetter{Message: Message{Body: "x"}})
+	}
+	if n, err := store.PurgeAll(); n != 3 || err != nil {
+		t.Fatalf("expected 3 purged, got %d (%v)", n, err)
+	}
+	if info, _ := os.Stat(path); info.Size() != 0 {
+		t.Errorf("expected an empty store file, got %d bytes", info.Size())
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestDeadLetterNotifier_CancellationNotStored(t *testing.T) {
+	store, _ := openTestDeadLetters(t, newFakeClock())
+	notifier := NewDeadLetterNotifier(&scriptedNotifier{vendor: "twilio", errs: []error{context.Canceled}}, "sms", store)
+
+	if err := notifier.Send("hello"); !errors.Is(err, context.Canceled) || store.Len() != 0 {
+		t.Errorf("expected cancellation to pass through without a dead letter, got %v and %d letters", err, store.Len())
+	}
+}
+
+func TestDeadLetterStore_UnknownIDs(t *testing.T) {
+	store, _ := openTestDeadLetters(t, newFakeClock())
+
+	if _, err := store.Get(42); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("expected ErrDeadLetterNotFound from Get, got %v", err)
+	}
+	if err := store.Purge(42); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("expected ErrDeadLetterNotFound from Purge, got %v", err)
+	}
+	if err := store.Replay(context.Background(), 42, nil); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("expected ErrDeadLetterNotFound from Replay, got %v", err)
+	}
+}
+
+func TestDeadLetterStore_ConcurrentReplay(t *testing.T) {
+	store, _ := openTestDeadLetters(t, newFakeClock())
+	id, _ := store.Add(DeadLetter{Channel: "sms", Vendor: "twilio", Message: Message{Body: "once"}})
+	gated := newGatedNotifier()
+	build := func(DeadLetter) (Notifier, error) { return gated, nil }
+
+	first := make(chan error, 1)
+	go func() { first <- store.Replay(context.Background(), id, build) }()
+	<-gated.started
+
+	if err := store.Replay(context.Background(), id, build); !errors.Is(err, ErrReplayInProgress) {
+		t.Errorf("expected ErrReplayInProgress, got %v", err)
+	}
+	close(gated.gate)
+	if err := <-first; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := gated.Sent(); len(got) != 1 || store.Len() != 0 {
+		t.Errorf("expected one delivery and the letter removed, got %v and %d letters", got, store.Len())
+	}
+}
+
+func TestDeadLetterStore_ReplayUnknownChannel(t *testing.T) {
+	store, _ := openTestDeadLetters(t, newFakeClock())
+	id, _ := store.Add(DeadLetter{Channel: "pager", Vendor: "acme", Message: Message{Body: "x"}})
+
+	if err := store.Replay(context.Background(), id, RegistryReplayBuilder(nil)); !errors.Is(err, ErrUnknownNotifier) {
+		t.Errorf("expected ErrUnknownNotifier, got %v", err)
+	}
+	if store.Len() != 1 {
+		t.Error("expected the letter to be kept")
+	}
+}
+
+func TestDeadLetterStore_ReplayRefusesLoggingStub(t *testing.T) {
+	store, _ := openTestDeadLetters(t, newFakeClock())
+	config, _ := ParseConfig([]byte(`{"notifiers": {"ops": {"channel": "email", "vendor": "ses"}}}`), "json")
+	id, _ := store.Add(DeadLetter{Channel: "email", Vendor: "ses", Message: Message{Body: "hi"}})
+	stray, _ := store.Add(DeadLetter{Channel: "email", Vendor: "mailgun", Message: Message{Body: "hi"}})
+
+	testCases := []struct {
+		name  string
+		id    uint64
+		build ReplayBuilder
+	}{
+		{"no builder", id, nil},
+		{"registry stub", id, RegistryReplayBuilder(nil)},
+		{"no matching pipeline", stray, ConfigReplayBuilder(config, nil)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := store.Replay(context.Background(), tc.id, tc.build); !errors.Is(err, ErrNotReplayable) {
+				t.Errorf("expected ErrNotReplayable, got %v", err)
+			}
+		})
+	}
+	if letter, _ := store.Get(id); store.Len() != 2 || letter.Attempts != 1 {
+		t.Errorf("expected the letters kept untouched, got %d letters, %+v", store.Len(), letter)
+	}
+}
+
+func TestDeadLetterStore_RejectsTypeNameVendor(t *testing.T) {
+	store, _ := openTestDeadLetters(t, newFakeClock())
+
+	if _, err := store.Add(DeadLetter{Channel: "sms", Vendor: "*factory.FailoverNotifier"}); !errors.Is(err, ErrInvalidDeadLetter) {
+		t.Errorf("expected ErrInvalidDeadLetter, got %v", err)
+	}
+	if _, err := store.Add(DeadLetter{Channel: "webhook", Vendor: "hooks.example.com"}); err != nil {
+		t.Errorf("expected a host name to be accepted, got %v", err)
+	}
+}
+
+func TestDeadLetterStore_CorruptLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deadletters.log")
+	os.WriteFile(path, []byte("not json\n{\"op\":\"del\",\"id\":1}\n"), 0o644)
+
+	if _, err := OpenDeadLetters(path, nil); err == nil {
+		t.Error("expected corruption before the last line to be reported")
+	}
+}
@@ -0,0 +1,351 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDigestClosed is returned by digest notifiers after Shutdown
+var ErrDigestClosed = errors.New("digest: closed")
+
+// DigestCountMetadata is set on rendered digests to the number of items
+const DigestCountMetadata = "digest_count"
+
+// Reasons a DigestBatch was flushed
+const (
+	DigestWindow   = "window"
+	DigestSize     = "size"
+	DigestFlush    = "flush"
+	DigestShutdown = "shutdown"
+)
+
+// DigestBatch is the buffered messages for one recipient on one channel
+type DigestBatch struct {
+	Channel   string
+	Recipient string
+	Items     []Message // in arrival order, each addressed to Recipient only
+	Reason    string    // DigestWindow, DigestSize, DigestFlush or DigestShutdown
+}
+
+// DigestPolicy configures a Digester
+type DigestPolicy struct {
+	Window   time.Duration // how long the first item waits for company, defaults to 5m
+	MaxItems int           // flush as soon as a batch is this big, defaults to 20
+
+	// Render turns a batch into the message that is sent. Defaults to
+	// RenderDigest.
+	Render func(DigestBatch) (Message, error)
+
+	// OnFlush is called after every flush with the send error. Window
+	// flushes have no caller to return the error to, so this is the only
+	// place it is reported.
+	OnFlush func(batch DigestBatch, err error)
+
+	Clock Clock // defaults to SystemClock
+}
+
+// RenderDigest is the default renderer. A batch of one is sent unchanged;
+// larger batches become a list of the items' subjects and bodies. The
+// digest takes the highest priority of its items. Attachments are dropped.
+func RenderDigest(batch DigestBatch) (Message, error) {
+	if len(batch.Items) == 1 {
+		return batch.Items[0], nil
+	}
+	digest := Message{
+		To:       []string{batch.Recipient},
+		Subject:  fmt.Sprintf("%d new notifications", len(batch.Items)),
+		Priority: PriorityBulk,
+		Metadata: map[string]string{DigestCountMetadata: strconv.Itoa(len(batch.Items))},
+	}
+	var body strings.Builder
+	for _, item := range batch.Items {
+		digest.Priority = max(digest.Priority, item.Priority)
+		text := item.Body
+		if text == "" {
+			text = item.HTMLBody
+		}
+		if item.Subject != "" {
+			text = item.Subject + ": " + text
+		}
+		body.WriteString("- " + text + "\n")
+	}
+	digest.Body = body.String()
+	return digest, nil
+}
+
+type digestKey struct {
+	channel   string
+	recipient string
+}
+
+type digestBuffer struct {
+	next  Notifier
+	items []Message
+}
+
+// Digester buffers messages per recipient and channel and sends each batch
+// as one digest when its window ends or it reaches MaxItems. Critical
+// messages skip the buffer. A Digester is safe for concurrent use.
+type Digester struct {
+	policy DigestPolicy
+
+	mu      sync.Mutex
+	buffers map[digestKey]*digestBuffer
+	closed  bool
+
+	done   chan struct{} // closed by Shutdown to stop window timers
+	timers sync.WaitGroup
+	ctx    context.Context // bounds window flushes, cancelled when Shutdown expires
+	cancel context.CancelFunc
+}
+
+// NewDigester creates a Digester, filling in policy defaults
+func NewDigester(policy DigestPolicy) *Digester {
+	if policy.Window <= 0 {
+		policy.Window = 5 * time.Minute
+	}
+	if policy.MaxItems <= 0 {
+		policy.MaxItems = 20
+	}
+	if policy.Render == nil {
+		policy.Render = RenderDigest
+	}
+	if policy.Clock == nil {
+		policy.Clock = SystemClock
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Digester{
+		policy:  policy,
+		buffers: make(map[digestKey]*digestBuffer),
+		done:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Wrap returns a notifier that batches messages for channel and sends the
+// digests through next
+func (d *Digester) Wrap(channel string, next Notifier) *DigestNotifier {
+	return &DigestNotifier{digester: d, channel: channel, next: next}
+}
+
+// Pending returns the number of buffered messages
+func (d *Digester) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pending := 0
+	for _, buffer := range d.buffers {
+		pending += len(buffer.items)
+	}
+	return pending
+}
+
+// add buffers msg for each of its recipients. It returns the error of any
+// size-triggered flush.
+func (d *Digester) add(ctx context.Context, channel string, next Notifier, msg Message) error {
+	recipients := msg.To
+	if len(recipients) == 0 {
+		recipients = []string{""} // the notifier's default recipient
+	}
+
+	var full []DigestBatch
+	var nexts []Notifier
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return ErrDigestClosed
+	}
+	for _, recipient := range recipients {
+		item := msg
+		item.To = nil
+		if recipient != "" {
+			item.To = []string{recipient}
+		}
+		key := digestKey{channel: channel, recipient: recipient}
+		buffer, ok := d.buffers[key]
+		if !ok {
+			buffer = &digestBuffer{next: next}
+			d.buffers[key] = buffer
+			d.startTimer(key, buffer)
+		}
+		buffer.items = append(buffer.items, item)
+		if len(buffer.items) >= d.policy.MaxItems {
+			delete(d.buffers, key)
+			full = append(full, DigestBatch{Channel: channel, Recipient: recipient, Items: buffer.items, Reason: DigestSize})
+			nexts = append(nexts, buffer.next)
+		}
+	}
+	d.mu.Unlock()
+
+	var errs []error
+	for i, batch := range full {
+		if err := d.send(ctx, nexts[i], batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// startTimer flushes buffer when its window ends unless it was flushed
+// earlier. The timer is taken here, under d.mu, so a fake clock sees it
+// before the caller returns. The caller holds d.mu.
+func (d *Digester) startTimer(key digestKey, buffer *digestBuffer) {
+	expired := d.policy.Clock.After(d.policy.Window)
+	d.timers.Add(1)
+	go func() {
+		defer d.timers.Done()
+		select {
+		case <-expired:
+		case <-d.done:
+			return
+		}
+		d.mu.Lock()
+		if d.buffers[key] != buffer {
+			d.mu.Unlock()
+			return
+		}
+		delete(d.buffers, key)
+		d.mu.Unlock()
+		d.send(d.ctx, buffer.next, DigestBatch{
+			Channel:   key.channel,
+			Recipient: key.recipient,
+			Items:     buffer.items,
+			Reason:    DigestWindow,
+		})
+	}()
+}
+
+func (d *Digester) send(ctx context.Context, next Notifier, batch DigestBatch) error {
+	digest, err := d.policy.Render(batch)
+	if err == nil {
+		err = SendMessage(ctx, next, digest)
+	}
+	if err != nil {
+		err = fmt.Errorf("digest for %s %q: %w", batch.Channel, batch.Recipient, err)
+	}
+	if d.policy.OnFlush != nil {
+		d.policy.OnFlush(batch, err)
+	}
+	return err
+}
+
+// take removes and returns every buffered batch
+func (d *Digester) take(reason string) ([]DigestBatch, []Notifier) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	batches := make([]DigestBatch, 0, len(d.buffers))
+	nexts := make([]Notifier, 0, len(d.buffers))
+	for key, buffer := range d.buffers {
+		batches = append(batches, DigestBatch{Channel: key.channel, Recipient: key.recipient, Items: buffer.items, Reason: reason})
+		nexts = append(nexts, buffer.next)
+	}
+	clear(d.buffers)
+	sort.Sort(digestOrder{batches, nexts})
+	return batches, nexts
+}
+
+// digestOrder sorts batches, and their notifiers with them, by channel then
+// recipient so flushes happen in a stable order
+type digestOrder struct {
+	batches []DigestBatch
+	nexts   []Notifier
+}
+
+func (o digestOrder) Len() int { return len(o.batches) }
+func (o digestOrder) Less(i, j int) bool {
+	a, b := o.batches[i], o.batches[j]
+	return a.Channel < b.Channel || (a.Channel == b.Channel && a.Recipient < b.Recipient)
+}
+func (o digestOrder) Swap(i, j int) {
+	o.batches[i], o.batches[j] = o.batches[j], o.batches[i]
+	o.nexts[i], o.nexts[j] = o.nexts[j], o.nexts[i]
+}
+
+func (d *Digester) sendAll(ctx context.Context, reason string) error {
+	batches, nexts := d.take(reason)
+	var errs []error
+	for i, batch := range batches {
+		if err := d.send(ctx, nexts[i], batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Flush sends every buffered batch now, without waiting for its window
+func (d *Digester) Flush(ctx context.Context) error {
+	return d.sendAll(ctx, DigestFlush)
+}
+
+// Shutdown stops accepting messages, waits for window flushes already in
+// progress and sends whatever is still buffered, bounded by ctx. If ctx
+// expires while window flushes are running, they are cancelled when the
+// notifier honours context and the remaining buffer is abandoned.
+func (d *Digester) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	close(d.done)
+	d.mu.Unlock()
+	defer d.cancel()
+
+	flushed := make(chan struct{})
+	go func() {
+		d.timers.Wait()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+		return d.sendAll(ctx, DigestShutdown)
+	case <-ctx.Done():
+		return fmt.Errorf("digest: shutdown abandoned %d message(s): %w", d.Pending(), ctx.Err())
+	}
+}
+
+// DigestNotifier batches messages for one channel of a Digester
+type DigestNotifier struct {
+	digester *Digester
+	channel  string
+	next     Notifier
+}
+
+// VendorName passes through the wrapped notifier's vendor
+func (n *DigestNotifier) VendorName() string {
+	return vendorName(n.next)
+}
+
+// Send buffers msg for the notifier's default recipient
+func (n *DigestNotifier) Send(msg string) error {
+	return n.SendContext(context.Background(), msg)
+}
+
+// SendContext is Send bounded by ctx
+func (n *DigestNotifier) SendContext(ctx context.Context, msg string) error {
+	return n.SendMessage(ctx, Message{Body: msg})
+}
+
+// SendMessage buffers msg once per recipient. Critical messages are sent
+// straight away. A nil error means the message was buffered; the digest
+// is sent later unless this message filled its batch.
+func (n *DigestNotifier) SendMessage(ctx context.Context, msg Message) error {
+	if msg.Priority < PriorityCritical {
+		return n.digester.add(ctx, n.channel, n.next, msg)
+	}
+	n.digester.mu.Lock()
+	closed := n.digester.closed
+	n.digester.mu.Unlock()
+	if closed {
+		return ErrDigestClosed
+	}
+	return SendMessage(ctx, n.next, msg)
+}
@@ -0,0 +1,215 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// flushLog collects OnFlush calls so tests can wait for window flushes
+type flushLog chan DigestBatch
+
+func (l flushLog) record(batch DigestBatch, err error) { l <- batch }
+
+func (l flushLog) wait(t *testing.T) DigestBatch {
+	t.Helper()
+	select {
+	case batch := <-l:
+		return batch
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a digest flush")
+		return DigestBatch{}
+	}
+}
+
+// ============================================================================
+// DIGEST TESTS
+// ============================================================================
+
+func TestDigester_WindowCoalescesPerRecipient(t *testing.T) {
+	clock := newFakeClock()
+	flushed := make(flushLog, 10)
+	digester := NewDigester(DigestPolicy{Window: time.Minute, Clock: clock, OnFlush: flushed.record})
+	email := &messageRecorder{scriptedNotifier: scriptedNotifier{vendor: "sendgrid"}}
+	notifier := digester.Wrap("email", email)
+
+	notifier.SendMessage(context.Background(), Message{To: []string{"a@example.com"}, Subject: "Comment", Body: "Bo replied"})
+	notifier.SendMessage(context.Background(), Message{To: []string{"a@example.com", "b@example.com"}, Subject: "Like", Body: "Cy liked your post"})
+	notifier.SendMessage(context.Background(), Message{To: []string{"a@example.com"}, Body: "Weekly stats are ready"})
+
+	if digester.Pending() != 4 || email.Calls() != 0 {
+		t.Fatalf("expected 4 buffered items and no sends, got %d buffered and %d sends", digester.Pending(), email.Calls())
+	}
+	clock.Advance(time.Minute)
+	flushed.wait(t)
+	flushed.wait(t)
+
+	byRecipient := map[string]Message{}
+	for _, msg := range email.Messages() {
+		byRecipient[msg.To[0]] = msg
+	}
+	a := byRecipient["a@example.com"]
+	if a.Subject != "3 new notifications" || a.Metadata[DigestCountMetadata] != "3" {
+		t.Errorf("expected a 3-item digest for a, got %+v", a)
+	}
+	if want := "- Comment: Bo replied\n- Like: Cy liked your post\n- Weekly stats are ready\n"; a.Body != want {
+		t.Errorf("expected body %q, got %q", want, a.Body)
+	}
+	if b := byRecipient["b@example.com"]; b.Subject != "Like" || b.Body != "Cy liked your post" {
+		t.Errorf("expected a single item to be sent unchanged, got %+v", b)
+	}
+}
+
+func TestDigester_SizeThresholdFlushesImmediately(t *testing.T) {
+	digester := NewDigester(DigestPolicy{Window: time.Hour, MaxItems: 3, Clock: newFakeClock()})
+	sms := &messageRecorder{scriptedNotifier: scriptedNotifier{vendor: "twilio"}}
+	notifier := digester.Wrap("sms", sms)
+
+	for _, body := range []string{"one", "two", "three", "four"} {
+		if err := notifier.SendMessage(context.Background(), Message{To: []string{"+15551234567"}, Body: body}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := sms.Messages(); len(got) != 1 || got[0].Body != "- one\n- two\n- three\n" {
+		t.Errorf("expected the third message to flush a digest, got %+v", got)
+	}
+	if digester.Pending() != 1 {
+		t.Errorf("expected the fourth message to start a new batch, got %d pending", digester.Pending())
+	}
+}
+
+func TestDigester_ChannelsBatchSeparately(t *testing.T) {
+	digester := NewDigester(DigestPolicy{Clock: newFakeClock()})
+	email := &messageRecorder{scriptedNotifier: scriptedNotifier{vendor: "sendgrid"}}
+	push := &messageRecorder{scriptedNotifier: scriptedNotifier{vendor: "fcm"}}
+
+	digester.Wrap("email", email).SendMessage(context.Background(), Message{To: []string{"u1"}, Body: "a"})
+	digester.Wrap("push", push).SendMessage(context.Background(), Message{To: []string{"u1"}, Body: "b"})
+	if err := digester.Flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(email.Messages()) != 1 || len(push.Messages()) != 1 {
+		t.Errorf("expected one message per channel, got email %d push %d", len(email.Messages()), len(push.Messages()))
+	}
+}
+
+func TestDigester_CriticalSkipsBuffer(t *testing.T) {
+	digester := NewDigester(DigestPolicy{Clock: newFakeClock()})
+	sms := &messageRecorder{scriptedNotifier: scriptedNotifier{vendor: "twilio"}}
+
+	digester.Wrap("sms", sms).SendMessage(context.Background(), Message{Body: "Password reset code 4821", Priority: PriorityCritical})
+	if sms.Calls() != 1 || digester.Pending() != 0 {
+		t.Errorf("expected a critical message to be sent at once, got %d sends and %d pending", sms.Calls(), digester.Pending())
+	}
+}
+
+func TestDigester_ShutdownFlushes(t *testing.T) {
+	flushed := make(flushLog, 10)
+	digester := NewDigester(DigestPolicy{Window: time.Hour, Clock: newFakeClock(), OnFlush: flushed.record})
+	email := &messageRecorder{scriptedNotifier: scriptedNotifier{vendor: "sendgrid"}}
+	notifier := digester.Wrap("email", email)
+	notifier.Send("first")
+	notifier.Send("second")
+
+	if err := digester.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if batch := flushed.wait(t); batch.Reason != DigestShutdown || len(batch.Items) != 2 {
+		t.Errorf("expected a 2-item shutdown flush, got %+v", batch)
+	}
+	if got := email.Messages(); len(got) != 1 || got[0].Body != "- first\n- second\n" {
+		t.Errorf("expected the buffered messages to be sent on shutdown, got %+v", got)
+	}
+}
+
+func TestRenderDigest_Priority(t *testing.T) {
+	digest, _ := RenderDigest(DigestBatch{Recipient: "u1", Items: []Message{
+		{Body: "a", Priority: PriorityBulk},
+		{Body: "b", Priority: PriorityHigh},
+	}})
+	if digest.Priority != PriorityHigh || !reflect.DeepEqual(digest.To, []string{"u1"}) {
+		t.Errorf("expected a high-priority digest to u1, got %+v", digest)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestDigester_RejectsAfterShutdown(t *testing.T) {
+	digester := NewDigester(DigestPolicy{Clock: newFakeClock()})
+	digester.Shutdown(context.Background())
+	notifier := digester.Wrap("email", &scriptedNotifier{})
+
+	if err := notifier.Send("late"); !errors.Is(err, ErrDigestClosed) {
+		t.Errorf("expected ErrDigestClosed, got %v", err)
+	}
+	if err := notifier.SendMessage(context.Background(), Message{Body: "late", Priority: PriorityCritical}); !errors.Is(err, ErrDigestClosed) {
+		t.Errorf("expected ErrDigestClosed for a critical message, got %v", err)
+	}
+}
+
+func TestDigester_WindowFlushErrorReported(t *testing.T) {
+	clock := newFakeClock()
+	var reported error
+	done := make(chan struct{})
+	digester := NewDigester(DigestPolicy{Window: time.Minute, Clock: clock, OnFlush: func(_ DigestBatch, err error) {
+		reported = err
+		close(done)
+	}})
+	digester.Wrap("sms", &scriptedNotifier{vendor: "twilio", errs: []error{errVendorDown}}).Send("hello")
+
+	clock.Advance(time.Minute)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the window flush")
+	}
+	if !errors.Is(reported, errVendorDown) {
+		t.Errorf("expected the vendor error to reach OnFlush, got %v", reported)
+	}
+}
+
+func TestDigester_ShutdownDeadlineCancelsWindowFlush(t *testing.T) {
+	clock := newFakeClock()
+	digester := NewDigester(DigestPolicy{Window: time.Minute, Clock: clock})
+	next := newBlockingNotifier()
+	digester.Wrap("sms", next).Send("hello")
+
+	clock.Advance(time.Minute)
+	<-next.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- digester.Shutdown(ctx) }()
+
+	select {
+	case err := <-shutdown:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected DeadlineExceeded, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Shutdown to honour its deadline while a window flush hangs")
+	}
+	select {
+	case err := <-next.released:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the window flush to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Shutdown to cancel the window flush")
+	}
+}
+
+func TestDigester_RenderError(t *testing.T) {
+	errRender := errors.New("template broken")
+	digester := NewDigester(DigestPolicy{MaxItems: 1, Clock: newFakeClock(), Render: func(DigestBatch) (Message, error) {
+		return Message{}, errRender
+	}})
+	sms := &scriptedNotifier{vendor: "twilio"}
+
+	if err := digester.Wrap("sms", sms).Send("hello"); !errors.Is(err, errRender) || sms.Calls() != 0 {
+		t.Errorf("expected the render error and no send, got %v with %d sends", err, sms.Calls())
+	}
+}
@@ -0,0 +1,297 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy decides what Enqueue does when the queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits until a worker frees a slot
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDrop silently discards the message and counts it as dropped
+	OverflowDrop
+	// OverflowError rejects the message with ErrQueueFull
+	OverflowError
+)
+
+var (
+	// ErrQueueFull is returned by Enqueue under OverflowError
+	ErrQueueFull = errors.New("dispatcher: queue full")
+	// ErrDispatcherClosed is returned by Enqueue after Shutdown
+	ErrDispatcherClosed = errors.New("dispatcher: closed")
+)
+
+// DispatcherConfig configures a Dispatcher
+type DispatcherConfig struct {
+	// NotifierType and Vendor are passed to NotifierFactory
+	// unless Notifier is set
+	NotifierType string
+	Vendor       string
+	Notifier     Notifier
+
+	Workers   int // concurrent senders, defaults to 4
+	QueueSize int // buffered messages, defaults to 100
+	Overflow  OverflowPolicy
+
+	// OnError is called from a worker with the message body when a send fails
+	OnError func(msg string, err error)
+
+	// Outbox, if set, makes queued messages survive a crash: each message is
+	// appended before it is queued and acked once it is sent or fails
+	// permanently. Entries left pending by a previous run are queued again
+	// by NewDispatcher. The caller owns the outbox and closes it after
+	// Shutdown.
+	Outbox *Outbox
+
+	// Priority orders the queue by Message.Priority; see PriorityPolicy
+	Priority PriorityPolicy
+}
+
+// DispatcherStats counts what happened to enqueued messages
+type DispatcherStats struct {
+	Enqueued int64
+	Sent     int64
+	Failed   int64
+	Dropped  int64
+	Replayed int64 // pending outbox entries queued again at startup
+}
+
+// Dispatcher delivers messages asynchronously through a bounded priority
+// queue and a fixed pool of worker goroutines
+type Dispatcher struct {
+	notifier Notifier
+	overflow OverflowPolicy
+	onError  func(msg string, err error)
+	outbox   *Outbox
+
+	queue  *priorityQueue
+	abort  chan struct{}
+	ctx    context.Context // cancelled with abort to stop in-flight sends
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex // guards closed and outbox appends
+	closed bool
+
+	enqueued, sent, failed, dropped, replayed atomic.Int64
+}
+
+// queued is a message with its outbox ID, zero when there is no outbox
+type queued struct {
+	id  uint64
+	msg Message
+	at  time.Time // when it was queued
+}
+
+// NewDispatcher builds the notifier and starts the workers
+func NewDispatcher(config DispatcherConfig) (*Dispatcher, error) {
+	notifier := config.Notifier
+	if notifier == nil {
+		var err error
+		notifier, err = NotifierFactory(config.NotifierType, config.Vendor)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 100
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		notifier: notifier,
+		overflow: config.Overflow,
+		onError:  config.OnError,
+		outbox:   config.Outbox,
+		queue:    newPriorityQueue(config.QueueSize, config.Priority),
+		abort:    make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	d.wg.Add(config.Workers)
+	for i := 0; i < config.Workers; i++ {
+		go d.worker()
+	}
+
+	// Replay blocks until every pending entry fits in the queue, so a
+	// backlog larger than QueueSize waits for the workers to drain it
+	if d.outbox != nil {
+		for _, entry := range d.outbox.Pending() {
+			d.queue.push(context.Background(), queued{id: entry.ID, msg: entry.Message}, true)
+			d.enqueued.Add(1)
+			d.replayed.Add(1)
+		}
+	}
+	return d, nil
+}
+
+// Enqueue hands msg to the workers, applying the overflow policy when full
+func (d *Dispatcher) Enqueue(msg string) error {
+	return d.EnqueueContext(context.Background(), msg)
+}
+
+// EnqueueContext is Enqueue where ctx bounds how long OverflowBlock waits
+// for a free slot
+func (d *Dispatcher) EnqueueContext(ctx context.Context, msg string) error {
+	return d.EnqueueMessage(ctx, Message{Body: msg})
+}
+
+// EnqueueMessage queues a structured message like EnqueueContext
+func (d *Dispatcher) EnqueueMessage(ctx context.Context, msg Message) error {
+	item, err := d.admit(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	// The lock is not held here: a push blocked on a full queue is woken
+	// with ErrDispatcherClosed when Shutdown closes the queue
+	err = d.queue.push(ctx, item, d.overflow == OverflowBlock)
+	if err == ErrQueueFull && d.overflow == OverflowDrop {
+		d.dropped.Add(1)
+		return d.forget(item)
+	}
+	if err != nil {
+		return errors.Join(err, d.forget(item))
+	}
+	d.enqueued.Add(1)
+	return nil
+}
+
+// admit checks that the dispatcher is open and appends msg to the outbox
+func (d *Dispatcher) admit(ctx context.Context, msg Message) (queued, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return queued{}, ErrDispatcherClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return queued{}, err
+	}
+
+	item := queued{msg: msg}
+	if d.outbox != nil {
+		id, err := d.outbox.Append(msg)
+		if err != nil {
+			return queued{}, err
+		}
+		item.id = id
+	}
+	return item, nil
+}
+
+// forget acks an outbox entry that was never queued
+func (d *Dispatcher) forget(item queued) error {
+	if item.id == 0 {
+		return nil
+	}
+	return d.outbox.Ack(item.id)
+}
+
+// Send enqueues msg so a Dispatcher can be used wherever a Notifier is expected.
+// A nil error means the message was accepted, not that it was delivered.
+func (d *Dispatcher) Send(msg string) error {
+	return d.Enqueue(msg)
+}
+
+// SendContext enqueues msg like Send. ctx only covers the enqueue; delivery
+// runs on the dispatcher's own context, which Shutdown cancels on expiry.
+func (d *Dispatcher) SendContext(ctx context.Context, msg string) error {
+	return d.EnqueueContext(ctx, msg)
+}
+
+// SendMessage enqueues a structured message like SendContext
+func (d *Dispatcher) SendMessage(ctx context.Context, msg Message) error {
+	return d.EnqueueMessage(ctx, msg)
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+	for {
+		// pop checks abort first so an expired Shutdown wins over a
+		// non-empty queue
+		item, ok := d.queue.pop(d.abort)
+		if !ok {
+			return
+		}
+		d.deliver(item)
+	}
+}
+
+// deliver sends one message. Outbox entries are acked after a success or a
+// permanent failure; transient failures stay pending and are retried on the
+// next startup.
+func (d *Dispatcher) deliver(item queued) {
+	err := SendMessage(d.ctx, d.notifier, item.msg)
+	if err == nil {
+		d.sent.Add(1)
+	} else {
+		d.failed.Add(1)
+		if d.onError != nil {
+			d.onError(item.msg.Body, err)
+		}
+	}
+
+	if item.id != 0 && (err == nil || IsPermanent(err)) {
+		if ackErr := d.outbox.Ack(item.id); ackErr != nil && d.onError != nil {
+			d.onError(item.msg.Body, ackErr)
+		}
+	}
+}
+
+// Shutdown stops accepting messages and waits for queued and in-flight
+// sends to finish. If ctx expires first, Shutdown returns immediately,
+// in-flight sends are cancelled when the notifier honours context, workers
+// stop after their current send and queued messages are abandoned.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return ErrDispatcherClosed
+	}
+	d.closed = true
+	d.queue.close()
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		close(d.abort)
+		d.cancel()
+		return fmt.Errorf("dispatcher: shutdown abandoned %d message(s): %w", d.queue.len(), ctx.Err())
+	}
+}
+
+// Queued returns the number of messages waiting in each priority class
+func (d *Dispatcher) Queued() map[Priority]int {
+	return d.queue.lens()
+}
+
+// Stats returns a snapshot of the dispatcher counters
+func (d *Dispatcher) Stats() DispatcherStats {
+	return DispatcherStats{
+		Enqueued: d.enqueued.Load(),
+		Sent:     d.sent.Load(),
+		Failed:   d.failed.Load(),
+		Dropped:  d.dropped.Load(),
+		Replayed: d.replayed.Load(),
+	}
+}
@@ -0,0 +1,313 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedNotifier blocks every Send until the gate is opened
+type gatedNotifier struct {
+	gate    chan struct{}
+	started chan string
+
+	mu   sync.Mutex
+	sent []string
+}
+
+func newGatedNotifier() *gatedNotifier {
+	return &gatedNotifier{gate: make(chan struct{}), started: make(chan string, 100)}
+}
+
+func (g *gatedNotifier) Send(msg string) error {
+	g.started <- msg
+	<-g.gate
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sent = append(g.sent, msg)
+	return nil
+}
+
+func (g *gatedNotifier) Sent() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.sent...)
+}
+
+// ============================================================================
+// DISPATCHER TESTS
+// ============================================================================
+
+func TestDispatcher_DeliversAllMessages(t *testing.T) {
+	inner := &scriptedNotifier{}
+	d, err := NewDispatcher(DispatcherConfig{Notifier: inner, Workers: 3, QueueSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		if err := d.Enqueue("hello"); err != nil {
+			t.Fatalf("unexpected enqueue error: %v", err)
+		}
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+
+	if inner.Calls() != 20 {
+		t.Errorf("expected 20 sends, got %d", inner.Calls())
+	}
+	stats := d.Stats()
+	if stats.Enqueued != 20 || stats.Sent != 20 || stats.Failed != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestDispatcher_BuildsNotifierFromFactory(t *testing.T) {
+	d, err := NewDispatcher(DispatcherConfig{NotifierType: "sms", Vendor: "twilio"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer d.Shutdown(context.Background())
+
+	if _, ok := d.notifier.(*SmsNotifier); !ok {
+		t.Errorf("expected *SmsNotifier, got %T", d.notifier)
+	}
+}
+
+func TestDispatcher_SendDoesNotBlockCaller(t *testing.T) {
+	inner := newGatedNotifier()
+	d, _ := NewDispatcher(DispatcherConfig{Notifier: inner, Workers: 1, QueueSize: 5})
+
+	var n Notifier = d
+	if err := n.Send("async"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-inner.started
+
+	close(inner.gate)
+	d.Shutdown(context.Background())
+	if got := inner.Sent(); len(got) != 1 || got[0] != "async" {
+		t.Errorf("expected 'async' to be delivered, got %v", got)
+	}
+}
+
+func TestDispatcher_OverflowDrop(t *testing.T) {
+	inner := newGatedNotifier()
+	d, _ := NewDispatcher(DispatcherConfig{Notifier: inner, Workers: 1, QueueSize: 1, Overflow: OverflowDrop})
+
+	d.Enqueue("in flight")
+	<-inner.started
+	d.Enqueue("queued")
+	if err := d.Enqueue("dropped"); err != nil {
+		t.Errorf("expected drop to be silent, got %v", err)
+	}
+
+	close(inner.gate)
+	d.Shutdown(context.Background())
+
+	stats := d.Stats()
+	if stats.Dropped != 1 || stats.Sent != 2 {
+		t.Errorf("expected 1 dropped and 2 sent, got %+v", stats)
+	}
+}
+
+func TestDispatcher_OverflowBlock(t *testing.T) {
+	inner := newGatedNotifier()
+	d, _ := NewDispatcher(DispatcherConfig{Notifier: inner, Workers: 1, QueueSize: 1, Overflow: OverflowBlock})
+
+	d.Enqueue("in flight")
+	<-inner.started
+	d.Enqueue("queued")
+
+	accepted := make(chan struct{})
+	go func() {
+		d.Enqueue("waiting")
+		close(accepted)
+	}()
+
+	select {
+	case <-accepted:
+		t.Fatal("expected Enqueue to block while the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(inner.gate)
+	<-accepted
+	d.Shutdown(context.Background())
+	if got := len(inner.Sent()); got != 3 {
+		t.Errorf("expected 3 messages delivered, got %d", got)
+	}
+}
+
+func TestDispatcher_OnError(t *testing.T) {
+	inner := &scriptedNotifier{errs: []error{errVendorDown}}
+	var mu sync.Mutex
+	var failed []string
+
+	d, _ := NewDispatcher(DispatcherConfig{
+		Notifier: inner,
+		Workers:  1,
+		OnError: func(msg string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, msg)
+		},
+	})
+	d.Enqueue("first")
+	d.Enqueue("second")
+	d.Shutdown(context.Background())
+
+	if len(failed) != 1 || failed[0] != "first" {
+		t.Errorf("expected OnError for 'first', got %v", failed)
+	}
+	if stats := d.Stats(); stats.Failed != 1 || stats.Sent != 1 {
+		t.Errorf("expected 1 failed and 1 sent, got %+v", stats)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestDispatcher_OverflowError(t *testing.T) {
+	inner := newGatedNotifier()
+	d, _ := NewDispatcher(DispatcherConfig{Notifier: inner, Workers: 1, QueueSize: 1, Overflow: OverflowError})
+
+	d.Enqueue("in flight")
+	<-inner.started
+	d.Enqueue("queued")
+	if err := d.Enqueue("rejected"); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+
+	close(inner.gate)
+	d.Shutdown(context.Background())
+}
+
+func TestDispatcher_EnqueueAfterShutdown(t *testing.T) {
+	d, _ := NewDispatcher(DispatcherConfig{Notifier: &scriptedNotifier{}})
+	d.Shutdown(context.Background())
+
+	if err := d.Enqueue("late"); !errors.Is(err, ErrDispatcherClosed) {
+		t.Errorf("expected ErrDispatcherClosed, got %v", err)
+	}
+	if err := d.Shutdown(context.Background()); !errors.Is(err, ErrDispatcherClosed) {
+		t.Errorf("expected second Shutdown to report ErrDispatcherClosed, got %v", err)
+	}
+}
+
+func TestDispatcher_ShutdownDeadline(t *testing.T) {
+	inner := newGatedNotifier()
+	d, _ := NewDispatcher(DispatcherConfig{Notifier: inner, Workers: 1, QueueSize: 5})
+
+	d.Enqueue("in flight")
+	<-inner.started
+	d.Enqueue("abandoned 1")
+	d.Enqueue("abandoned 2")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := d.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+
+	// Let the in-flight send finish; the worker must not pick up anything else
+	close(inner.gate)
+	time.Sleep(20 * time.Millisecond)
+	if got := inner.Sent(); len(got) != 1 || got[0] != "in flight" {
+		t.Errorf("expected only the in-flight send to complete, got %v", got)
+	}
+}
+
+func TestDispatcher_ShutdownDeadlineWithBlockedEnqueue(t *testing.T) {
+	inner := newGatedNotifier()
+	defer close(inner.gate)
+	d, _ := NewDispatcher(DispatcherConfig{Notifier: inner, Workers: 1, QueueSize: 1, Overflow: OverflowBlock})
+
+	d.Enqueue("in flight")
+	<-inner.started
+	d.Enqueue("queued")
+
+	enqueued := make(chan error, 1)
+	go func() { enqueued <- d.Enqueue("waiting") }()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- d.Shutdown(ctx) }()
+
+	select {
+	case err := <-shutdown:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected DeadlineExceeded, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Shutdown to honour its deadline while an Enqueue is blocked")
+	}
+	select {
+	case err := <-enqueued:
+		if !errors.Is(err, ErrDispatcherClosed) {
+			t.Errorf("expected the blocked Enqueue to get ErrDispatcherClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Shutdown to release the blocked Enqueue")
+	}
+}
+
+func TestNewDispatcher_UnknownType(t *testing.T) {
+	d, err := NewDispatcher(DispatcherConfig{NotifierType: "pager", Vendor: "pagerduty"})
+	if !errors.Is(err, ErrUnknownNotifier) {
+		t.Errorf("expected ErrUnknownNotifier, got %v", err)
+	}
+	if d != nil {
+		t.Error("expected nil dispatcher on error")
+	}
+}
+
+func TestDispatcher_ShutdownDeadlineCancelsInFlight(t *testing.T) {
+	inner := newBlockingNotifier()
+	d, _ := NewDispatcher(DispatcherConfig{Notifier: inner, Workers: 1})
+
+	d.Enqueue("in flight")
+	<-inner.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := d.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+
+	select {
+	case err := <-inner.released:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected in-flight send to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Shutdown deadline to cancel the in-flight send")
+	}
+}
+
+func TestDispatcher_EnqueueContextBlockedUntilCancel(t *testing.T) {
+	inner := newGatedNotifier()
+	d, _ := NewDispatcher(DispatcherConfig{Notifier: inner, Workers: 1, QueueSize: 1, Overflow: OverflowBlock})
+
+	d.Enqueue("in flight")
+	<-inner.started
+	d.Enqueue("queued")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := d.EnqueueContext(ctx, "waiting"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+
+	close(inner.gate)
+	d.Shutdown(context.Background())
+	if stats := d.Stats(); stats.Enqueued != 2 {
+		t.Errorf("expected 2 enqueued, got %+v", stats)
+	}
+}
@@ -1,15 +1,60 @@
 package factory
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
 
 // Notifier interface - all notifiers must implement this
 type Notifier interface {
 	Send(message string) error
 }
 
-// EmailNotifier sends notifications via email
+var (
+	logMu     sync.Mutex
+	logOutput io.Writer = os.Stdout
+)
+
+// SetLogOutput sets where notifiers without a transport print their sends,
+// os.Stdout by default, and returns the previous writer
+func SetLogOutput(w io.Writer) io.Writer {
+	logMu.Lock()
+	defer logMu.Unlock()
+	previous := logOutput
+	logOutput = w
+	return previous
+}
+
+// logf prints one logged send
+func logf(format string, args ...any) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	fmt.Fprintf(logOutput, format, args...)
+}
+
+// Vendored is implemented by notifiers backed by a named vendor
+type Vendored interface {
+	VendorName() string
+}
+
+// EmailNotifier sends notifications via email.
+// Without SMTP configuration it only prints the message, see SetLogOutput.
 type EmailNotifier struct {
 	Vendor string
+	SMTP   *SMTPConfig
+}
+
+// VendorName returns the vendor backing this notifier
+func (e *EmailNotifier) VendorName() string {
+	return e.Vendor
+}
+
+// logsOnly reports whether sends are only printed
+func (e *EmailNotifier) logsOnly() bool {
+	return e.SMTP == nil
 }
 
 // NewEmailNotifier creates a new EmailNotifier
@@ -21,13 +66,49 @@ func NewEmailNotifier(vendor string) *EmailNotifier {
 
 // Send sends an email notification
 func (e *EmailNotifier) Send(msg string) error {
-	fmt.Printf("Msg '%s' sent from email vendor: %s\n", msg, e.Vendor)
+	return e.SendContext(context.Background(), msg)
+}
+
+// SendContext sends an email notification, aborting the SMTP session when ctx ends
+func (e *EmailNotifier) SendContext(ctx context.Context, msg string) error {
+	return e.SendMessage(ctx, Message{Body: msg})
+}
+
+// SendMessage validates and sends a structured email.
+// Recipients and subject override the SMTP configuration when set.
+func (e *EmailNotifier) SendMessage(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := msg.Validate("email"); err != nil {
+		return err
+	}
+	if e.SMTP != nil {
+		return e.SMTP.sendSMTP(ctx, msg)
+	}
+	logf("Msg '%s' sent from email vendor: %s\n", msg.Body, e.Vendor)
 	return nil
 }
 
-// SmsNotifier sends notifications via SMS
+// SmsNotifier sends notifications via SMS. Bodies longer than one SMS are
+// split into concatenated segments; see Plan.
 type SmsNotifier struct {
 	Vendor string
+
+	Unicode         SMSUnicodePolicy // what to do with text outside GSM-7
+	MaxSegments     int              // reject bodies needing more segments, 0 for no limit
+	PricePerSegment float64          // used for SMSPlan.Cost
+}
+
+// VendorName returns the vendor backing this notifier
+func (e *SmsNotifier) VendorName() string {
+	return e.Vendor
+}
+
+// logsOnly reports whether sends are only printed, which is always true:
+// SmsNotifier has no vendor transport yet
+func (e *SmsNotifier) logsOnly() bool {
+	return true
 }
 
 // NewSmsNotifier creates a new SmsNotifier
@@ -39,13 +120,53 @@ func NewSmsNotifier(vendor string) *SmsNotifier {
 
 // Send sends an SMS notification
 func (e *SmsNotifier) Send(msg string) error {
-	fmt.Printf("Msg '%s' sent from SMS vendor: %s\n", msg, e.Vendor)
+	return e.SendContext(context.Background(), msg)
+}
+
+// SendContext sends an SMS notification unless ctx is already done
+func (e *SmsNotifier) SendContext(ctx context.Context, msg string) error {
+	return e.SendMessage(ctx, Message{Body: msg})
+}
+
+// SendMessage validates and sends a structured SMS notification
+func (e *SmsNotifier) SendMessage(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	plan, err := e.Plan(msg)
+	if err != nil {
+		return err
+	}
+	if len(plan.Segments) == 1 {
+		logf("Msg '%s' sent from SMS vendor: %s\n", plan.Text, e.Vendor)
+		return nil
+	}
+	for i, segment := range plan.Segments {
+		logf("Msg '%s' sent from SMS vendor: %s (segment %d/%d)\n", segment.Text, e.Vendor, i+1, len(plan.Segments))
+	}
 	return nil
 }
 
-// PushNotifier sends push notifications
+// PushNotifier sends push notifications. With APNs or FCM set it delivers
+// to that service; otherwise it only logs the notification. Setting both
+// is an error, since a device token belongs to one service.
 type PushNotifier struct {
 	Vendor string
+	APNs   *APNsConfig
+	FCM    *FCMConfig
+	Clock  Clock // dates APNs provider tokens and expirations, defaults to SystemClock
+
+	apnsJWT *apnsTokenCache // set by NewAPNsPushNotifier; nil signs per send
+}
+
+// VendorName returns the vendor backing this notifier
+func (e *PushNotifier) VendorName() string {
+	return e.Vendor
+}
+
+// logsOnly reports whether sends are only printed
+func (e *PushNotifier) logsOnly() bool {
+	return e.APNs == nil && e.FCM == nil
 }
 
 // NewPushNotifier creates a new PushNotifier
@@ -57,20 +178,53 @@ func NewPushNotifier(vendor string) *PushNotifier {
 
 // Send sends a push notification
 func (e *PushNotifier) Send(msg string) error {
-	fmt.Printf("Msg '%s' sent from Push vendor: %s\n", msg, e.Vendor)
-	return nil
+	return e.SendContext(context.Background(), msg)
 }
 
-// NotifierFactory creates the appropriate Notifier based on type
+// SendContext sends a push notification unless ctx is already done
+func (e *PushNotifier) SendContext(ctx context.Context, msg string) error {
+	return e.SendMessage(ctx, Message{Body: msg})
+}
+
+// SendMessage validates and sends a structured push notification
+func (e *PushNotifier) SendMessage(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := msg.Validate("push"); err != nil {
+		return err
+	}
+	if e.APNs == nil && e.FCM == nil {
+		logf("Msg '%s' sent from Push vendor: %s\n", msg.Body, e.Vendor)
+		return nil
+	}
+	if e.APNs != nil && e.FCM != nil {
+		return &ValidationError{Channel: "push", Field: "APNs", Reason: "cannot be combined with FCM; use one notifier per service"}
+	}
+	if len(msg.To) == 0 {
+		return &ValidationError{Channel: "push", Field: "To", Reason: "needs at least one device token"}
+	}
+	if e.APNs != nil {
+		opts, err := pushOptions(e.APNs.Defaults, msg)
+		if err != nil {
+			return err
+		}
+		clock := e.Clock
+		if clock == nil {
+			clock = SystemClock
+		}
+		return e.APNs.send(ctx, e.apnsJWT, msg, opts, clock.Now())
+	}
+	opts, err := pushOptions(e.FCM.Defaults, msg)
+	if err != nil {
+		return err
+	}
+	return e.FCM.send(ctx, msg, opts)
+}
+
+// NotifierFactory creates the appropriate Notifier based on type.
+// Types are resolved through the default Registry, so callers can plug in
+// their own notifiers with Register.
 func NotifierFactory(notifierType, vendor string) (Notifier, error) {
-	switch notifierType {
-	case "email":
-		return NewEmailNotifier(vendor), nil
-	case "sms":
-		return NewSmsNotifier(vendor), nil
-	case "push":
-		return NewPushNotifier(vendor), nil
-	default:
-		return nil, fmt.Errorf("unknown notifier type: %s", notifierType)
-	}
-}
\ No newline at end of file
+	return defaultRegistry.New(notifierType, vendor)
+}
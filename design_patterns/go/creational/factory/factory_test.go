@@ -1,6 +1,7 @@
 package factory
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -96,6 +97,21 @@ func TestNotifier_InterfaceSatisfaction(t *testing.T) {
 	var _ Notifier = &PushNotifier{}
 }
 
+func TestSetLogOutput_RedirectsLoggedSends(t *testing.T) {
+	var out strings.Builder
+	previous := SetLogOutput(&out)
+	defer SetLogOutput(previous)
+
+	NewEmailNotifier("ses").Send("hello")
+	NewSmsNotifier("twilio").Send("hi")
+	NewPushNotifier("fcm").Send("ping")
+
+	want := "Msg 'hello' sent from email vendor: ses\nMsg 'hi' sent from SMS vendor: twilio\nMsg 'ping' sent from Push vendor: fcm\n"
+	if out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
+
 // ==================== NEGATIVE TEST CASES ====================
 
 func TestNotifierFactory_EmptyType(t *testing.T) {
@@ -0,0 +1,197 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VendorOutcome is the result of trying one vendor for one send
+type VendorOutcome struct {
+	Vendor   string
+	Err      error
+	Duration time.Duration
+}
+
+// VendorStats summarises every send attempted on one vendor
+type VendorStats struct {
+	Vendor    string
+	Successes int
+	Failures  int
+	LastError error
+	Healthy   bool // the last attempt on this vendor succeeded
+}
+
+// FailoverError is returned when every vendor failed
+type FailoverError struct {
+	Outcomes []VendorOutcome
+}
+
+func (e *FailoverError) Error() string {
+	parts := make([]string, 0, len(e.Outcomes))
+	for _, o := range e.Outcomes {
+		parts = append(parts, fmt.Sprintf("%s: %v", o.Vendor, o.Err))
+	}
+	return "all vendors failed: " + strings.Join(parts, "; ")
+}
+
+// Unwrap exposes each vendor error to errors.Is / errors.As
+func (e *FailoverError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Outcomes))
+	for _, o := range e.Outcomes {
+		errs = append(errs, o.Err)
+	}
+	return errs
+}
+
+// FailoverNotifier tries an ordered list of vendors for one channel.
+// It remembers the last vendor that worked and starts there next time,
+// so a primary outage costs one failed attempt instead of one per send.
+type FailoverNotifier struct {
+	vendors []Notifier
+	names   []string
+	clock   Clock
+
+	mu        sync.Mutex
+	preferred int
+	stats     []VendorStats
+	last      []VendorOutcome
+}
+
+// NewFailoverNotifier creates a FailoverNotifier trying vendors in order
+func NewFailoverNotifier(vendors ...Notifier) (*FailoverNotifier, error) {
+	if len(vendors) == 0 {
+		return nil, errors.New("failover: at least one vendor is required")
+	}
+
+	f := &FailoverNotifier{
+		vendors: vendors,
+		names:   make([]string, len(vendors)),
+		stats:   make([]VendorStats, len(vendors)),
+		clock:   SystemClock,
+	}
+	for i, v := range vendors {
+		if v == nil {
+			return nil, fmt.Errorf("failover: vendor %d is nil", i)
+		}
+		f.names[i] = vendorName(v)
+		f.stats[i] = VendorStats{Vendor: f.names[i], Healthy: true}
+	}
+	return f, nil
+}
+
+// NewFailoverFromFactory builds one notifier per vendor with NotifierFactory
+func NewFailoverFromFactory(notifierType string, vendors ...string) (*FailoverNotifier, error) {
+	notifiers := make([]Notifier, 0, len(vendors))
+	for _, vendor := range vendors {
+		n, err := NotifierFactory(notifierType, vendor)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	}
+	return NewFailoverNotifier(notifiers...)
+}
+
+// Send tries the preferred vendor first, then the rest in configured order.
+// Permanent errors, including invalid messages, are about the message itself
+// and stop the chain.
+func (f *FailoverNotifier) Send(msg string) error {
+	return f.SendContext(context.Background(), msg)
+}
+
+// SendContext is Send bounded by ctx. Once ctx ends no further vendors are
+// tried and the context error is returned.
+func (f *FailoverNotifier) SendContext(ctx context.Context, msg string) error {
+	return f.SendMessage(ctx, Message{Body: msg})
+}
+
+// SendMessage fails a structured message over like SendContext
+func (f *FailoverNotifier) SendMessage(ctx context.Context, msg Message) error {
+	f.mu.Lock()
+	order := f.order()
+	f.mu.Unlock()
+
+	outcomes := make([]VendorOutcome, 0, len(order))
+	for _, i := range order {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		start := f.clock.Now()
+		err := SendMessage(ctx, f.vendors[i], msg)
+		outcomes = append(outcomes, VendorOutcome{Vendor: f.names[i], Err: err, Duration: f.clock.Now().Sub(start)})
+		f.record(i, err, outcomes)
+
+		if err == nil {
+			return nil
+		}
+		if IsPermanent(err) {
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+	}
+	return &FailoverError{Outcomes: outcomes}
+}
+
+// order returns vendor indexes starting with the preferred one
+func (f *FailoverNotifier) order() []int {
+	order := make([]int, 0, len(f.vendors))
+	order = append(order, f.preferred)
+	for i := range f.vendors {
+		if i != f.preferred {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+func (f *FailoverNotifier) record(i int, err error, outcomes []VendorOutcome) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := &f.stats[i]
+	if err == nil {
+		stats.Successes++
+		stats.Healthy = true
+		f.preferred = i
+	} else {
+		stats.Failures++
+		stats.LastError = err
+		stats.Healthy = false
+	}
+	f.last = append([]VendorOutcome(nil), outcomes...)
+}
+
+// Preferred returns the vendor that will be tried first on the next send
+func (f *FailoverNotifier) Preferred() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.names[f.preferred]
+}
+
+// Stats returns per-vendor counters in configured order
+func (f *FailoverNotifier) Stats() []VendorStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]VendorStats(nil), f.stats...)
+}
+
+// LastOutcomes returns the vendor attempts made by the most recent send
+func (f *FailoverNotifier) LastOutcomes() []VendorOutcome {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]VendorOutcome(nil), f.last...)
+}
+
+// vendorName identifies a notifier for stats and errors
+func vendorName(n Notifier) string {
+	if v, ok := n.(Vendored); ok {
+		return v.VendorName()
+	}
+	return fmt.Sprintf("%T", n)
+}
@@ -0,0 +1,179 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// FAILOVER TESTS
+// ============================================================================
+
+func TestFailoverNotifier_UsesPrimaryWhenHealthy(t *testing.T) {
+	primary := &scriptedNotifier{vendor: "twilio"}
+	backup := &scriptedNotifier{vendor: "nexmo"}
+
+	notifier, err := NewFailoverNotifier(primary, backup)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := notifier.Send("otp 1234"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if primary.Calls() != 1 || backup.Calls() != 0 {
+		t.Errorf("expected only primary to be used, got primary=%d backup=%d", primary.Calls(), backup.Calls())
+	}
+}
+
+func TestFailoverNotifier_FailsOverToBackup(t *testing.T) {
+	primary := &scriptedNotifier{vendor: "twilio", errs: []error{errVendorDown}}
+	backup := &scriptedNotifier{vendor: "nexmo"}
+
+	notifier, _ := NewFailoverNotifier(primary, backup)
+	if err := notifier.Send("otp 1234"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	outcomes := notifier.LastOutcomes()
+	if len(outcomes) != 2 {
+		t.Fatalf("expected 2 outcomes, got %d", len(outcomes))
+	}
+	if outcomes[0].Vendor != "twilio" || !errors.Is(outcomes[0].Err, errVendorDown) {
+		t.Errorf("expected twilio failure first, got %+v", outcomes[0])
+	}
+	if outcomes[1].Vendor != "nexmo" || outcomes[1].Err != nil {
+		t.Errorf("expected nexmo success second, got %+v", outcomes[1])
+	}
+}
+
+func TestFailoverNotifier_RemembersHealthyVendor(t *testing.T) {
+	primary := &scriptedNotifier{vendor: "twilio", errs: []error{errVendorDown}}
+	backup := &scriptedNotifier{vendor: "nexmo"}
+
+	notifier, _ := NewFailoverNotifier(primary, backup)
+	notifier.Send("first")
+
+	if notifier.Preferred() != "nexmo" {
+		t.Errorf("expected nexmo to be preferred, got '%s'", notifier.Preferred())
+	}
+
+	notifier.Send("second")
+	notifier.Send("third")
+	if primary.Calls() != 1 {
+		t.Errorf("expected failed primary to be skipped while backup is healthy, got %d calls", primary.Calls())
+	}
+	if backup.Calls() != 3 {
+		t.Errorf("expected backup to take all three sends, got %d", backup.Calls())
+	}
+}
+
+func TestFailoverNotifier_Stats(t *testing.T) {
+	primary := &scriptedNotifier{vendor: "twilio", errs: []error{errVendorDown}}
+	backup := &scriptedNotifier{vendor: "nexmo"}
+
+	notifier, _ := NewFailoverNotifier(primary, backup)
+	notifier.Send("first")
+	notifier.Send("second")
+
+	stats := notifier.Stats()
+	if stats[0].Vendor != "twilio" || stats[0].Failures != 1 || stats[0].Healthy {
+		t.Errorf("unexpected twilio stats: %+v", stats[0])
+	}
+	if !errors.Is(stats[0].LastError, errVendorDown) {
+		t.Errorf("expected twilio last error to be recorded, got %v", stats[0].LastError)
+	}
+	if stats[1].Vendor != "nexmo" || stats[1].Successes != 2 || !stats[1].Healthy {
+		t.Errorf("unexpected nexmo stats: %+v", stats[1])
+	}
+}
+
+func TestNewFailoverFromFactory(t *testing.T) {
+	notifier, err := NewFailoverFromFactory("sms", "twilio", "nexmo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stats := notifier.Stats()
+	if len(stats) != 2 || stats[0].Vendor != "twilio" || stats[1].Vendor != "nexmo" {
+		t.Errorf("expected twilio then nexmo, got %+v", stats)
+	}
+	if err := notifier.Send("hello"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestFailoverNotifier_AllVendorsFail(t *testing.T) {
+	errNexmo := errors.New("nexmo quota exceeded")
+	primary := &scriptedNotifier{vendor: "twilio", errs: []error{errVendorDown}}
+	backup := &scriptedNotifier{vendor: "nexmo", errs: []error{errNexmo}}
+
+	notifier, _ := NewFailoverNotifier(primary, backup)
+	err := notifier.Send("hello")
+
+	var failoverErr *FailoverError
+	if !errors.As(err, &failoverErr) {
+		t.Fatalf("expected *FailoverError, got %v", err)
+	}
+	if len(failoverErr.Outcomes) != 2 {
+		t.Errorf("expected 2 outcomes, got %d", len(failoverErr.Outcomes))
+	}
+	if !errors.Is(err, errVendorDown) || !errors.Is(err, errNexmo) {
+		t.Errorf("expected both vendor errors to be wrapped, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "twilio") || !strings.Contains(err.Error(), "nexmo") {
+		t.Errorf("expected vendors named in error, got '%s'", err.Error())
+	}
+}
+
+func TestFailoverNotifier_PermanentErrorStopsChain(t *testing.T) {
+	primary := &scriptedNotifier{vendor: "twilio", errs: []error{Permanent(errors.New("invalid number"))}}
+	backup := &scriptedNotifier{vendor: "nexmo"}
+
+	notifier, _ := NewFailoverNotifier(primary, backup)
+	if err := notifier.Send("hello"); !IsPermanent(err) {
+		t.Errorf("expected permanent error, got %v", err)
+	}
+	if backup.Calls() != 0 {
+		t.Errorf("expected backup to be skipped, got %d calls", backup.Calls())
+	}
+}
+
+func TestNewFailoverNotifier_InvalidVendors(t *testing.T) {
+	if _, err := NewFailoverNotifier(); err == nil {
+		t.Error("expected error for no vendors, got nil")
+	}
+	if _, err := NewFailoverNotifier(&scriptedNotifier{}, nil); err == nil {
+		t.Error("expected error for nil vendor, got nil")
+	}
+	if _, err := NewFailoverFromFactory("pager", "pagerduty"); !errors.Is(err, ErrUnknownNotifier) {
+		t.Errorf("expected ErrUnknownNotifier, got %v", err)
+	}
+}
+
+func TestFailoverNotifier_CancelStopsChain(t *testing.T) {
+	primary := newBlockingNotifier()
+	backup := &scriptedNotifier{vendor: "nexmo"}
+	notifier, _ := NewFailoverNotifier(primary, backup)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- notifier.SendContext(ctx, "hello") }()
+
+	<-primary.started
+	cancel()
+
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if err := <-primary.released; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected in-flight vendor to see cancellation, got %v", err)
+	}
+	if backup.Calls() != 0 {
+		t.Errorf("expected backup to be skipped after cancel, got %d calls", backup.Calls())
+	}
+}
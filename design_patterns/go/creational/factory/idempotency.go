@@ -0,0 +1,389 @@
+package factory
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSendInProgress is returned when a send with the same idempotency key
+// is still running. It is retryable: the caller will get the original
+// result once that send finishes.
+var ErrSendInProgress = errors.New("idempotency: send with this key is in progress")
+
+// IdempotencyRecord is the stored outcome of one keyed send
+type IdempotencyRecord struct {
+	Key       string    `json:"key"`
+	Err       string    `json:"err,omitempty"` // empty when the send succeeded
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// InFlight is true from Claim until Complete: the send has started
+	// but its outcome is not known yet
+	InFlight bool `json:"in_flight,omitempty"`
+}
+
+// ReplayedError is the original failure returned again for a repeated key.
+// Only permanent failures are recorded, so it is itself permanent.
+type ReplayedError struct {
+	Key string
+	Err string
+}
+
+func (e *ReplayedError) Error() string {
+	return fmt.Sprintf("idempotency: key %q already failed: %s", e.Key, e.Err)
+}
+
+// IdempotencyStore records which keys have been sent.
+// Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Claim reserves key for ttl and returns a record with InFlight set.
+	// If key is already claimed or recorded and not expired, Claim returns
+	// the existing record, InFlight included, and false.
+	Claim(key string, ttl time.Duration) (IdempotencyRecord, bool, error)
+	// Complete stores the outcome for a claimed key. The record passed in
+	// has InFlight cleared and must be returned that way by later Claims.
+	Complete(record IdempotencyRecord) error
+	// Release forgets a claimed key so it can be sent again
+	Release(key string) error
+}
+
+// idempotencySweepInterval is how often Claim drops expired records
+const idempotencySweepInterval = time.Minute
+
+// MemoryIdempotencyStore keeps records in memory. Expired records are
+// dropped by Claim at most once a minute, or at once by Sweep.
+type MemoryIdempotencyStore struct {
+	clock Clock
+
+	mu        sync.Mutex
+	records   map[string]IdempotencyRecord
+	nextSweep time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory store
+func NewMemoryIdempotencyStore(clock Clock) *MemoryIdempotencyStore {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &MemoryIdempotencyStore{clock: clock, records: make(map[string]IdempotencyRecord)}
+}
+
+func (s *MemoryIdempotencyStore) Claim(key string, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	if !now.Before(s.nextSweep) {
+		s.sweep(now)
+		s.nextSweep = now.Add(idempotencySweepInterval)
+	}
+	if existing, ok := s.records[key]; ok && now.Before(existing.ExpiresAt) {
+		return existing, false, nil
+	}
+	record := IdempotencyRecord{Key: key, CreatedAt: now, ExpiresAt: now.Add(ttl), InFlight: true}
+	s.records[key] = record
+	return record, true, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record.InFlight = false
+	s.records[record.Key] = record
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// Sweep drops expired records and returns how many were removed
+func (s *MemoryIdempotencyStore) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sweep(s.clock.Now())
+}
+
+func (s *MemoryIdempotencyStore) sweep(now time.Time) int {
+	removed := 0
+	for key, record := range s.records {
+		if !now.Before(record.ExpiresAt) {
+			delete(s.records, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Len returns the number of records held, including expired ones not yet swept
+func (s *MemoryIdempotencyStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
+
+// FileIdempotencyStore persists completed records as JSON lines so duplicates
+// are still suppressed after a restart. Claims for sends in progress are
+// kept in memory only.
+type FileIdempotencyStore struct {
+	*MemoryIdempotencyStore
+
+	path string
+
+	fileMu sync.Mutex
+	file   *os.File
+}
+
+// OpenFileIdempotencyStore loads unexpired records from path, creating the
+// file if needed, and rewrites it without the expired ones
+func OpenFileIdempotencyStore(path string, clock Clock) (*FileIdempotencyStore, error) {
+	s := &FileIdempotencyStore{MemoryIdempotencyStore: NewMemoryIdempotencyStore(clock), path: path}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.Compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileIdempotencyStore) load() error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("idempotency: open %s: %w", s.path, err)
+	}
+	defer f.Close()
+
+	now := s.clock.Now()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var torn error
+	for line := 1; scanner.Scan(); line++ {
+		if torn != nil {
+			return torn
+		}
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record IdempotencyRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// A torn final line from a crash is dropped; corruption
+			// anywhere else is reported
+			torn = fmt.Errorf("idempotency: %s line %d: %w", s.path, line, err)
+			continue
+		}
+		if now.Before(record.ExpiresAt) {
+			s.records[record.Key] = record
+		}
+	}
+	return scanner.Err()
+}
+
+// Complete stores the outcome and appends it to the file
+func (s *FileIdempotencyStore) Complete(record IdempotencyRecord) error {
+	record.InFlight = false
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("idempotency: encode record: %w", err)
+	}
+
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("idempotency: %s is closed", s.path)
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("idempotency: write %s: %w", s.path, err)
+	}
+	return s.MemoryIdempotencyStore.Complete(record)
+}
+
+// Compact rewrites the file with only completed, unexpired records
+func (s *FileIdempotencyStore) Compact() error {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+
+	s.mu.Lock()
+	now := s.clock.Now()
+	var live []IdempotencyRecord
+	for key, record := range s.records {
+		if !now.Before(record.ExpiresAt) {
+			delete(s.records, key)
+			continue
+		}
+		if !record.InFlight {
+			live = append(live, record)
+		}
+	}
+	s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("idempotency: compact: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, record := range live {
+		if err := enc.Encode(record); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return fmt.Errorf("idempotency: compact: %w", err)
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("idempotency: compact: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("idempotency: compact: %w", err)
+	}
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("idempotency: reopen %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Close closes the underlying file
+func (s *FileIdempotencyStore) Close() error {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// IdempotencyPolicy configures an IdempotentNotifier
+type IdempotencyPolicy struct {
+	Store IdempotencyStore // defaults to an in-memory store
+	TTL   time.Duration    // how long a key suppresses duplicates, defaults to 24h
+
+	// Key derives the idempotency key for a message. Defaults to
+	// Message.IdempotencyKey; messages without a key are not deduplicated.
+	Key func(Message) string
+}
+
+// IdempotentNotifier suppresses repeated sends of the same idempotency key
+// and returns the original result instead. Successes and permanent failures
+// are recorded; other failures release the key so the send can be retried.
+type IdempotentNotifier struct {
+	next   Notifier
+	policy IdempotencyPolicy
+
+	duplicates atomic.Int64
+}
+
+// NewIdempotentNotifier wraps next with the given policy, filling in defaults
+func NewIdempotentNotifier(next Notifier, policy IdempotencyPolicy) *IdempotentNotifier {
+	if policy.Store == nil {
+		policy.Store = NewMemoryIdempotencyStore(nil)
+	}
+	if policy.TTL <= 0 {
+		policy.TTL = 24 * time.Hour
+	}
+	if policy.Key == nil {
+		policy.Key = func(m Message) string { return m.IdempotencyKey }
+	}
+	return &IdempotentNotifier{next: next, policy: policy}
+}
+
+// ContentKey derives a key from the recipients, subject and body, for
+// callers that cannot supply their own idempotency keys
+func ContentKey(m Message) string {
+	h := sha256.New()
+	for _, part := range append(m.Recipients(), m.Subject, m.Body, m.HTMLBody) {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VendorName passes through the wrapped notifier's vendor
+func (n *IdempotentNotifier) VendorName() string {
+	return vendorName(n.next)
+}
+
+// Send sends msg; with the default policy a bare string has no key
+func (n *IdempotentNotifier) Send(msg string) error {
+	return n.SendContext(context.Background(), msg)
+}
+
+// SendContext is Send bounded by ctx
+func (n *IdempotentNotifier) SendContext(ctx context.Context, msg string) error {
+	return n.SendMessage(ctx, Message{Body: msg})
+}
+
+// SendMessage sends msg unless its key was already sent within the TTL
+func (n *IdempotentNotifier) SendMessage(ctx context.Context, msg Message) error {
+	key := n.policy.Key(msg)
+	if key == "" {
+		return SendMessage(ctx, n.next, msg)
+	}
+
+	record, claimed, err := n.policy.Store.Claim(key, n.policy.TTL)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		n.duplicates.Add(1)
+		switch {
+		case record.InFlight:
+			return ErrSendInProgress
+		case record.Err != "":
+			return Permanent(&ReplayedError{Key: key, Err: record.Err})
+		}
+		return nil
+	}
+
+	sendErr := SendMessage(ctx, n.next, msg)
+	if sendErr != nil && !IsPermanent(sendErr) {
+		if err := n.policy.Store.Release(key); err != nil {
+			return errors.Join(sendErr, err)
+		}
+		return sendErr
+	}
+
+	record.InFlight = false
+	if sendErr != nil {
+		record.Err = sendErr.Error()
+	}
+	if err := n.policy.Store.Complete(record); err != nil {
+		return errors.Join(sendErr, err)
+	}
+	return sendErr
+}
+
+// Duplicates returns how many sends were suppressed
+func (n *IdempotentNotifier) Duplicates() int64 {
+	return n.duplicates.Load()
+}
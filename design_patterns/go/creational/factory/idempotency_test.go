@@ -0,0 +1,314 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ============================================================================
+// IDEMPOTENCY TESTS
+// ============================================================================
+
+func TestIdempotentNotifier_SuppressesDuplicates(t *testing.T) {
+	inner := &messageRecorder{}
+	notifier := NewIdempotentNotifier(inner, IdempotencyPolicy{})
+	msg := Message{To: []string{"+14155552671"}, Body: "code 1234", IdempotencyKey: "otp-42"}
+
+	for i := 0; i < 3; i++ {
+		if err := notifier.SendMessage(context.Background(), msg); err != nil {
+			t.Fatalf("attempt %d: unexpected error: %v", i+1, err)
+		}
+	}
+	if inner.Calls() != 1 {
+		t.Errorf("expected 1 send, got %d", inner.Calls())
+	}
+	if notifier.Duplicates() != 2 {
+		t.Errorf("expected 2 duplicates, got %d", notifier.Duplicates())
+	}
+}
+
+func TestIdempotentNotifier_KeyExpires(t *testing.T) {
+	clock := newFakeClock()
+	inner := &messageRecorder{}
+	notifier := NewIdempotentNotifier(inner, IdempotencyPolicy{
+		Store: NewMemoryIdempotencyStore(clock),
+		TTL:   time.Minute,
+	})
+	msg := Message{Body: "hello", IdempotencyKey: "k"}
+
+	notifier.SendMessage(context.Background(), msg)
+	clock.Advance(59 * time.Second)
+	notifier.SendMessage(context.Background(), msg)
+	clock.Advance(time.Second)
+	notifier.SendMessage(context.Background(), msg)
+
+	if inner.Calls() != 2 {
+		t.Errorf("expected a resend once the TTL passed, got %d sends", inner.Calls())
+	}
+}
+
+func TestMemoryIdempotencyStore_ClaimEvictsExpired(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryIdempotencyStore(clock)
+	for _, key := range []string{"a", "b", "c"} {
+		store.Claim(key, time.Second)
+	}
+
+	clock.Advance(idempotencySweepInterval)
+	store.Claim("d", time.Hour)
+	if store.Len() != 1 {
+		t.Errorf("expected the expired keys evicted by Claim, got %d records", store.Len())
+	}
+}
+
+func TestIdempotentNotifier_NoKeyPassesThrough(t *testing.T) {
+	inner := &scriptedNotifier{}
+	notifier := NewIdempotentNotifier(inner, IdempotencyPolicy{})
+
+	notifier.Send("same")
+	notifier.Send("same")
+	if inner.Calls() != 2 {
+		t.Errorf("expected unkeyed sends to pass through, got %d", inner.Calls())
+	}
+}
+
+func TestIdempotentNotifier_ContentKey(t *testing.T) {
+	inner := &scriptedNotifier{}
+	notifier := NewIdempotentNotifier(inner, IdempotencyPolicy{Key: ContentKey})
+
+	notifier.Send("same")
+	notifier.Send("same")
+	notifier.Send("different")
+	if inner.Calls() != 2 {
+		t.Errorf("expected identical content to be deduplicated, got %d sends", inner.Calls())
+	}
+	if ContentKey(Message{To: []string{"a"}, Body: "b"}) == ContentKey(Message{To: []string{"ab"}}) {
+		t.Error("expected field boundaries to change the key")
+	}
+}
+
+func TestIdempotentNotifier_TransientFailureCanBeRetried(t *testing.T) {
+	inner := &messageRecorder{scriptedNotifier: scriptedNotifier{errs: []error{errVendorDown}}}
+	notifier := NewIdempotentNotifier(inner, IdempotencyPolicy{})
+	msg := Message{Body: "hello", IdempotencyKey: "k"}
+
+	if err := notifier.SendMessage(context.Background(), msg); !errors.Is(err, errVendorDown) {
+		t.Fatalf("expected vendor error, got %v", err)
+	}
+	if err := notifier.SendMessage(context.Background(), msg); err != nil {
+		t.Fatalf("expected retry to go through, got %v", err)
+	}
+	if inner.Calls() != 2 {
+		t.Errorf("expected 2 attempts, got %d", inner.Calls())
+	}
+}
+
+func TestIdempotentNotifier_InsideRetry(t *testing.T) {
+	clock := newFakeClock()
+	clock.autoAdvance = true
+	inner := &messageRecorder{scriptedNotifier: scriptedNotifier{errs: []error{errVendorDown}}}
+	notifier := NewRetryNotifier(NewIdempotentNotifier(inner, IdempotencyPolicy{}), RetryPolicy{Clock: clock})
+
+	msg := Message{Body: "hello", IdempotencyKey: "event-7"}
+	if err := notifier.SendMessage(context.Background(), msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A duplicate event after a successful retry is still suppressed
+	if err := notifier.SendMessage(context.Background(), msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(inner.Messages()) != 1 {
+		t.Errorf("expected exactly one delivery, got %d", len(inner.Messages()))
+	}
+}
+
+func TestFileIdempotencyStore_SurvivesRestart(t *testing.T) {
+	clock := newFakeClock()
+	path := filepath.Join(t.TempDir(), "idempotency.log")
+
+	store, err := OpenFileIdempotencyStore(path, clock)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	inner := &messageRecorder{}
+	notifier := NewIdempotentNotifier(inner, IdempotencyPolicy{Store: store, TTL: time.Hour})
+	notifier.SendMessage(context.Background(), Message{Body: "a", IdempotencyKey: "first"})
+	store.Close()
+
+	store, _ = OpenFileIdempotencyStore(path, clock)
+	notifier = NewIdempotentNotifier(inner, IdempotencyPolicy{Store: store, TTL: time.Hour})
+	notifier.SendMessage(context.Background(), Message{Body: "a", IdempotencyKey: "first"})
+	notifier.SendMessage(context.Background(), Message{Body: "b", IdempotencyKey: "second"})
+	store.Close()
+
+	if inner.Calls() != 2 {
+		t.Fatalf("expected the duplicate to be suppressed after restart, got %d sends", inner.Calls())
+	}
+
+	clock.Advance(2 * time.Hour)
+	store, err = OpenFileIdempotencyStore(path, clock)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.Close()
+	if store.Len() != 0 {
+		t.Errorf("expected expired records to be dropped, got %d", store.Len())
+	}
+	content, _ := os.ReadFile(path)
+	if len(strings.TrimSpace(string(content))) != 0 {
+		t.Errorf("expected compaction to empty the file, got %q", content)
+	}
+}
+
+func TestFileIdempotencyStore_TornFinalLine(t *testing.T) {
+	clock := newFakeClock()
+	path := filepath.Join(t.TempDir(), "idempotency.log")
+
+	store, _ := OpenFileIdempotencyStore(path, clock)
+	record, _, _ := store.Claim("k", time.Hour)
+	store.Complete(record)
+	store.Close()
+
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString(`{"key":"half`)
+	f.Close()
+
+	store, err := OpenFileIdempotencyStore(path, clock)
+	if err != nil {
+		t.Fatalf("expected torn final line to be ignored, got %v", err)
+	}
+	defer store.Close()
+	if _, claimed, _ := store.Claim("k", time.Hour); claimed {
+		t.Error("expected the complete record to survive")
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestIdempotentNotifier_ReplaysPermanentFailure(t *testing.T) {
+	inner := &messageRecorder{scriptedNotifier: scriptedNotifier{errs: []error{Permanent(errors.New("number blocked"))}}}
+	notifier := NewIdempotentNotifier(inner, IdempotencyPolicy{})
+	msg := Message{Body: "hello", IdempotencyKey: "k"}
+
+	notifier.SendMessage(context.Background(), msg)
+	err := notifier.SendMessage(context.Background(), msg)
+
+	var replayed *ReplayedError
+	if !errors.As(err, &replayed) || replayed.Err != "number blocked" {
+		t.Fatalf("expected replayed 'number blocked', got %v", err)
+	}
+	if !IsPermanent(err) {
+		t.Error("expected replayed failure to be permanent")
+	}
+	if inner.Calls() != 1 {
+		t.Errorf("expected 1 send, got %d", inner.Calls())
+	}
+}
+
+func TestIdempotentNotifier_ConcurrentDuplicateInProgress(t *testing.T) {
+	inner := newGatedNotifier()
+	notifier := NewIdempotentNotifier(inner, IdempotencyPolicy{Key: func(Message) string { return "k" }})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		notifier.Send("first")
+	}()
+	<-inner.started
+
+	if err := notifier.Send("second"); !errors.Is(err, ErrSendInProgress) {
+		t.Errorf("expected ErrSendInProgress, got %v", err)
+	}
+	close(inner.gate)
+	wg.Wait()
+
+	if err := notifier.Send("third"); err != nil {
+		t.Errorf("expected original success to be returned, got %v", err)
+	}
+	if got := inner.Sent(); len(got) != 1 {
+		t.Errorf("expected a single delivery, got %v", got)
+	}
+}
+
+// mapIdempotencyStore is a store written against the exported API only,
+// as a store outside this package would be
+type mapIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+}
+
+func (s *mapIdempotencyStore) Claim(key string, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.records[key]; ok {
+		return existing, false, nil
+	}
+	now := time.Now()
+	record := IdempotencyRecord{Key: key, CreatedAt: now, ExpiresAt: now.Add(ttl), InFlight: true}
+	s.records[key] = record
+	return record, true, nil
+}
+
+func (s *mapIdempotencyStore) Complete(record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.Key] = record
+	return nil
+}
+
+func (s *mapIdempotencyStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func TestIdempotentNotifier_CustomStoreInProgress(t *testing.T) {
+	store := &mapIdempotencyStore{records: make(map[string]IdempotencyRecord)}
+	inner := newGatedNotifier()
+	notifier := NewIdempotentNotifier(inner, IdempotencyPolicy{Store: store, Key: func(Message) string { return "k" }})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		notifier.Send("first")
+	}()
+	<-inner.started
+
+	if err := notifier.Send("second"); !errors.Is(err, ErrSendInProgress) {
+		t.Errorf("expected ErrSendInProgress from a custom store, got %v", err)
+	}
+	close(inner.gate)
+	wg.Wait()
+
+	if record := store.records["k"]; record.InFlight {
+		t.Error("expected Complete to receive the record with InFlight cleared")
+	}
+}
+
+func TestFileIdempotencyStore_CorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.log")
+	os.WriteFile(path, []byte("not json\n{\"key\":\"k\"}\n"), 0o644)
+
+	if _, err := OpenFileIdempotencyStore(path, newFakeClock()); err == nil {
+		t.Error("expected error for corrupt store, got nil")
+	}
+}
+
+func TestFileIdempotencyStore_CompleteAfterClose(t *testing.T) {
+	store, _ := OpenFileIdempotencyStore(filepath.Join(t.TempDir(), "idempotency.log"), newFakeClock())
+	record, _, _ := store.Claim("k", time.Hour)
+	store.Close()
+
+	if err := store.Complete(record); err == nil {
+		t.Error("expected error after Close, got nil")
+	}
+}
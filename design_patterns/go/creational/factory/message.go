@@ -0,0 +1,167 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidMessage is matched by every *ValidationError.
+// Invalid messages are treated as permanent failures by retry and failover.
+var ErrInvalidMessage = errors.New("invalid message")
+
+// Priority tells queues and vendors how urgent a message is
+type Priority int
+
+const (
+	PriorityBulk     Priority = -1
+	PriorityNormal   Priority = 0
+	PriorityHigh     Priority = 1
+	PriorityCritical Priority = 2
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityBulk:
+		return "bulk"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	case PriorityCritical:
+		return "critical"
+	}
+	return fmt.Sprintf("priority(%d)", int(p))
+}
+
+// Attachment is a file sent along with a message
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"` // defaults to application/octet-stream
+	Data        []byte `json:"data"`
+}
+
+// Message is a structured notification.
+// Notifier.Send(s) is equivalent to sending Message{Body: s}.
+type Message struct {
+	To          []string // email addresses, E.164 numbers or device tokens
+	CC          []string // email only
+	Subject     string
+	Body        string // plain text body
+	HTMLBody    string // email only, sent as multipart/alternative with Body
+	Priority    Priority
+	Metadata    map[string]string
+	Attachments []Attachment // email and webhook only
+
+	// IdempotencyKey identifies one logical send; IdempotentNotifier
+	// suppresses repeats of the same key
+	IdempotencyKey string
+}
+
+// Recipients returns To followed by CC
+func (m Message) Recipients() []string {
+	return append(append([]string(nil), m.To...), m.CC...)
+}
+
+// ValidationError describes one field that is not valid for a channel
+type ValidationError struct {
+	Channel string
+	Field   string // e.g. "To[0]"
+	Value   string
+	Reason  string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%s: %s %s", e.Channel, e.Field, e.Reason)
+	}
+	return fmt.Sprintf("%s: %s %q %s", e.Channel, e.Field, e.Value, e.Reason)
+}
+
+// Is makes every ValidationError match ErrInvalidMessage
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidMessage
+}
+
+// e164 is a leading '+', a non-zero country code digit and up to 15 digits in total
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// Validate checks the message against the rules of a channel and returns
+// every problem found, joined. Recipients are optional because notifiers
+// may fall back to configured defaults, but those present must be valid:
+//
+//   - email: To and CC must be RFC 5322 addresses
+//   - sms:   To must be E.164 numbers; no CC, HTML body or attachments
+//   - push:  To must be non-empty device tokens; no CC, HTML body or attachments
+//
+// Other channels only require a body.
+func (m Message) Validate(channel string) error {
+	var errs []error
+	invalid := func(field, value, reason string) {
+		errs = append(errs, &ValidationError{Channel: channel, Field: field, Value: value, Reason: reason})
+	}
+
+	if strings.TrimSpace(m.Body) == "" && strings.TrimSpace(m.HTMLBody) == "" {
+		invalid("Body", "", "is required")
+	}
+
+	switch channel {
+	case "email":
+		for i, address := range m.To {
+			if _, err := mail.ParseAddress(address); err != nil {
+				invalid(fmt.Sprintf("To[%d]", i), address, "is not a valid RFC 5322 address")
+			}
+		}
+		for i, address := range m.CC {
+			if _, err := mail.ParseAddress(address); err != nil {
+				invalid(fmt.Sprintf("CC[%d]", i), address, "is not a valid RFC 5322 address")
+			}
+		}
+	case "sms":
+		for i, number := range m.To {
+			if !e164.MatchString(number) {
+				invalid(fmt.Sprintf("To[%d]", i), number, "is not an E.164 phone number")
+			}
+		}
+		m.rejectEmailOnly(invalid)
+	case "push":
+		for i, token := range m.To {
+			if strings.TrimSpace(token) == "" {
+				invalid(fmt.Sprintf("To[%d]", i), "", "must be a device token")
+			}
+		}
+		m.rejectEmailOnly(invalid)
+	}
+	return errors.Join(errs...)
+}
+
+func (m Message) rejectEmailOnly(invalid func(field, value, reason string)) {
+	if len(m.CC) > 0 {
+		invalid("CC", "", "is not supported")
+	}
+	if m.HTMLBody != "" {
+		invalid("HTMLBody", "", "is not supported")
+	}
+	if len(m.Attachments) > 0 {
+		invalid("Attachments", "", "are not supported")
+	}
+}
+
+// MessageNotifier is a Notifier that accepts structured messages.
+// All built-in notifiers and wrappers implement it.
+type MessageNotifier interface {
+	ContextNotifier
+	SendMessage(ctx context.Context, msg Message) error
+}
+
+// SendMessage sends msg through n, using SendMessage when n supports it.
+// Other notifiers receive only msg.Body.
+func SendMessage(ctx context.Context, n Notifier, msg Message) error {
+	if mn, ok := n.(MessageNotifier); ok {
+		return mn.SendMessage(ctx, msg)
+	}
+	return SendWithContext(ctx, n, msg.Body)
+}
@@ -0,0 +1,334 @@
+package factory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Compile-time checks that built-ins and wrappers accept structured messages
+var (
+	_ MessageNotifier = (*EmailNotifier)(nil)
+	_ MessageNotifier = (*SmsNotifier)(nil)
+	_ MessageNotifier = (*PushNotifier)(nil)
+	_ MessageNotifier = (*WebhookNotifier)(nil)
+	_ MessageNotifier = (*RetryNotifier)(nil)
+	_ MessageNotifier = (*FailoverNotifier)(nil)
+	_ MessageNotifier = (*Dispatcher)(nil)
+	_ MessageNotifier = (*RateLimitNotifier)(nil)
+	_ MessageNotifier = (*IdempotentNotifier)(nil)
+	_ MessageNotifier = (*BroadcastNotifier)(nil)
+	_ MessageNotifier = (*TrackingNotifier)(nil)
+	_ MessageNotifier = (*InstrumentedNotifier)(nil)
+	_ MessageNotifier = (*CircuitBreakerNotifier)(nil)
+	_ MessageNotifier = (*Router)(nil)
+	_ MessageNotifier = (*DigestNotifier)(nil)
+)
+
+// messageRecorder records every structured message it receives
+type messageRecorder struct {
+	scriptedNotifier
+	messages []Message
+}
+
+func (m *messageRecorder) SendContext(ctx context.Context, msg string) error {
+	return m.SendMessage(ctx, Message{Body: msg})
+}
+
+func (m *messageRecorder) SendMessage(ctx context.Context, msg Message) error {
+	if err := m.scriptedNotifier.Send(msg.Body); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func (m *messageRecorder) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// ============================================================================
+// MESSAGE TESTS
+// ============================================================================
+
+func TestMessage_ValidPerChannel(t *testing.T) {
+	testCases := []struct {
+		channel string
+		msg     Message
+	}{
+		{"email", Message{To: []string{"Alice <alice@example.com>"}, CC: []string{"bob@example.com"}, Body: "hi", HTMLBody: "<p>hi</p>"}},
+		{"email", Message{Body: "no recipients falls back to config"}},
+		{"sms", Message{To: []string{"+14155552671", "+447911123456"}, Body: "code 1234"}},
+		{"push", Message{To: []string{"device-token-1"}, Body: "ping"}},
+		{"webhook", Message{Body: "event", Attachments: []Attachment{{Filename: "a.txt", Data: []byte("x")}}}},
+		{"slack", Message{HTMLBody: "<b>html only</b>"}},
+	}
+
+	for _, tc := range testCases {
+		if err := tc.msg.Validate(tc.channel); err != nil {
+			t.Errorf("%s: expected valid message, got %v", tc.channel, err)
+		}
+	}
+}
+
+func TestMessage_Recipients(t *testing.T) {
+	msg := Message{To: []string{"a@example.com"}, CC: []string{"b@example.com"}}
+	expected := []string{"a@example.com", "b@example.com"}
+	if got := msg.Recipients(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestPriority_String(t *testing.T) {
+	expected := map[Priority]string{
+		PriorityBulk:     "bulk",
+		PriorityNormal:   "normal",
+		PriorityHigh:     "high",
+		PriorityCritical: "critical",
+		Priority(7):      "priority(7)",
+	}
+	for p, want := range expected {
+		if got := p.String(); got != want {
+			t.Errorf("expected '%s', got '%s'", want, got)
+		}
+	}
+}
+
+func TestSendMessage_LegacyNotifierGetsBody(t *testing.T) {
+	inner := &scriptedNotifier{}
+	msg := Message{To: []string{"+14155552671"}, Subject: "ignored", Body: "hello"}
+
+	if err := SendMessage(context.Background(), inner, msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(inner.sent, []string{"hello"}) {
+		t.Errorf("expected body to be sent, got %v", inner.sent)
+	}
+}
+
+func TestEmailNotifier_SMTPMessageOverridesConfig(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	server.start()
+
+	notifier := NewSMTPEmailNotifier("postfix", newTestSMTPConfig(server))
+	err := notifier.SendMessage(context.Background(), Message{
+		To:       []string{"carol@example.com"},
+		CC:       []string{"dave@example.com"},
+		Subject:  "Override",
+		Body:     "hello",
+		Priority: PriorityCritical,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	commands, _, data := server.snapshot()
+	joined := strings.Join(commands, "\n")
+	for _, want := range []string{"RCPT TO:<carol@example.com>", "RCPT TO:<dave@example.com>"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected %q, got %v", want, commands)
+		}
+	}
+	if strings.Contains(joined, "alice@example.com") {
+		t.Errorf("expected configured recipients to be replaced, got %v", commands)
+	}
+	for _, header := range []string{"To: carol@example.com\r\n", "Cc: dave@example.com\r\n", "Subject: Override\r\n", "X-Priority: 1 (Highest)\r\n"} {
+		if !strings.Contains(data, header) {
+			t.Errorf("expected header %q in message:\n%s", header, data)
+		}
+	}
+}
+
+func TestEmailNotifier_SMTPHTMLAndAttachments(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	server.start()
+
+	notifier := NewSMTPEmailNotifier("postfix", newTestSMTPConfig(server))
+	err := notifier.SendMessage(context.Background(), Message{
+		Body:        "plain version",
+		HTMLBody:    "<p>html version</p>",
+		Attachments: []Attachment{{Filename: "report.csv", ContentType: "text/csv", Data: []byte("a,b\n1,2\n")}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, _, data := server.snapshot()
+	parsed, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("invalid message: %v", err)
+	}
+	mediaType, params, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if mediaType != "multipart/mixed" {
+		t.Fatalf("expected multipart/mixed, got '%s'", mediaType)
+	}
+
+	mixed := multipart.NewReader(parsed.Body, params["boundary"])
+	body, err := mixed.NextPart()
+	if err != nil {
+		t.Fatalf("missing body part: %v", err)
+	}
+	bodyType, bodyParams, _ := mime.ParseMediaType(body.Header.Get("Content-Type"))
+	if bodyType != "multipart/alternative" {
+		t.Fatalf("expected multipart/alternative, got '%s'", bodyType)
+	}
+	alternative := multipart.NewReader(body, bodyParams["boundary"])
+	var texts []string
+	for {
+		part, err := alternative.NextPart()
+		if err != nil {
+			break
+		}
+		content, _ := io.ReadAll(part)
+		texts = append(texts, part.Header.Get("Content-Type")+"|"+string(content))
+	}
+	expected := []string{"text/plain; charset=utf-8|plain version", "text/html; charset=utf-8|<p>html version</p>"}
+	if !reflect.DeepEqual(texts, expected) {
+		t.Errorf("expected %v, got %v", expected, texts)
+	}
+
+	attachment, err := mixed.NextPart()
+	if err != nil {
+		t.Fatalf("missing attachment: %v", err)
+	}
+	if attachment.FileName() != "report.csv" {
+		t.Errorf("expected filename 'report.csv', got '%s'", attachment.FileName())
+	}
+	if encoding := attachment.Header.Get("Content-Transfer-Encoding"); encoding != "base64" {
+		t.Errorf("expected base64 encoding, got '%s'", encoding)
+	}
+}
+
+func TestWebhookNotifier_MessageFields(t *testing.T) {
+	server, received := newWebhookServer(t, http.StatusOK)
+	notifier := NewWebhookNotifier("billing", server.URL, "")
+
+	err := notifier.SendMessage(context.Background(), Message{
+		To:       []string{"acct-42"},
+		Subject:  "Invoice",
+		Body:     "invoice paid",
+		Priority: PriorityHigh,
+		Metadata: map[string]string{"invoice": "INV-1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var payload WebhookPayload
+	json.Unmarshal((<-received).body, &payload)
+	if payload.Message != "invoice paid" || payload.Subject != "Invoice" || payload.Priority != "high" {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+	if payload.Metadata["invoice"] != "INV-1" || !reflect.DeepEqual(payload.To, []string{"acct-42"}) {
+		t.Errorf("expected recipients and metadata, got %+v", payload)
+	}
+}
+
+func TestDispatcher_DeliversStructuredMessages(t *testing.T) {
+	inner := &messageRecorder{}
+	d, _ := NewDispatcher(DispatcherConfig{Notifier: inner, Workers: 1})
+
+	msg := Message{To: []string{"+14155552671"}, Body: "queued", Priority: PriorityHigh}
+	if err := d.SendMessage(context.Background(), msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.Shutdown(context.Background())
+
+	if got := inner.Messages(); len(got) != 1 || !reflect.DeepEqual(got[0], msg) {
+		t.Errorf("expected message delivered intact, got %+v", got)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestMessage_InvalidPerChannel(t *testing.T) {
+	testCases := []struct {
+		name    string
+		channel string
+		msg     Message
+		fields  []string
+	}{
+		{"empty body", "webhook", Message{}, []string{"Body"}},
+		{"bad email", "email", Message{To: []string{"not-an-address"}, CC: []string{"@example.com"}, Body: "x"}, []string{"To[0]", "CC[0]"}},
+		{"sms without plus", "sms", Message{To: []string{"4155552671"}, Body: "x"}, []string{"To[0]"}},
+		{"sms leading zero", "sms", Message{To: []string{"+04155552671"}, Body: "x"}, []string{"To[0]"}},
+		{"sms too long", "sms", Message{To: []string{"+1234567890123456"}, Body: "x"}, []string{"To[0]"}},
+		{"sms email-only fields", "sms", Message{CC: []string{"+14155552671"}, Body: "x", HTMLBody: "<p>", Attachments: []Attachment{{}}}, []string{"CC", "HTMLBody", "Attachments"}},
+		{"push blank token", "push", Message{To: []string{" "}, Body: "x"}, []string{"To[0]"}},
+	}
+
+	for _, tc := range testCases {
+		err := tc.msg.Validate(tc.channel)
+		if !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("%s: expected ErrInvalidMessage, got %v", tc.name, err)
+			continue
+		}
+		for _, field := range tc.fields {
+			if !strings.Contains(err.Error(), field) {
+				t.Errorf("%s: expected error to mention %s, got %v", tc.name, field, err)
+			}
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Channel != tc.channel {
+			t.Errorf("%s: expected *ValidationError for channel %s, got %v", tc.name, tc.channel, err)
+		}
+	}
+}
+
+func TestBuiltins_RejectInvalidMessages(t *testing.T) {
+	testCases := []struct {
+		notifier MessageNotifier
+		msg      Message
+	}{
+		{&EmailNotifier{Vendor: "gmail"}, Message{To: []string{"nobody"}, Body: "x"}},
+		{&SmsNotifier{Vendor: "twilio"}, Message{To: []string{"555-1234"}, Body: "x"}},
+		{&PushNotifier{Vendor: "firebase"}, Message{To: []string{""}, Body: "x"}},
+		{NewWebhookNotifier("billing", "http://127.0.0.1:1", ""), Message{}},
+	}
+
+	for _, tc := range testCases {
+		if err := tc.notifier.SendMessage(context.Background(), tc.msg); !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("%T: expected ErrInvalidMessage, got %v", tc.notifier, err)
+		}
+	}
+}
+
+func TestRetryNotifier_InvalidMessageNotRetried(t *testing.T) {
+	clock := newFakeClock()
+	clock.autoAdvance = true
+	inner := &messageRecorder{scriptedNotifier: scriptedNotifier{errs: []error{&ValidationError{Channel: "sms", Field: "To[0]"}}}}
+
+	err := NewRetryNotifier(inner, RetryPolicy{MaxAttempts: 5, Clock: clock}).SendMessage(context.Background(), Message{Body: "x"})
+	if !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("expected ErrInvalidMessage, got %v", err)
+	}
+	if inner.Calls() != 1 {
+		t.Errorf("expected a single attempt, got %d", inner.Calls())
+	}
+}
+
+func TestFailoverNotifier_InvalidMessageStopsChain(t *testing.T) {
+	primary := &SmsNotifier{Vendor: "twilio"}
+	backup := &messageRecorder{scriptedNotifier: scriptedNotifier{vendor: "nexmo"}}
+	notifier, _ := NewFailoverNotifier(primary, backup)
+
+	err := notifier.SendMessage(context.Background(), Message{To: []string{"12345"}, Body: "x"})
+	if !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("expected ErrInvalidMessage, got %v", err)
+	}
+	if backup.Calls() != 0 {
+		t.Errorf("expected backup to be skipped, got %d calls", backup.Calls())
+	}
+}
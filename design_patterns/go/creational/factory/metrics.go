@@ -0,0 +1,247 @@
+package factory
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the histogram upper bounds in seconds
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metricsKey labels one series
+type metricsKey struct {
+	channel, vendor string
+}
+
+// metricsSeries holds the counters for one channel/vendor pair
+type metricsSeries struct {
+	sends   uint64
+	errors  uint64
+	buckets []uint64 // per bucket, not cumulative; the last is +Inf
+	total   time.Duration
+}
+
+// Metrics collects send counts, errors and latency histograms by channel
+// and vendor, and exposes them in the Prometheus text format.
+// It is safe for concurrent use.
+type Metrics struct {
+	buckets []float64
+	clock   Clock
+
+	mu     sync.Mutex
+	series map[metricsKey]*metricsSeries
+}
+
+// NewMetrics creates an empty collector. buckets are histogram upper bounds
+// in seconds and default to DefaultLatencyBuckets.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{buckets: buckets, clock: SystemClock, series: make(map[metricsKey]*metricsSeries)}
+}
+
+// Observe records one send
+func (m *Metrics) Observe(channel, vendor string, latency time.Duration, err error) {
+	seconds := latency.Seconds()
+	bucket := sort.SearchFloat64s(m.buckets, seconds)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := metricsKey{channel, vendor}
+	s, ok := m.series[key]
+	if !ok {
+		s = &metricsSeries{buckets: make([]uint64, len(m.buckets)+1)}
+		m.series[key] = s
+	}
+	s.sends++
+	if err != nil {
+		s.errors++
+	}
+	s.buckets[bucket]++
+	s.total += latency
+}
+
+// MetricsSnapshot is a point-in-time copy of one series
+type MetricsSnapshot struct {
+	Channel, Vendor string
+	Sends, Errors   uint64
+	LatencySum      time.Duration
+}
+
+// Snapshot returns every series, sorted by channel then vendor
+func (m *Metrics) Snapshot() []MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshots := make([]MetricsSnapshot, 0, len(m.series))
+	for key, s := range m.series {
+		snapshots = append(snapshots, MetricsSnapshot{
+			Channel:    key.channel,
+			Vendor:     key.vendor,
+			Sends:      s.sends,
+			Errors:     s.errors,
+			LatencySum: s.total,
+		})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].Channel != snapshots[j].Channel {
+			return snapshots[i].Channel < snapshots[j].Channel
+		}
+		return snapshots[i].Vendor < snapshots[j].Vendor
+	})
+	return snapshots
+}
+
+// WriteTo writes every series in the Prometheus text exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	keys := make([]metricsKey, 0, len(m.series))
+	series := make(map[metricsKey]metricsSeries, len(m.series))
+	for key, s := range m.series {
+		keys = append(keys, key)
+		copied := *s
+		copied.buckets = append([]uint64(nil), s.buckets...)
+		series[key] = copied
+	}
+	m.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].channel != keys[j].channel {
+			return keys[i].channel < keys[j].channel
+		}
+		return keys[i].vendor < keys[j].vendor
+	})
+
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	fmt.Fprintln(cw, "# HELP notifier_sends_total Notifications sent, including failures.")
+	fmt.Fprintln(cw, "# TYPE notifier_sends_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(cw, "notifier_sends_total%s %d\n", key.labels(), series[key].sends)
+	}
+	fmt.Fprintln(cw, "# HELP notifier_errors_total Notifications that failed.")
+	fmt.Fprintln(cw, "# TYPE notifier_errors_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(cw, "notifier_errors_total%s %d\n", key.labels(), series[key].errors)
+	}
+	fmt.Fprintln(cw, "# HELP notifier_send_duration_seconds Time spent sending a notification.")
+	fmt.Fprintln(cw, "# TYPE notifier_send_duration_seconds histogram")
+	for _, key := range keys {
+		s := series[key]
+		var cumulative uint64
+		for i, count := range s.buckets {
+			cumulative += count
+			le := math.Inf(1)
+			if i < len(m.buckets) {
+				le = m.buckets[i]
+			}
+			fmt.Fprintf(cw, "notifier_send_duration_seconds_bucket%s %d\n", key.labels("le", formatFloat(le)), cumulative)
+		}
+		fmt.Fprintf(cw, "notifier_send_duration_seconds_sum%s %s\n", key.labels(), formatFloat(s.total.Seconds()))
+		fmt.Fprintf(cw, "notifier_send_duration_seconds_count%s %d\n", key.labels(), s.sends)
+	}
+	if cw.err == nil {
+		cw.err = bw.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// Handler serves the metrics for a Prometheus scrape
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.WriteTo(w)
+	})
+}
+
+// labels renders {channel="...",vendor="..."} plus any extra name/value pairs
+func (k metricsKey) labels(extra ...string) string {
+	pairs := append([]string{"channel", k.channel, "vendor", k.vendor}, extra...)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", pairs[i], escapeLabel(pairs[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countingWriter tracks bytes written and keeps the first error
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+// InstrumentedNotifier records every send of the wrapped notifier in Metrics
+type InstrumentedNotifier struct {
+	next    Notifier
+	channel string
+	metrics *Metrics
+}
+
+// NewInstrumentedNotifier wraps next, labelling its sends with channel and
+// the wrapped notifier's vendor
+func NewInstrumentedNotifier(next Notifier, channel string, metrics *Metrics) *InstrumentedNotifier {
+	return &InstrumentedNotifier{next: next, channel: channel, metrics: metrics}
+}
+
+// VendorName passes through the wrapped notifier's vendor
+func (n *InstrumentedNotifier) VendorName() string {
+	return vendorName(n.next)
+}
+
+// Send sends msg and records the outcome
+func (n *InstrumentedNotifier) Send(msg string) error {
+	return n.SendContext(context.Background(), msg)
+}
+
+// SendContext is Send bounded by ctx
+func (n *InstrumentedNotifier) SendContext(ctx context.Context, msg string) error {
+	return n.SendMessage(ctx, Message{Body: msg})
+}
+
+// SendMessage sends msg and records the outcome
+func (n *InstrumentedNotifier) SendMessage(ctx context.Context, msg Message) error {
+	start := n.metrics.clock.Now()
+	err := SendMessage(ctx, n.next, msg)
+	n.metrics.Observe(n.channel, n.VendorName(), n.metrics.clock.Now().Sub(start), err)
+	return err
+}
@@ -0,0 +1,137 @@
+package factory
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowNotifier advances a fake clock by delay on every send
+type slowNotifier struct {
+	scriptedNotifier
+	clock *fakeClock
+	delay time.Duration
+}
+
+func (s *slowNotifier) Send(msg string) error {
+	s.clock.Advance(s.delay)
+	return s.scriptedNotifier.Send(msg)
+}
+
+func newTestMetrics(clock Clock) *Metrics {
+	metrics := NewMetrics(0.1, 1)
+	metrics.clock = clock
+	return metrics
+}
+
+func scrape(t *testing.T, metrics *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("expected Prometheus text content type, got %q", ct)
+	}
+	return rec.Body.String()
+}
+
+// ============================================================================
+// METRICS TESTS
+// ============================================================================
+
+func TestInstrumentedNotifier_CountsAndLatency(t *testing.T) {
+	clock := newFakeClock()
+	metrics := newTestMetrics(clock)
+	inner := &slowNotifier{scriptedNotifier: scriptedNotifier{vendor: "twilio", errs: []error{errVendorDown}}, clock: clock, delay: 50 * time.Millisecond}
+	notifier := NewInstrumentedNotifier(inner, "sms", metrics)
+
+	notifier.Send("one")
+	notifier.Send("two")
+	inner.delay = 2 * time.Second
+	notifier.Send("three")
+
+	snapshot := metrics.Snapshot()
+	if len(snapshot) != 1 {
+		t.Fatalf("expected 1 series, got %+v", snapshot)
+	}
+	got := snapshot[0]
+	if got.Channel != "sms" || got.Vendor != "twilio" || got.Sends != 3 || got.Errors != 1 {
+		t.Errorf("unexpected snapshot: %+v", got)
+	}
+	if got.LatencySum != 2100*time.Millisecond {
+		t.Errorf("expected latency sum 2.1s, got %v", got.LatencySum)
+	}
+
+	body := scrape(t, metrics)
+	for _, want := range []string{
+		"# TYPE notifier_sends_total counter\n",
+		`notifier_sends_total{channel="sms",vendor="twilio"} 3` + "\n",
+		`notifier_errors_total{channel="sms",vendor="twilio"} 1` + "\n",
+		"# TYPE notifier_send_duration_seconds histogram\n",
+		`notifier_send_duration_seconds_bucket{channel="sms",vendor="twilio",le="0.1"} 2` + "\n",
+		`notifier_send_duration_seconds_bucket{channel="sms",vendor="twilio",le="1"} 2` + "\n",
+		`notifier_send_duration_seconds_bucket{channel="sms",vendor="twilio",le="+Inf"} 3` + "\n",
+		`notifier_send_duration_seconds_sum{channel="sms",vendor="twilio"} 2.1` + "\n",
+		`notifier_send_duration_seconds_count{channel="sms",vendor="twilio"} 3` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected exposition to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetrics_SeriesPerChannelAndVendor(t *testing.T) {
+	metrics := NewMetrics()
+	NewInstrumentedNotifier(&scriptedNotifier{vendor: "twilio"}, "sms", metrics).Send("a")
+	NewInstrumentedNotifier(&scriptedNotifier{vendor: "nexmo"}, "sms", metrics).Send("b")
+	NewInstrumentedNotifier(&scriptedNotifier{vendor: "sendgrid"}, "email", metrics).Send("c")
+
+	var got []string
+	for _, s := range metrics.Snapshot() {
+		got = append(got, s.Channel+"/"+s.Vendor)
+	}
+	if strings.Join(got, " ") != "email/sendgrid sms/nexmo sms/twilio" {
+		t.Errorf("expected sorted series, got %v", got)
+	}
+	if n := strings.Count(scrape(t, metrics), "notifier_send_duration_seconds_bucket{"); n != 3*(len(DefaultLatencyBuckets)+1) {
+		t.Errorf("expected a full set of buckets per series, got %d bucket lines", n)
+	}
+}
+
+func TestMetrics_EscapesLabels(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.Observe("web\"hook", "a\\b\nc", time.Millisecond, nil)
+
+	want := `notifier_sends_total{channel="web\"hook",vendor="a\\b\nc"} 1`
+	if body := scrape(t, metrics); !strings.Contains(body, want) {
+		t.Errorf("expected escaped labels %q, got:\n%s", want, body)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestMetrics_EmptyExposition(t *testing.T) {
+	body := scrape(t, NewMetrics())
+	if strings.Contains(body, "{") {
+		t.Errorf("expected no samples before any send, got:\n%s", body)
+	}
+	if !strings.Contains(body, "# TYPE notifier_errors_total counter") {
+		t.Errorf("expected metric metadata, got:\n%s", body)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, io.ErrClosedPipe }
+
+func TestMetrics_WriteToError(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.Observe("sms", "twilio", time.Millisecond, errors.New("boom"))
+
+	if _, err := metrics.WriteTo(failingWriter{}); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("expected write error, got %v", err)
+	}
+}
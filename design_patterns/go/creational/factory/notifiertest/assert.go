@@ -0,0 +1,65 @@
+package notifiertest
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Recorder is anything that reports the messages it sent, such as a
+// RecordingNotifier or a FailingNotifier
+type Recorder interface {
+	Sent() []Sent
+}
+
+// AssertSent reports an error unless a message with exactly this body was
+// sent. It returns whether the assertion held.
+func AssertSent(t testing.TB, r Recorder, body string) bool {
+	t.Helper()
+	sent := r.Sent()
+	for _, s := range sent {
+		if s.Message.Body == body {
+			return true
+		}
+	}
+	t.Errorf("expected a message with body %q, got %s", body, describe(sent))
+	return false
+}
+
+// AssertSentTo reports an error unless a message was sent to recipient,
+// in To or CC. It returns whether the assertion held.
+func AssertSentTo(t testing.TB, r Recorder, recipient string) bool {
+	t.Helper()
+	sent := r.Sent()
+	for _, s := range sent {
+		if slices.Contains(s.Message.Recipients(), recipient) {
+			return true
+		}
+	}
+	t.Errorf("expected a message to %s, got %s", recipient, describe(sent))
+	return false
+}
+
+// AssertNoSends reports an error if anything was sent. It returns whether
+// the assertion held.
+func AssertNoSends(t testing.TB, r Recorder) bool {
+	t.Helper()
+	if sent := r.Sent(); len(sent) > 0 {
+		t.Errorf("expected no messages, got %s", describe(sent))
+		return false
+	}
+	return true
+}
+
+// describe lists sent messages for failure output
+func describe(sent []Sent) string {
+	if len(sent) == 0 {
+		return "none"
+	}
+	lines := make([]string, len(sent))
+	for i, s := range sent {
+		lines[i] = fmt.Sprintf("\n  %d. %s to %v: %q", i+1, s.Vendor, s.Message.Recipients(), s.Message.Body)
+	}
+	return fmt.Sprintf("%d message(s):%s", len(sent), strings.Join(lines, ""))
+}
@@ -0,0 +1,70 @@
+package notifiertest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/drive-deep/interview_preparation/design_patterns/go/creational/factory"
+)
+
+// fakeT captures assertion failures instead of failing the test
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func newRecorded() *RecordingNotifier {
+	recorder := NewRecordingNotifier("sendgrid")
+	recorder.SendMessage(context.Background(), factory.Message{To: []string{"a@example.com"}, CC: []string{"b@example.com"}, Body: "hello"})
+	return recorder
+}
+
+// ============================================================================
+// ASSERTION TESTS
+// ============================================================================
+
+func TestAssertions_Pass(t *testing.T) {
+	recorder := newRecorded()
+	ft := &fakeT{TB: t}
+
+	if !AssertSent(ft, recorder, "hello") || !AssertSentTo(ft, recorder, "a@example.com") || !AssertSentTo(ft, recorder, "b@example.com") {
+		t.Errorf("expected the assertions to hold, got %v", ft.errors)
+	}
+	if !AssertNoSends(ft, NewRecordingNotifier("ses")) || len(ft.errors) != 0 {
+		t.Errorf("expected no failures, got %v", ft.errors)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestAssertions_Fail(t *testing.T) {
+	recorder := newRecorded()
+
+	testCases := []struct {
+		name   string
+		assert func(testing.TB) bool
+	}{
+		{"wrong body", func(tb testing.TB) bool { return AssertSent(tb, recorder, "goodbye") }},
+		{"wrong recipient", func(tb testing.TB) bool { return AssertSentTo(tb, recorder, "c@example.com") }},
+		{"unexpected send", func(tb testing.TB) bool { return AssertNoSends(tb, recorder) }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ft := &fakeT{TB: t}
+			if tc.assert(ft) || len(ft.errors) != 1 {
+				t.Fatalf("expected one failure, got %v", ft.errors)
+			}
+			if !strings.Contains(ft.errors[0], `sendgrid to [a@example.com b@example.com]: "hello"`) {
+				t.Errorf("expected the sent messages in the failure, got %q", ft.errors[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+// Package notifiertest provides notifiers and assertions for testing code
+// that sends through the factory package, without capturing stdout.
+//
+// A RecordingNotifier keeps every message it is asked to send. A
+// FailingNotifier is a RecordingNotifier that can be scripted to fail,
+// return specific errors or respond slowly. Either one can sit at the
+// bottom of any pipeline of factory wrappers, or be installed into a
+// Registry so NotifierFactory and config-built pipelines return it.
+package notifiertest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drive-deep/interview_preparation/design_patterns/go/creational/factory"
+)
+
+// Sent is one message accepted by a recording notifier
+type Sent struct {
+	Vendor  string
+	Message factory.Message
+}
+
+// RecordingNotifier records every message and never fails. The zero
+// value is ready to use and safe for concurrent use.
+type RecordingNotifier struct {
+	Vendor string
+
+	mu   sync.Mutex
+	sent []Sent
+}
+
+// NewRecordingNotifier creates a RecordingNotifier reporting vendor
+func NewRecordingNotifier(vendor string) *RecordingNotifier {
+	return &RecordingNotifier{Vendor: vendor}
+}
+
+// VendorName returns the configured vendor
+func (r *RecordingNotifier) VendorName() string {
+	return r.Vendor
+}
+
+// Send records msg as a message body
+func (r *RecordingNotifier) Send(msg string) error {
+	return r.SendContext(context.Background(), msg)
+}
+
+// SendContext records msg unless ctx is already done
+func (r *RecordingNotifier) SendContext(ctx context.Context, msg string) error {
+	return r.SendMessage(ctx, factory.Message{Body: msg})
+}
+
+// SendMessage records msg unless ctx is already done
+func (r *RecordingNotifier) SendMessage(ctx context.Context, msg factory.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.record(r.Vendor, msg)
+	return nil
+}
+
+func (r *RecordingNotifier) record(vendor string, msg factory.Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, Sent{Vendor: vendor, Message: msg})
+}
+
+// Sent returns the recorded messages in the order they were sent
+func (r *RecordingNotifier) Sent() []Sent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Sent(nil), r.sent...)
+}
+
+// Messages returns the recorded messages without their vendors
+func (r *RecordingNotifier) Messages() []factory.Message {
+	sent := r.Sent()
+	messages := make([]factory.Message, len(sent))
+	for i, s := range sent {
+		messages[i] = s.Message
+	}
+	return messages
+}
+
+// Reset forgets the recorded messages
+func (r *RecordingNotifier) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = nil
+}
+
+// FailingNotifier fails according to a script and records the sends that
+// succeed. Each send takes the next scripted outcome; once the script is
+// used up it fails with the FailAlways error, or succeeds if none is set.
+type FailingNotifier struct {
+	RecordingNotifier
+
+	scriptMu sync.Mutex // guards the fields below
+	script   []error
+	always   error
+	latency  time.Duration
+	calls    int
+}
+
+// NewFailingNotifier creates a FailingNotifier reporting vendor. Until it
+// is scripted it behaves like a RecordingNotifier.
+func NewFailingNotifier(vendor string) *FailingNotifier {
+	return &FailingNotifier{RecordingNotifier: RecordingNotifier{Vendor: vendor}}
+}
+
+// FailTimes makes the next n scripted sends fail with err
+func (f *FailingNotifier) FailTimes(n int, err error) *FailingNotifier {
+	f.scriptMu.Lock()
+	defer f.scriptMu.Unlock()
+	for i := 0; i < n; i++ {
+		f.script = append(f.script, err)
+	}
+	return f
+}
+
+// FailWith scripts one send per error; a nil entry is a success
+func (f *FailingNotifier) FailWith(errs ...error) *FailingNotifier {
+	f.scriptMu.Lock()
+	defer f.scriptMu.Unlock()
+	f.script = append(f.script, errs...)
+	return f
+}
+
+// FailAlways makes every send after the script fail with err
+func (f *FailingNotifier) FailAlways(err error) *FailingNotifier {
+	f.scriptMu.Lock()
+	defer f.scriptMu.Unlock()
+	f.always = err
+	return f
+}
+
+// WithLatency makes every send take d, or until its context is done
+func (f *FailingNotifier) WithLatency(d time.Duration) *FailingNotifier {
+	f.scriptMu.Lock()
+	defer f.scriptMu.Unlock()
+	f.latency = d
+	return f
+}
+
+// Calls returns how many sends were attempted, failed or not
+func (f *FailingNotifier) Calls() int {
+	f.scriptMu.Lock()
+	defer f.scriptMu.Unlock()
+	return f.calls
+}
+
+// Send is SendMessage with a plain body
+func (f *FailingNotifier) Send(msg string) error {
+	return f.SendContext(context.Background(), msg)
+}
+
+// SendContext is SendMessage with a plain body
+func (f *FailingNotifier) SendContext(ctx context.Context, msg string) error {
+	return f.SendMessage(ctx, factory.Message{Body: msg})
+}
+
+// SendMessage waits out the latency, then fails or records msg according
+// to the script
+func (f *FailingNotifier) SendMessage(ctx context.Context, msg factory.Message) error {
+	f.scriptMu.Lock()
+	f.calls++
+	err, latency := f.always, f.latency
+	if len(f.script) > 0 {
+		err, f.script = f.script[0], f.script[1:]
+	}
+	f.scriptMu.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return f.RecordingNotifier.SendMessage(ctx, msg)
+}
+
+// vendorNotifier records into a shared RecordingNotifier under its own
+// vendor, so one recorder can stand in for every vendor of a type
+type vendorNotifier struct {
+	recorder *RecordingNotifier
+	vendor   string
+}
+
+func (v *vendorNotifier) VendorName() string { return v.vendor }
+
+func (v *vendorNotifier) Send(msg string) error {
+	return v.SendMessage(context.Background(), factory.Message{Body: msg})
+}
+
+func (v *vendorNotifier) SendContext(ctx context.Context, msg string) error {
+	return v.SendMessage(ctx, factory.Message{Body: msg})
+}
+
+func (v *vendorNotifier) SendMessage(ctx context.Context, msg factory.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	v.recorder.record(v.vendor, msg)
+	return nil
+}
+
+// Install replaces notifierType in registry, or in the default registry
+// when registry is nil, with a recorder for every vendor, and restores the
+// original constructor when the test ends. Tests that install into the
+// default registry must not run in parallel.
+func Install(t testing.TB, registry *factory.Registry, notifierType string) *RecordingNotifier {
+	t.Helper()
+	if registry == nil {
+		registry = factory.DefaultRegistry()
+	}
+	original, hadOriginal := registry.Lookup(notifierType)
+	if hadOriginal {
+		if err := registry.Unregister(notifierType); err != nil {
+			t.Fatalf("notifiertest: %v", err)
+		}
+	}
+	recorder := &RecordingNotifier{}
+	err := registry.Register(notifierType, func(vendor string) (factory.Notifier, error) {
+		return &vendorNotifier{recorder: recorder, vendor: vendor}, nil
+	})
+	if err != nil {
+		t.Fatalf("notifiertest: %v", err)
+	}
+
+	t.Cleanup(func() {
+		err := registry.Unregister(notifierType)
+		if hadOriginal {
+			err = errors.Join(err, registry.Register(notifierType, original))
+		}
+		if err != nil {
+			t.Errorf("notifiertest: restore %s: %v", notifierType, err)
+		}
+	})
+	return recorder
+}
@@ -0,0 +1,149 @@
+package notifiertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/drive-deep/interview_preparation/design_patterns/go/creational/factory"
+)
+
+var errVendorDown = errors.New("vendor down")
+
+// ============================================================================
+// NOTIFIERTEST TESTS
+// ============================================================================
+
+func TestRecordingNotifier_RecordsMessages(t *testing.T) {
+	var recorder RecordingNotifier
+	recorder.Vendor = "sendgrid"
+
+	recorder.Send("hello")
+	factory.SendMessage(context.Background(), &recorder, factory.Message{To: []string{"a@example.com"}, Body: "report"})
+
+	sent := recorder.Sent()
+	if len(sent) != 2 || sent[0].Message.Body != "hello" || sent[1].Vendor != "sendgrid" {
+		t.Fatalf("expected both messages recorded in order, got %+v", sent)
+	}
+	if messages := recorder.Messages(); messages[1].To[0] != "a@example.com" {
+		t.Errorf("expected the recipient kept, got %+v", messages[1])
+	}
+	recorder.Reset()
+	AssertNoSends(t, &recorder)
+}
+
+func TestFailingNotifier_FailTimes(t *testing.T) {
+	notifier := NewFailingNotifier("twilio").FailTimes(2, errVendorDown)
+
+	for i := 0; i < 2; i++ {
+		if err := notifier.Send("code"); !errors.Is(err, errVendorDown) {
+			t.Errorf("expected send %d to fail, got %v", i+1, err)
+		}
+	}
+	if err := notifier.Send("code"); err != nil {
+		t.Errorf("expected the third send to succeed, got %v", err)
+	}
+	if notifier.Calls() != 3 || len(notifier.Sent()) != 1 {
+		t.Errorf("expected 3 calls and 1 recorded send, got %d and %d", notifier.Calls(), len(notifier.Sent()))
+	}
+}
+
+func TestFailingNotifier_FailWithThenAlways(t *testing.T) {
+	errRejected := factory.Permanent(errors.New("rejected"))
+	notifier := NewFailingNotifier("twilio").FailWith(nil, errRejected).FailAlways(errVendorDown)
+
+	if err := notifier.Send("first"); err != nil {
+		t.Errorf("expected the nil entry to succeed, got %v", err)
+	}
+	if err := notifier.Send("second"); !factory.IsPermanent(err) {
+		t.Errorf("expected the scripted permanent error, got %v", err)
+	}
+	if err := notifier.Send("third"); !errors.Is(err, errVendorDown) {
+		t.Errorf("expected the FailAlways error once the script ran out, got %v", err)
+	}
+	AssertSent(t, notifier, "first")
+}
+
+func TestFailingNotifier_InRetryPipeline(t *testing.T) {
+	notifier := NewFailingNotifier("twilio").FailTimes(2, errVendorDown)
+	retry := factory.NewRetryNotifier(notifier, factory.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	msg := factory.Message{To: []string{"+15551234567"}, Body: "Your code is 1234"}
+	if err := factory.SendMessage(context.Background(), retry, msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if notifier.Calls() != 3 {
+		t.Errorf("expected 3 attempts, got %d", notifier.Calls())
+	}
+	AssertSentTo(t, notifier, "+15551234567")
+	AssertSent(t, notifier, "Your code is 1234")
+}
+
+func TestInstall_ReplacesFactoryType(t *testing.T) {
+	recorder := Install(t, nil, "email")
+
+	notifier, err := factory.NotifierFactory("email", "ses")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	factory.SendMessage(context.Background(), notifier, factory.Message{To: []string{"ops@example.com"}, Body: "deploy done"})
+
+	sent := recorder.Sent()
+	if len(sent) != 1 || sent[0].Vendor != "ses" {
+		t.Errorf("expected one send recorded under ses, got %+v", sent)
+	}
+	AssertSentTo(t, recorder, "ops@example.com")
+}
+
+func TestInstall_RestoresConstructor(t *testing.T) {
+	registry := factory.NewRegistry()
+	registry.Register("sms", func(vendor string) (factory.Notifier, error) {
+		return NewRecordingNotifier("original"), nil
+	})
+
+	t.Run("installed", func(t *testing.T) {
+		Install(t, registry, "sms")
+		if n, _ := registry.New("sms", "twilio"); n.(factory.Vendored).VendorName() != "twilio" {
+			t.Errorf("expected the installed recorder, got %T", n)
+		}
+	})
+	if n, _ := registry.New("sms", "twilio"); n.(factory.Vendored).VendorName() != "original" {
+		t.Errorf("expected the original constructor back, got %T", n)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestRecordingNotifier_CanceledContext(t *testing.T) {
+	recorder := NewRecordingNotifier("sendgrid")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := recorder.SendContext(ctx, "hello"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	AssertNoSends(t, recorder)
+}
+
+func TestFailingNotifier_LatencyHonoursContext(t *testing.T) {
+	notifier := NewFailingNotifier("twilio").WithLatency(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := notifier.SendContext(ctx, "slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to cut the latency short, got %v", err)
+	}
+	AssertNoSends(t, notifier)
+}
+
+func TestInstall_UnregisteredTypeRemoved(t *testing.T) {
+	registry := factory.NewRegistry()
+
+	t.Run("installed", func(t *testing.T) {
+		Install(t, registry, "pager")
+	})
+	if _, ok := registry.Lookup("pager"); ok {
+		t.Error("expected a type that did not exist before to be removed again")
+	}
+}
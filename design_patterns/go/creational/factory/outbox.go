@@ -0,0 +1,253 @@
+package factory
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrOutboxClosed is returned by Append and Ack after Close
+var ErrOutboxClosed = errors.New("outbox: closed")
+
+// outboxCompactAfter is how many acks accumulate in the log before Ack
+// compacts it
+const outboxCompactAfter = 1000
+
+// OutboxEntry is a message written to the outbox and not yet acknowledged
+type OutboxEntry struct {
+	ID         uint64
+	Message    Message
+	EnqueuedAt time.Time
+}
+
+// outboxRecord is one line of the log: either a new entry or an ack
+type outboxRecord struct {
+	Op      string    `json:"op"` // "add" or "ack"
+	ID      uint64    `json:"id"`
+	Message *Message  `json:"message,omitempty"`
+	At      time.Time `json:"at,omitempty"`
+}
+
+// Outbox is a file-backed write-ahead log of messages awaiting delivery.
+// Append makes a message durable before it is sent and Ack marks it
+// delivered; whatever is still pending when the process restarts is
+// returned by Pending so it can be sent again. Delivery is at-least-once:
+// a crash between a send and its ack replays that message.
+type Outbox struct {
+	path  string
+	clock Clock
+
+	mu      sync.Mutex
+	file    *os.File
+	pending map[uint64]OutboxEntry
+	nextID  uint64
+	acked   int // ack records written since the last compaction
+}
+
+// OpenOutbox loads the log at path, creating it if needed, and compacts it
+// down to the pending entries
+func OpenOutbox(path string, clock Clock) (*Outbox, error) {
+	if clock == nil {
+		clock = SystemClock
+	}
+	o := &Outbox{path: path, clock: clock, pending: make(map[uint64]OutboxEntry), nextID: 1}
+	if err := o.load(); err != nil {
+		return nil, err
+	}
+	if err := o.Compact(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *Outbox) load() error {
+	f, err := os.Open(o.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("outbox: open %s: %w", o.path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	var torn error
+	for line := 1; scanner.Scan(); line++ {
+		if torn != nil {
+			return torn
+		}
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record outboxRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// A torn final line from a crash is dropped; corruption
+			// anywhere else is reported
+			torn = fmt.Errorf("outbox: %s line %d: %w", o.path, line, err)
+			continue
+		}
+		switch record.Op {
+		case "add":
+			if record.Message == nil {
+				return fmt.Errorf("outbox: %s line %d: add without message", o.path, line)
+			}
+			o.pending[record.ID] = OutboxEntry{ID: record.ID, Message: *record.Message, EnqueuedAt: record.At}
+		case "ack":
+			delete(o.pending, record.ID)
+		default:
+			return fmt.Errorf("outbox: %s line %d: unknown op %q", o.path, line, record.Op)
+		}
+		if record.ID >= o.nextID {
+			o.nextID = record.ID + 1
+		}
+	}
+	return scanner.Err()
+}
+
+// Append durably records msg and returns its ID. The file is synced
+// before Append returns.
+func (o *Outbox) Append(msg Message) (uint64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.file == nil {
+		return 0, ErrOutboxClosed
+	}
+	entry := OutboxEntry{ID: o.nextID, Message: msg, EnqueuedAt: o.clock.Now()}
+	if err := o.write(outboxRecord{Op: "add", ID: entry.ID, Message: &msg, At: entry.EnqueuedAt}); err != nil {
+		return 0, err
+	}
+	if err := o.file.Sync(); err != nil {
+		return 0, fmt.Errorf("outbox: sync %s: %w", o.path, err)
+	}
+	o.nextID++
+	o.pending[entry.ID] = entry
+	return entry.ID, nil
+}
+
+// Ack marks an entry delivered. The ack is not synced: losing it in a crash
+// only means the message is sent again.
+func (o *Outbox) Ack(id uint64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.file == nil {
+		return ErrOutboxClosed
+	}
+	if _, ok := o.pending[id]; !ok {
+		return nil
+	}
+	if err := o.write(outboxRecord{Op: "ack", ID: id}); err != nil {
+		return err
+	}
+	delete(o.pending, id)
+	o.acked++
+	if o.acked >= outboxCompactAfter && o.acked > len(o.pending) {
+		return o.compactLocked()
+	}
+	return nil
+}
+
+func (o *Outbox) write(record outboxRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("outbox: encode record: %w", err)
+	}
+	if _, err := o.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("outbox: write %s: %w", o.path, err)
+	}
+	return nil
+}
+
+// Pending returns the unacknowledged entries, oldest first
+func (o *Outbox) Pending() []OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entries := make([]OutboxEntry, 0, len(o.pending))
+	for _, entry := range o.pending {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+// Len returns the number of pending entries
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.pending)
+}
+
+// Compact rewrites the log with only the pending entries
+func (o *Outbox) Compact() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.compactLocked()
+}
+
+func (o *Outbox) compactLocked() error {
+	ids := make([]uint64, 0, len(o.pending))
+	for id := range o.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	tmp, err := os.CreateTemp(filepath.Dir(o.path), filepath.Base(o.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("outbox: compact: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, id := range ids {
+		entry := o.pending[id]
+		if err := enc.Encode(outboxRecord{Op: "add", ID: id, Message: &entry.Message, At: entry.EnqueuedAt}); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return fmt.Errorf("outbox: compact: %w", err)
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("outbox: compact: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), o.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("outbox: compact: %w", err)
+	}
+
+	if o.file != nil {
+		o.file.Close()
+	}
+	o.file, err = os.OpenFile(o.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("outbox: reopen %s: %w", o.path, err)
+	}
+	o.acked = 0
+	return nil
+}
+
+// Close closes the log file. Pending entries stay on disk for the next Open.
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file == nil {
+		return nil
+	}
+	err := o.file.Close()
+	o.file = nil
+	return err
+}
@@ -0,0 +1,296 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fileNotifier appends every body it sends to a file, so deliveries
+// survive the process that made them
+type fileNotifier struct {
+	path string
+
+	mu    sync.Mutex
+	calls int
+	// onSend runs after the nth delivery has been written
+	onSend func(n int)
+}
+
+func (f *fileNotifier) Send(msg string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(file, msg)
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	f.calls++
+	if f.onSend != nil {
+		f.onSend(f.calls)
+	}
+	return err
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return strings.Fields(string(content))
+}
+
+// ============================================================================
+// OUTBOX TESTS
+// ============================================================================
+
+func TestOutbox_PendingSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.log")
+	outbox, err := OpenOutbox(path, newFakeClock())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first, _ := outbox.Append(Message{To: []string{"+14155552671"}, Body: "one"})
+	outbox.Append(Message{Body: "two", Priority: PriorityHigh})
+	outbox.Ack(first)
+	outbox.Close()
+
+	outbox, err = OpenOutbox(path, newFakeClock())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer outbox.Close()
+
+	pending := outbox.Pending()
+	if len(pending) != 1 || pending[0].Message.Body != "two" || pending[0].Message.Priority != PriorityHigh {
+		t.Fatalf("expected only 'two' to be pending, got %+v", pending)
+	}
+	if id, _ := outbox.Append(Message{Body: "three"}); id <= pending[0].ID {
+		t.Errorf("expected IDs to keep increasing, got %d after %d", id, pending[0].ID)
+	}
+}
+
+func TestOutbox_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.log")
+	outbox, _ := OpenOutbox(path, newFakeClock())
+	defer outbox.Close()
+
+	for i := 0; i < 10; i++ {
+		id, _ := outbox.Append(Message{Body: fmt.Sprintf("m%d", i)})
+		if i < 9 {
+			outbox.Ack(id)
+		}
+	}
+	if err := outbox.Compact(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if lines := readLines(t, path); len(lines) != 1 || !strings.Contains(lines[0], `"m9"`) {
+		t.Errorf("expected a single pending record after compaction, got %v", lines)
+	}
+	id, _ := outbox.Append(Message{Body: "after"})
+	outbox.Ack(id)
+	if outbox.Len() != 1 {
+		t.Errorf("expected 1 pending entry, got %d", outbox.Len())
+	}
+}
+
+func TestOutbox_TornFinalLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.log")
+	outbox, _ := OpenOutbox(path, newFakeClock())
+	outbox.Append(Message{Body: "kept"})
+	outbox.Close()
+
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString(`{"op":"add","id":2,"mess`)
+	f.Close()
+
+	outbox, err := OpenOutbox(path, newFakeClock())
+	if err != nil {
+		t.Fatalf("expected torn final line to be ignored, got %v", err)
+	}
+	defer outbox.Close()
+	if pending := outbox.Pending(); len(pending) != 1 || pending[0].Message.Body != "kept" {
+		t.Errorf("expected the complete entry to survive, got %+v", pending)
+	}
+}
+
+func TestDispatcher_OutboxAcksDeliveredMessages(t *testing.T) {
+	outbox, _ := OpenOutbox(filepath.Join(t.TempDir(), "outbox.log"), nil)
+	defer outbox.Close()
+	inner := &scriptedNotifier{}
+	d, _ := NewDispatcher(DispatcherConfig{Notifier: inner, Outbox: outbox})
+
+	for i := 0; i < 10; i++ {
+		d.Enqueue(fmt.Sprintf("m%d", i))
+	}
+	d.Shutdown(context.Background())
+
+	if inner.Calls() != 10 {
+		t.Errorf("expected 10 sends, got %d", inner.Calls())
+	}
+	if outbox.Len() != 0 {
+		t.Errorf("expected every entry to be acked, got %d pending", outbox.Len())
+	}
+}
+
+func TestDispatcher_OutboxReplaysAfterKill(t *testing.T) {
+	if os.Getenv("OUTBOX_HELPER_DIR") != "" {
+		t.Skip("running as helper")
+	}
+	dir := t.TempDir()
+	delivered := filepath.Join(dir, "delivered.log")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestOutboxHelperProcess$")
+	cmd.Env = append(os.Environ(), "OUTBOX_HELPER_DIR="+dir)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The helper hangs after its 8th delivery; kill it there
+	deadline := time.Now().Add(10 * time.Second)
+	for len(readLines(t, delivered)) < 8 {
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			t.Fatalf("helper delivered %d messages before timing out", len(readLines(t, delivered)))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	outbox, err := OpenOutbox(filepath.Join(dir, "outbox.log"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer outbox.Close()
+	// The 8th message was sent but never acked, so it is sent again
+	if outbox.Len() != 13 {
+		t.Fatalf("expected 13 pending entries after the kill, got %d", outbox.Len())
+	}
+
+	d, err := NewDispatcher(DispatcherConfig{Notifier: &fileNotifier{path: delivered}, Workers: 2, Outbox: outbox})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if d.Stats().Replayed != 13 {
+		t.Errorf("expected 13 replayed messages, got %d", d.Stats().Replayed)
+	}
+	seen := map[string]int{}
+	for _, body := range readLines(t, delivered) {
+		seen[body]++
+	}
+	for i := 1; i <= 20; i++ {
+		if seen[fmt.Sprintf("m%02d", i)] == 0 {
+			t.Errorf("expected m%02d to be delivered after restart", i)
+		}
+	}
+	if outbox.Len() != 0 {
+		t.Errorf("expected outbox to be drained, got %d pending", outbox.Len())
+	}
+}
+
+// TestOutboxHelperProcess is the process killed by
+// TestDispatcher_OutboxReplaysAfterKill. It queues 20 messages and hangs
+// in the middle of the 8th send.
+func TestOutboxHelperProcess(t *testing.T) {
+	dir := os.Getenv("OUTBOX_HELPER_DIR")
+	if dir == "" {
+		t.Skip("only runs as a helper process")
+	}
+	outbox, err := OpenOutbox(filepath.Join(dir, "outbox.log"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	allQueued := make(chan struct{})
+	notifier := &fileNotifier{path: filepath.Join(dir, "delivered.log")}
+	notifier.onSend = func(n int) {
+		if n == 1 {
+			<-allQueued
+		}
+		if n == 8 {
+			select {}
+		}
+	}
+	d, _ := NewDispatcher(DispatcherConfig{Notifier: notifier, Workers: 1, Outbox: outbox})
+	for i := 1; i <= 20; i++ {
+		if err := d.Enqueue(fmt.Sprintf("m%02d", i)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	close(allQueued)
+	select {}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestDispatcher_OutboxKeepsTransientFailures(t *testing.T) {
+	outbox, _ := OpenOutbox(filepath.Join(t.TempDir(), "outbox.log"), nil)
+	defer outbox.Close()
+	inner := &scriptedNotifier{errs: []error{errVendorDown, Permanent(errors.New("bad number"))}}
+	d, _ := NewDispatcher(DispatcherConfig{Notifier: inner, Workers: 1, Outbox: outbox})
+
+	d.Enqueue("transient")
+	d.Enqueue("permanent")
+	d.Enqueue("ok")
+	d.Shutdown(context.Background())
+
+	if pending := outbox.Pending(); len(pending) != 1 || pending[0].Message.Body != "transient" {
+		t.Errorf("expected only the transient failure to stay pending, got %+v", pending)
+	}
+}
+
+func TestDispatcher_OutboxForgetsRejectedMessages(t *testing.T) {
+	outbox, _ := OpenOutbox(filepath.Join(t.TempDir(), "outbox.log"), nil)
+	defer outbox.Close()
+	inner := newGatedNotifier()
+	d, _ := NewDispatcher(DispatcherConfig{Notifier: inner, Workers: 1, QueueSize: 1, Overflow: OverflowError, Outbox: outbox})
+
+	d.Enqueue("in flight")
+	<-inner.started
+	d.Enqueue("queued")
+	if err := d.Enqueue("rejected"); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	if outbox.Len() != 2 {
+		t.Errorf("expected the rejected message not to stay pending, got %d", outbox.Len())
+	}
+	close(inner.gate)
+	d.Shutdown(context.Background())
+}
+
+func TestOutbox_CorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.log")
+	os.WriteFile(path, []byte("not json\n{\"op\":\"ack\",\"id\":1}\n"), 0o644)
+
+	if _, err := OpenOutbox(path, nil); err == nil {
+		t.Error("expected error for corrupt outbox, got nil")
+	}
+}
+
+func TestOutbox_AppendAfterClose(t *testing.T) {
+	outbox, _ := OpenOutbox(filepath.Join(t.TempDir(), "outbox.log"), nil)
+	outbox.Close()
+
+	if _, err := outbox.Append(Message{Body: "late"}); !errors.Is(err, ErrOutboxClosed) {
+		t.Errorf("expected ErrOutboxClosed, got %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package factory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// priorityClasses lists the priorities a Dispatcher queues separately,
+// most urgent first
+var priorityClasses = [...]Priority{PriorityCritical, PriorityHigh, PriorityNormal, PriorityBulk}
+
+// PriorityPolicy configures how a Dispatcher orders queued messages.
+//
+// Workers take messages by smooth weighted round robin over the non-empty
+// priority classes, so under load each class gets a share of sends in
+// proportion to its weight and a newly queued critical message is next in
+// line. Within a class messages stay in FIFO order. A message that has
+// waited MaxWait is taken before anything else, oldest first, so bulk
+// traffic is slowed but never starved.
+type PriorityPolicy struct {
+	// Weights per class. Missing or non-positive entries default to
+	// critical 8, high 4, normal 2, bulk 1.
+	Weights map[Priority]int
+
+	MaxWait time.Duration // defaults to 30s
+	Clock   Clock         // defaults to SystemClock
+}
+
+func (p PriorityPolicy) withDefaults() PriorityPolicy {
+	defaults := map[Priority]int{PriorityCritical: 8, PriorityHigh: 4, PriorityNormal: 2, PriorityBulk: 1}
+	weights := make(map[Priority]int, len(priorityClasses))
+	for _, class := range priorityClasses {
+		weights[class] = defaults[class]
+		if w := p.Weights[class]; w > 0 {
+			weights[class] = w
+		}
+	}
+	p.Weights = weights
+	if p.MaxWait <= 0 {
+		p.MaxWait = 30 * time.Second
+	}
+	if p.Clock == nil {
+		p.Clock = SystemClock
+	}
+	return p
+}
+
+// priorityClass maps any Priority onto an index into priorityClasses
+func priorityClass(p Priority) int {
+	switch {
+	case p >= PriorityCritical:
+		return 0
+	case p == PriorityHigh:
+		return 1
+	case p <= PriorityBulk:
+		return 3
+	}
+	return 2
+}
+
+// priorityQueue is a bounded multi-class queue. Every change to its
+// contents closes and replaces signal, waking blocked pushers and poppers.
+type priorityQueue struct {
+	policy   PriorityPolicy
+	capacity int
+
+	mu      sync.Mutex
+	classes [len(priorityClasses)][]queued
+	current [len(priorityClasses)]int // smooth weighted round robin state
+	size    int
+	closed  bool
+	signal  chan struct{}
+}
+
+func newPriorityQueue(capacity int, policy PriorityPolicy) *priorityQueue {
+	return &priorityQueue{policy: policy.withDefaults(), capacity: capacity, signal: make(chan struct{})}
+}
+
+// changed wakes every waiter; the caller holds q.mu
+func (q *priorityQueue) changed() {
+	close(q.signal)
+	q.signal = make(chan struct{})
+}
+
+// push adds item, waiting for room while ctx allows when wait is set.
+// It returns ErrQueueFull when the queue is full and wait is not set.
+func (q *priorityQueue) push(ctx context.Context, item queued, wait bool) error {
+	q.mu.Lock()
+	for q.size >= q.capacity && !q.closed {
+		if !wait {
+			q.mu.Unlock()
+			return ErrQueueFull
+		}
+		signal := q.signal
+		q.mu.Unlock()
+		select {
+		case <-signal:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		q.mu.Lock()
+	}
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrDispatcherClosed
+	}
+	item.at = q.policy.Clock.Now()
+	class := priorityClass(item.msg.Priority)
+	q.classes[class] = append(q.classes[class], item)
+	q.size++
+	q.changed()
+	return nil
+}
+
+// pop waits for the next item by the scheduling policy. It returns false
+// once the queue is closed and empty, or when abort is closed.
+func (q *priorityQueue) pop(abort <-chan struct{}) (queued, bool) {
+	q.mu.Lock()
+	for {
+		select {
+		case <-abort:
+			q.mu.Unlock()
+			return queued{}, false
+		default:
+		}
+		if q.size > 0 {
+			break
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return queued{}, false
+		}
+		signal := q.signal
+		q.mu.Unlock()
+		select {
+		case <-signal:
+		case <-abort:
+			return queued{}, false
+		}
+		q.mu.Lock()
+	}
+	defer q.mu.Unlock()
+
+	class := q.next()
+	item := q.classes[class][0]
+	q.classes[class][0] = queued{}
+	q.classes[class] = q.classes[class][1:]
+	q.size--
+	if len(q.classes[class]) == 0 {
+		q.current[class] = 0
+	}
+	q.changed()
+	return item, true
+}
+
+// next picks the class to serve; the caller holds q.mu and q.size > 0
+func (q *priorityQueue) next() int {
+	now := q.policy.Clock.Now()
+	overdue, oldest := -1, time.Time{}
+	for class, items := range q.classes {
+		if len(items) > 0 && now.Sub(items[0].at) >= q.policy.MaxWait &&
+			(overdue < 0 || items[0].at.Before(oldest)) {
+			overdue, oldest = class, items[0].at
+		}
+	}
+	if overdue >= 0 {
+		return overdue
+	}
+
+	best, total := -1, 0
+	for class, items := range q.classes {
+		if len(items) == 0 {
+			continue
+		}
+		weight := q.policy.Weights[priorityClasses[class]]
+		q.current[class] += weight
+		total += weight
+		if best < 0 || q.current[class] > q.current[best] {
+			best = class
+		}
+	}
+	q.current[best] -= total
+	return best
+}
+
+// close stops pushes; queued items can still be popped
+func (q *priorityQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.changed()
+}
+
+func (q *priorityQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// lens returns the number of queued messages per priority
+func (q *priorityQueue) lens() map[Priority]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	lens := make(map[Priority]int, len(priorityClasses))
+	for class, items := range q.classes {
+		lens[priorityClasses[class]] = len(items)
+	}
+	return lens
+}
@@ -0,0 +1,166 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func pushPriorities(t *testing.T, q *priorityQueue, priorities ...Priority) {
+	t.Helper()
+	for i, p := range priorities {
+		item := queued{msg: Message{Body: fmt.Sprintf("%s-%d", p, i), Priority: p}}
+		if err := q.push(context.Background(), item, false); err != nil {
+			t.Fatalf("push %d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func popPriorities(q *priorityQueue, n int) []Priority {
+	var got []Priority
+	for i := 0; i < n; i++ {
+		item, ok := q.pop(nil)
+		if !ok {
+			break
+		}
+		got = append(got, item.msg.Priority)
+	}
+	return got
+}
+
+// ============================================================================
+// PRIORITY QUEUE TESTS
+// ============================================================================
+
+func TestPriorityQueue_WeightedRoundRobin(t *testing.T) {
+	q := newPriorityQueue(100, PriorityPolicy{Clock: newFakeClock()})
+	for i := 0; i < 10; i++ {
+		pushPriorities(t, q, PriorityHigh, PriorityNormal, PriorityBulk)
+	}
+
+	want := []Priority{PriorityHigh, PriorityNormal, PriorityHigh, PriorityBulk, PriorityHigh, PriorityNormal, PriorityHigh}
+	if got := popPriorities(q, 7); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected a 4:2:1 interleaving %v, got %v", want, got)
+	}
+}
+
+func TestPriorityQueue_CriticalJumpsBacklog(t *testing.T) {
+	q := newPriorityQueue(100, PriorityPolicy{Clock: newFakeClock()})
+	pushPriorities(t, q, PriorityBulk, PriorityBulk, PriorityBulk)
+	popPriorities(q, 1)
+	pushPriorities(t, q, PriorityCritical)
+
+	if got := popPriorities(q, 1); !reflect.DeepEqual(got, []Priority{PriorityCritical}) {
+		t.Errorf("expected the critical message next, got %v", got)
+	}
+}
+
+func TestPriorityQueue_FIFOWithinClass(t *testing.T) {
+	q := newPriorityQueue(100, PriorityPolicy{Clock: newFakeClock()})
+	pushPriorities(t, q, PriorityNormal, PriorityNormal, PriorityNormal)
+
+	for i := 0; i < 3; i++ {
+		item, _ := q.pop(nil)
+		if want := fmt.Sprintf("normal-%d", i); item.msg.Body != want {
+			t.Errorf("expected %s, got %s", want, item.msg.Body)
+		}
+	}
+}
+
+func TestPriorityQueue_AgingPreventsStarvation(t *testing.T) {
+	clock := newFakeClock()
+	q := newPriorityQueue(100, PriorityPolicy{MaxWait: 10 * time.Second, Clock: clock})
+	pushPriorities(t, q, PriorityBulk)
+	clock.Advance(5 * time.Second)
+	pushPriorities(t, q, PriorityCritical, PriorityCritical)
+
+	if got := popPriorities(q, 1); got[0] != PriorityCritical {
+		t.Errorf("expected critical first before the bulk message is overdue, got %v", got)
+	}
+	clock.Advance(5 * time.Second)
+	if got := popPriorities(q, 1); got[0] != PriorityBulk {
+		t.Errorf("expected the overdue bulk message ahead of critical, got %v", got)
+	}
+}
+
+func TestPriorityQueue_WeightsConfigurable(t *testing.T) {
+	q := newPriorityQueue(100, PriorityPolicy{Weights: map[Priority]int{PriorityNormal: 1, PriorityBulk: 1}, Clock: newFakeClock()})
+	pushPriorities(t, q, PriorityNormal, PriorityNormal, PriorityBulk, PriorityBulk)
+
+	want := []Priority{PriorityNormal, PriorityBulk, PriorityNormal, PriorityBulk}
+	if got := popPriorities(q, 4); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected equal weights to alternate %v, got %v", want, got)
+	}
+}
+
+func TestDispatcher_DeliversByPriority(t *testing.T) {
+	notifier := newGatedNotifier()
+	d, err := NewDispatcher(DispatcherConfig{Notifier: notifier, Workers: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	d.EnqueueMessage(ctx, Message{Body: "in-flight"})
+	<-notifier.started
+
+	for _, msg := range []Message{
+		{Body: "newsletter-1", Priority: PriorityBulk},
+		{Body: "newsletter-2", Priority: PriorityBulk},
+		{Body: "receipt", Priority: PriorityNormal},
+		{Body: "password-reset", Priority: PriorityCritical},
+		{Body: "login-alert", Priority: PriorityHigh},
+	} {
+		d.EnqueueMessage(ctx, msg)
+	}
+	want := map[Priority]int{PriorityCritical: 1, PriorityHigh: 1, PriorityNormal: 1, PriorityBulk: 2}
+	if got := d.Queued(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v queued, got %v", want, got)
+	}
+
+	close(notifier.gate)
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantOrder := []string{"in-flight", "password-reset", "login-alert", "receipt", "newsletter-1", "newsletter-2"}
+	if got := notifier.Sent(); !reflect.DeepEqual(got, wantOrder) {
+		t.Errorf("expected %v, got %v", wantOrder, got)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestPriorityQueue_FullAndClosed(t *testing.T) {
+	q := newPriorityQueue(1, PriorityPolicy{Clock: newFakeClock()})
+	pushPriorities(t, q, PriorityNormal)
+
+	if err := q.push(context.Background(), queued{msg: Message{Priority: PriorityCritical}}, false); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.push(ctx, queued{}, true); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a blocked push to honour ctx, got %v", err)
+	}
+
+	q.close()
+	if err := q.push(context.Background(), queued{}, true); !errors.Is(err, ErrDispatcherClosed) {
+		t.Errorf("expected ErrDispatcherClosed, got %v", err)
+	}
+	if got := popPriorities(q, 2); len(got) != 1 {
+		t.Errorf("expected the queued item to drain after close, got %v", got)
+	}
+}
+
+func TestPriorityQueue_PopAborts(t *testing.T) {
+	q := newPriorityQueue(1, PriorityPolicy{})
+	abort := make(chan struct{})
+	close(abort)
+	pushPriorities(t, q, PriorityNormal)
+
+	if _, ok := q.pop(abort); ok {
+		t.Error("expected an aborted pop to return nothing even with items queued")
+	}
+}
@@ -0,0 +1,461 @@
+package factory
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message.Metadata keys read by PushNotifier. Other metadata is sent as the
+// notification's custom data.
+const (
+	PushBadgeKey       = "push.badge"        // app icon badge count
+	PushSoundKey       = "push.sound"        // sound name, e.g. "default"
+	PushCollapseKeyKey = "push.collapse_key" // newer notifications with the same key replace older ones
+	PushTTLKey         = "push.ttl"          // how long to keep trying to deliver, e.g. "1h"
+)
+
+// Push service endpoints
+const (
+	APNsProductionEndpoint = "https://api.push.apple.com"
+	APNsSandboxEndpoint    = "https://api.sandbox.push.apple.com"
+	FCMEndpoint            = "https://fcm.googleapis.com"
+)
+
+// PushOptions are the per-notification settings of a push payload.
+// Zero values are left out of the payload.
+type PushOptions struct {
+	Badge       *int
+	Sound       string
+	CollapseKey string
+	TTL         time.Duration
+	Data        map[string]string
+}
+
+// pushOptions merges the notifier defaults with the message metadata
+func pushOptions(defaults PushOptions, msg Message) (PushOptions, error) {
+	opts := defaults
+	opts.Data = make(map[string]string, len(defaults.Data)+len(msg.Metadata))
+	for key, value := range defaults.Data {
+		opts.Data[key] = value
+	}
+	for key, value := range msg.Metadata {
+		switch key {
+		case PushBadgeKey:
+			badge, err := strconv.Atoi(value)
+			if err != nil || badge < 0 {
+				return opts, &ValidationError{Channel: "push", Field: "Metadata[" + key + "]", Value: value, Reason: "must be a non-negative integer"}
+			}
+			opts.Badge = &badge
+		case PushSoundKey:
+			opts.Sound = value
+		case PushCollapseKeyKey:
+			opts.CollapseKey = value
+		case PushTTLKey:
+			ttl, err := time.ParseDuration(value)
+			if err != nil || ttl < 0 {
+				return opts, &ValidationError{Channel: "push", Field: "Metadata[" + key + "]", Value: value, Reason: "must be a duration such as 1h"}
+			}
+			opts.TTL = ttl
+		default:
+			opts.Data[key] = value
+		}
+	}
+	return opts, nil
+}
+
+// PushError is a rejection from APNs or FCM for one device token
+type PushError struct {
+	Service    string // "apns" or "fcm"
+	Token      string
+	StatusCode int
+	Reason     string // e.g. "BadDeviceToken" or "UNREGISTERED"
+}
+
+func (e *PushError) Error() string {
+	return fmt.Sprintf("%s: token %s rejected with %d: %s", e.Service, e.Token, e.StatusCode, e.Reason)
+}
+
+// Temporary reports whether the service may accept a later retry
+func (e *PushError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Unregistered reports whether the token is no longer valid and should be
+// removed from the recipient's devices
+func (e *PushError) Unregistered() bool {
+	return e.StatusCode == http.StatusGone || e.Reason == "BadDeviceToken" ||
+		e.Reason == "Unregistered" || e.Reason == "UNREGISTERED"
+}
+
+// PushDeliveryError lists the device tokens a push could not be delivered
+// to; every other token was delivered. It is temporary when any failed
+// token may be retried, and RetryNotifier then resends to those tokens only.
+type PushDeliveryError struct {
+	Service string   // "apns" or "fcm"
+	Tokens  []string // failed tokens, in send order
+	Errs    []error  // the failure for each token in Tokens
+}
+
+func (e *PushDeliveryError) Error() string {
+	reasons := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		reasons[i] = err.Error()
+	}
+	return fmt.Sprintf("%s: delivery failed for %d token(s): %s", e.Service, len(e.Tokens), strings.Join(reasons, "; "))
+}
+
+// Unwrap returns the per-token errors
+func (e *PushDeliveryError) Unwrap() []error {
+	return e.Errs
+}
+
+// Temporary reports whether any failed token is worth retrying
+func (e *PushDeliveryError) Temporary() bool {
+	for _, err := range e.Errs {
+		if IsRetryable(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// Retry narrows msg to the failed tokens worth retrying and returns the
+// failures of the other tokens, or nil when there are none
+func (e *PushDeliveryError) Retry(msg Message) (Message, error) {
+	permanent := &PushDeliveryError{Service: e.Service}
+	msg.To = nil
+	for i, token := range e.Tokens {
+		if IsRetryable(e.Errs[i]) {
+			msg.To = append(msg.To, token)
+			continue
+		}
+		permanent.add(token, e.Errs[i])
+	}
+	return msg, permanent.err()
+}
+
+// Failed returns the failed tokens
+func (e *PushDeliveryError) Failed() []string {
+	return e.Tokens
+}
+
+func (e *PushDeliveryError) add(token string, err error) {
+	e.Tokens = append(e.Tokens, token)
+	e.Errs = append(e.Errs, err)
+}
+
+// err returns e, or nil when no token failed
+func (e *PushDeliveryError) err() error {
+	if len(e.Tokens) == 0 {
+		return nil
+	}
+	return e
+}
+
+// APNsConfig configures delivery through Apple Push Notification service
+// with token-based (JWT) authentication
+type APNsConfig struct {
+	Endpoint string // defaults to APNsProductionEndpoint
+	Topic    string // app bundle ID, sent as apns-topic
+
+	TeamID string
+	KeyID  string
+	Key    *ecdsa.PrivateKey // P-256 key from the .p8 file, see ParseAPNsKey
+
+	Defaults PushOptions
+	Client   *http.Client // defaults to a client with a 10s timeout
+}
+
+// FCMConfig configures delivery through the Firebase Cloud Messaging HTTP v1 API
+type FCMConfig struct {
+	Endpoint  string // defaults to FCMEndpoint
+	ProjectID string
+
+	// TokenSource returns an OAuth 2.0 access token for the
+	// firebase.messaging scope
+	TokenSource func(ctx context.Context) (string, error)
+
+	Defaults PushOptions
+	Client   *http.Client // defaults to a client with a 10s timeout
+}
+
+// NewAPNsPushNotifier creates a PushNotifier that delivers through APNs
+func NewAPNsPushNotifier(vendor string, config APNsConfig) *PushNotifier {
+	return &PushNotifier{Vendor: vendor, APNs: &config, apnsJWT: &apnsTokenCache{}}
+}
+
+// NewFCMPushNotifier creates a PushNotifier that delivers through FCM
+func NewFCMPushNotifier(vendor string, config FCMConfig) *PushNotifier {
+	return &PushNotifier{Vendor: vendor, FCM: &config}
+}
+
+// ParseAPNsKey parses the PEM-encoded PKCS #8 P-256 key downloaded from
+// Apple as a .p8 file
+func ParseAPNsKey(pemData []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("apns: key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("apns: parse key: %w", err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok || key.Curve != elliptic.P256() {
+		return nil, errors.New("apns: key must be an ECDSA P-256 key")
+	}
+	return key, nil
+}
+
+// apnsTokenLifetime is how long a provider token is reused. APNs rejects
+// tokens older than an hour and throttles refreshes under 20 minutes.
+const apnsTokenLifetime = 50 * time.Minute
+
+// apnsTokenCache reuses a signed provider token until it is due for refresh
+type apnsTokenCache struct {
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+func (c *apnsTokenCache) get(config *APNsConfig, now time.Time) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == "" || now.Sub(c.issuedAt) >= apnsTokenLifetime {
+		token, err := SignAPNsToken(config.TeamID, config.KeyID, config.Key, now)
+		if err != nil {
+			return "", err
+		}
+		c.token, c.issuedAt = token, now
+	}
+	return c.token, nil
+}
+
+// SignAPNsToken returns an ES256-signed JWT provider token
+func SignAPNsToken(teamID, keyID string, key *ecdsa.PrivateKey, issuedAt time.Time) (string, error) {
+	if key == nil || teamID == "" || keyID == "" {
+		return "", errors.New("apns: team ID, key ID and key are required")
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": keyID})
+	claims, _ := json.Marshal(map[string]any{"iss": teamID, "iat": issuedAt.Unix()})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("apns: sign token: %w", err)
+	}
+	// JWS uses the fixed-size r || s encoding, not ASN.1
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// apnsPayload is the JSON body of an APNs request. Custom data sits next
+// to "aps" at the top level.
+func apnsPayload(msg Message, opts PushOptions) map[string]any {
+	alert := map[string]string{"body": msg.Body}
+	if msg.Subject != "" {
+		alert["title"] = msg.Subject
+	}
+	aps := map[string]any{"alert": alert}
+	if opts.Badge != nil {
+		aps["badge"] = *opts.Badge
+	}
+	if opts.Sound != "" {
+		aps["sound"] = opts.Sound
+	}
+	payload := map[string]any{"aps": aps}
+	for key, value := range opts.Data {
+		if key != "aps" {
+			payload[key] = value
+		}
+	}
+	return payload
+}
+
+func (c *APNsConfig) send(ctx context.Context, tokens *apnsTokenCache, msg Message, opts PushOptions, now time.Time) error {
+	body, err := json.Marshal(apnsPayload(msg, opts))
+	if err != nil {
+		return fmt.Errorf("apns: encode payload: %w", err)
+	}
+	var jwt string
+	if tokens != nil {
+		jwt, err = tokens.get(c, now)
+	} else {
+		jwt, err = SignAPNsToken(c.TeamID, c.KeyID, c.Key, now)
+	}
+	if err != nil {
+		return err
+	}
+
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = APNsProductionEndpoint
+	}
+	priority := "10"
+	if msg.Priority == PriorityBulk {
+		priority = "5"
+	}
+
+	failed := &PushDeliveryError{Service: "apns"}
+	for _, token := range msg.To {
+		header := http.Header{}
+		header.Set("Authorization", "bearer "+jwt)
+		header.Set("apns-topic", c.Topic)
+		header.Set("apns-push-type", "alert")
+		header.Set("apns-priority", priority)
+		if opts.TTL > 0 {
+			header.Set("apns-expiration", strconv.FormatInt(now.Add(opts.TTL).Unix(), 10))
+		}
+		if opts.CollapseKey != "" {
+			header.Set("apns-collapse-id", opts.CollapseKey)
+		}
+
+		target := strings.TrimRight(endpoint, "/") + "/3/device/" + url.PathEscape(token)
+		status, respBody, err := postPush(ctx, c.Client, target, header, body)
+		if err != nil {
+			failed.add(token, fmt.Errorf("apns: post %s: %w", token, err))
+			continue
+		}
+		if status != http.StatusOK {
+			var reply struct {
+				Reason string `json:"reason"`
+			}
+			json.Unmarshal(respBody, &reply)
+			failed.add(token, &PushError{Service: "apns", Token: token, StatusCode: status, Reason: reply.Reason})
+		}
+	}
+	return failed.err()
+}
+
+// fcmMessage is the "message" object of an FCM v1 send request
+func fcmMessage(token string, msg Message, opts PushOptions) map[string]any {
+	notification := map[string]string{"body": msg.Body}
+	if msg.Subject != "" {
+		notification["title"] = msg.Subject
+	}
+	android := map[string]any{"priority": "HIGH"}
+	if msg.Priority == PriorityBulk {
+		android["priority"] = "NORMAL"
+	}
+	if opts.CollapseKey != "" {
+		android["collapse_key"] = opts.CollapseKey
+	}
+	if opts.TTL > 0 {
+		android["ttl"] = strconv.FormatInt(int64(opts.TTL/time.Second), 10) + "s"
+	}
+	androidNotification := map[string]any{}
+	if opts.Sound != "" {
+		androidNotification["sound"] = opts.Sound
+	}
+	if opts.Badge != nil {
+		androidNotification["notification_count"] = *opts.Badge
+	}
+	if len(androidNotification) > 0 {
+		android["notification"] = androidNotification
+	}
+
+	message := map[string]any{"token": token, "notification": notification, "android": android}
+	if len(opts.Data) > 0 {
+		message["data"] = opts.Data
+	}
+	// Devices on iOS receive FCM messages through APNs
+	if opts.Badge != nil || opts.Sound != "" {
+		aps := map[string]any{}
+		if opts.Badge != nil {
+			aps["badge"] = *opts.Badge
+		}
+		if opts.Sound != "" {
+			aps["sound"] = opts.Sound
+		}
+		message["apns"] = map[string]any{"payload": map[string]any{"aps": aps}}
+	}
+	return message
+}
+
+func (c *FCMConfig) send(ctx context.Context, msg Message, opts PushOptions) error {
+	if c.TokenSource == nil || c.ProjectID == "" {
+		return errors.New("fcm: project ID and token source are required")
+	}
+	accessToken, err := c.TokenSource(ctx)
+	if err != nil {
+		return fmt.Errorf("fcm: access token: %w", err)
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = FCMEndpoint
+	}
+	target := strings.TrimRight(endpoint, "/") + "/v1/projects/" + url.PathEscape(c.ProjectID) + "/messages:send"
+
+	failed := &PushDeliveryError{Service: "fcm"}
+	for _, token := range msg.To {
+		body, err := json.Marshal(map[string]any{"message": fcmMessage(token, msg, opts)})
+		if err != nil {
+			return fmt.Errorf("fcm: encode payload: %w", err)
+		}
+		header := http.Header{}
+		header.Set("Authorization", "Bearer "+accessToken)
+
+		status, respBody, err := postPush(ctx, c.Client, target, header, body)
+		if err != nil {
+			failed.add(token, fmt.Errorf("fcm: post %s: %w", token, err))
+			continue
+		}
+		if status != http.StatusOK {
+			var reply struct {
+				Error struct {
+					Status  string `json:"status"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			json.Unmarshal(respBody, &reply)
+			reason := reply.Error.Status
+			if reason == "" {
+				reason = reply.Error.Message
+			}
+			failed.add(token, &PushError{Service: "fcm", Token: token, StatusCode: status, Reason: reason})
+		}
+	}
+	return failed.err()
+}
+
+var defaultPushClient = &http.Client{Timeout: 10 * time.Second}
+
+// postPush POSTs a JSON body and returns the status and a bounded response body
+func postPush(ctx context.Context, client *http.Client, target string, header http.Header, body []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = defaultPushClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return resp.StatusCode, respBody, nil
+}
@@ -0,0 +1,404 @@
+package factory
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pushRequest is one request captured by a push service stand-in
+type pushRequest struct {
+	path   string
+	header http.Header
+	body   map[string]any
+}
+
+// pushServer records requests and answers each token from replies,
+// defaulting to 200
+type pushServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []pushRequest
+}
+
+func newPushServer(t *testing.T, replies map[string]func(w http.ResponseWriter)) *pushServer {
+	t.Helper()
+	s := &pushServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		json.Unmarshal(data, &body)
+		s.mu.Lock()
+		s.requests = append(s.requests, pushRequest{path: r.URL.Path, header: r.Header.Clone(), body: body})
+		s.mu.Unlock()
+
+		for token, reply := range replies {
+			if strings.Contains(r.URL.Path, token) || strings.Contains(string(data), token) {
+				reply(w)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, `{}`)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *pushServer) Requests() []pushRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]pushRequest(nil), s.requests...)
+}
+
+func newTestAPNsKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// verifyES256 checks a JWT signature against the public key and returns its
+// decoded header and claims
+func verifyES256(t *testing.T, token string, public *ecdsa.PublicKey) (header, claims map[string]any) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected a three-part JWT, got %q", token)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		t.Fatalf("expected a 64-byte raw signature, got %d bytes (%v)", len(signature), err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(public, digest[:], r, s) {
+		t.Fatal("expected the JWT signature to verify with the public key")
+	}
+	for i, target := range []*map[string]any{&header, &claims} {
+		data, _ := base64.RawURLEncoding.DecodeString(parts[i])
+		if err := json.Unmarshal(data, target); err != nil {
+			t.Fatalf("decode JWT part %d: %v", i, err)
+		}
+	}
+	return header, claims
+}
+
+// ============================================================================
+// PUSH NOTIFICATION TESTS
+// ============================================================================
+
+func TestAPNsPushNotifier_SendsSignedPayload(t *testing.T) {
+	server := newPushServer(t, nil)
+	key := newTestAPNsKey(t)
+	notifier := NewAPNsPushNotifier("apple", APNsConfig{
+		Endpoint: server.URL,
+		Topic:    "com.example.app",
+		TeamID:   "TEAM123456",
+		KeyID:    "KEY1234567",
+		Key:      key,
+		Defaults: PushOptions{Sound: "default"},
+	})
+
+	before := time.Now()
+	err := notifier.SendMessage(context.Background(), Message{
+		To:      []string{"device-a", "device-b"},
+		Subject: "Order shipped",
+		Body:    "Your order is on its way",
+		Metadata: map[string]string{
+			PushBadgeKey:       "3",
+			PushCollapseKeyKey: "order-42",
+			PushTTLKey:         "1h",
+			"order_id":         "42",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 || requests[0].path != "/3/device/device-a" || requests[1].path != "/3/device/device-b" {
+		t.Fatalf("expected one request per device token, got %+v", requests)
+	}
+	req := requests[0]
+	for name, want := range map[string]string{
+		"apns-topic":       "com.example.app",
+		"apns-push-type":   "alert",
+		"apns-priority":    "10",
+		"apns-collapse-id": "order-42",
+		"Content-Type":     "application/json",
+	} {
+		if got := req.header.Get(name); got != want {
+			t.Errorf("expected %s %q, got %q", name, want, got)
+		}
+	}
+	expiration, _ := strconv.ParseInt(req.header.Get("apns-expiration"), 10, 64)
+	if want := before.Add(time.Hour).Unix(); expiration < want || expiration > want+5 {
+		t.Errorf("expected apns-expiration about an hour from now, got %d", expiration)
+	}
+
+	want := map[string]any{
+		"aps": map[string]any{
+			"alert": map[string]any{"title": "Order shipped", "body": "Your order is on its way"},
+			"badge": float64(3),
+			"sound": "default",
+		},
+		"order_id": "42",
+	}
+	if !reflect.DeepEqual(req.body, want) {
+		t.Errorf("expected payload %v, got %v", want, req.body)
+	}
+
+	auth := req.header.Get("Authorization")
+	if !strings.HasPrefix(auth, "bearer ") {
+		t.Fatalf("expected a bearer token, got %q", auth)
+	}
+	header, claims := verifyES256(t, strings.TrimPrefix(auth, "bearer "), &key.PublicKey)
+	if header["alg"] != "ES256" || header["kid"] != "KEY1234567" || claims["iss"] != "TEAM123456" {
+		t.Errorf("unexpected JWT header %v claims %v", header, claims)
+	}
+	if requests[1].header.Get("Authorization") != auth {
+		t.Error("expected the provider token to be reused between requests")
+	}
+}
+
+func TestFCMPushNotifier_SendsV1Payload(t *testing.T) {
+	server := newPushServer(t, nil)
+	notifier := NewFCMPushNotifier("firebase", FCMConfig{
+		Endpoint:    server.URL,
+		ProjectID:   "demo-project",
+		TokenSource: func(context.Context) (string, error) { return "ya29.token", nil },
+	})
+
+	err := notifier.SendMessage(context.Background(), Message{
+		To:       []string{"fcm-token"},
+		Subject:  "Sale",
+		Body:     "20% off today",
+		Priority: PriorityBulk,
+		Metadata: map[string]string{
+			PushBadgeKey:       "1",
+			PushSoundKey:       "chime",
+			PushCollapseKeyKey: "sale",
+			PushTTLKey:         "90m",
+			"campaign":         "spring",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0].path != "/v1/projects/demo-project/messages:send" {
+		t.Fatalf("expected one request to the v1 send endpoint, got %+v", requests)
+	}
+	if got := requests[0].header.Get("Authorization"); got != "Bearer ya29.token" {
+		t.Errorf("expected the access token, got %q", got)
+	}
+	want := map[string]any{
+		"message": map[string]any{
+			"token":        "fcm-token",
+			"notification": map[string]any{"title": "Sale", "body": "20% off today"},
+			"data":         map[string]any{"campaign": "spring"},
+			"android": map[string]any{
+				"priority":     "NORMAL",
+				"collapse_key": "sale",
+				"ttl":          "5400s",
+				"notification": map[string]any{"sound": "chime", "notification_count": float64(1)},
+			},
+			"apns": map[string]any{"payload": map[string]any{"aps": map[string]any{"badge": float64(1), "sound": "chime"}}},
+		},
+	}
+	if !reflect.DeepEqual(requests[0].body, want) {
+		t.Errorf("expected payload %v, got %v", want, requests[0].body)
+	}
+}
+
+func TestParseAPNsKey(t *testing.T) {
+	key := newTestAPNsKey(t)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseAPNsKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !parsed.Equal(key) {
+		t.Error("expected the parsed key to equal the original")
+	}
+}
+
+func TestAPNsPushNotifier_UsesClock(t *testing.T) {
+	server := newPushServer(t, nil)
+	key := newTestAPNsKey(t)
+	clock := newFakeClock()
+	notifier := NewAPNsPushNotifier("apple", APNsConfig{Endpoint: server.URL, TeamID: "T", KeyID: "K", Key: key})
+	notifier.Clock = clock
+
+	notifier.SendMessage(context.Background(), Message{To: []string{"device-a"}, Body: "hi", Metadata: map[string]string{PushTTLKey: "1h"}})
+
+	req := server.Requests()[0]
+	if got, want := req.header.Get("apns-expiration"), strconv.FormatInt(clock.Now().Add(time.Hour).Unix(), 10); got != want {
+		t.Errorf("expected apns-expiration %s from the clock, got %s", want, got)
+	}
+	_, claims := verifyES256(t, strings.TrimPrefix(req.header.Get("Authorization"), "bearer "), &key.PublicKey)
+	if claims["iat"] != float64(clock.Now().Unix()) {
+		t.Errorf("expected the token issued at %d, got %v", clock.Now().Unix(), claims["iat"])
+	}
+}
+
+func TestPushNotifier_RetriesOnlyFailedTokens(t *testing.T) {
+	var busyCalls int
+	server := newPushServer(t, map[string]func(w http.ResponseWriter){
+		"stale": func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusGone)
+			io.WriteString(w, `{"reason":"Unregistered"}`)
+		},
+		"busy": func(w http.ResponseWriter) {
+			if busyCalls++; busyCalls == 1 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				io.WriteString(w, `{"reason":"TooManyRequests"}`)
+				return
+			}
+			io.WriteString(w, `{}`)
+		},
+	})
+	clock := newFakeClock()
+	clock.autoAdvance = true
+	apns := NewAPNsPushNotifier("apple", APNsConfig{
+		Endpoint: server.URL, Topic: "com.example.app", TeamID: "T", KeyID: "K", Key: newTestAPNsKey(t),
+	})
+	notifier := NewRetryNotifier(apns, RetryPolicy{MaxAttempts: 3, Clock: clock, Rand: noJitter})
+
+	err := notifier.SendMessage(context.Background(), Message{To: []string{"stale", "ok", "busy"}, Body: "hi"})
+
+	var paths []string
+	for _, req := range server.Requests() {
+		paths = append(paths, strings.TrimPrefix(req.path, "/3/device/"))
+	}
+	if want := []string{"stale", "ok", "busy", "busy"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("expected only the throttled token to be retried, got %v", paths)
+	}
+	var pushErr *PushError
+	if !errors.As(err, &pushErr) || pushErr.Token != "stale" || !pushErr.Unregistered() {
+		t.Errorf("expected the stale token still reported after the retry, got %v", err)
+	}
+}
+
+func TestPushNotifier_WithoutServiceOnlyLogs(t *testing.T) {
+	if err := NewPushNotifier("fcm").Send("hello"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestAPNsPushNotifier_ReportsRejectedTokens(t *testing.T) {
+	server := newPushServer(t, map[string]func(w http.ResponseWriter){
+		"stale": func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusGone)
+			io.WriteString(w, `{"reason":"Unregistered"}`)
+		},
+		"busy": func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"reason":"TooManyRequests"}`)
+		},
+	})
+	notifier := NewAPNsPushNotifier("apple", APNsConfig{
+		Endpoint: server.URL, Topic: "com.example.app", TeamID: "T", KeyID: "K", Key: newTestAPNsKey(t),
+	})
+
+	err := notifier.SendMessage(context.Background(), Message{To: []string{"stale", "ok", "busy"}, Body: "hi"})
+
+	var pushErr *PushError
+	if !errors.As(err, &pushErr) || pushErr.Token != "stale" || !pushErr.Unregistered() || pushErr.Temporary() {
+		t.Fatalf("expected an unregistered, permanent error for the stale token, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "busy rejected with 429: TooManyRequests") {
+		t.Errorf("expected the throttled token to be reported, got %v", err)
+	}
+	if len(server.Requests()) != 3 {
+		t.Errorf("expected every token to be attempted, got %d requests", len(server.Requests()))
+	}
+}
+
+func TestFCMPushNotifier_ServerErrorIsRetryable(t *testing.T) {
+	server := newPushServer(t, map[string]func(w http.ResponseWriter){
+		"fcm-token": func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, `{"error":{"code":503,"status":"UNAVAILABLE","message":"try later"}}`)
+		},
+	})
+	notifier := NewFCMPushNotifier("firebase", FCMConfig{
+		Endpoint:    server.URL,
+		ProjectID:   "demo-project",
+		TokenSource: func(context.Context) (string, error) { return "t", nil },
+	})
+
+	err := notifier.SendMessage(context.Background(), Message{To: []string{"fcm-token"}, Body: "hi"})
+	var pushErr *PushError
+	if !errors.As(err, &pushErr) || pushErr.Reason != "UNAVAILABLE" || !IsRetryable(err) {
+		t.Errorf("expected a retryable UNAVAILABLE error, got %v", err)
+	}
+}
+
+func TestPushNotifier_RejectsBadInput(t *testing.T) {
+	notifier := NewFCMPushNotifier("firebase", FCMConfig{
+		ProjectID:   "demo-project",
+		TokenSource: func(context.Context) (string, error) { return "t", nil },
+	})
+
+	if err := notifier.Send("no recipients"); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("expected a missing device token to be rejected, got %v", err)
+	}
+	err := notifier.SendMessage(context.Background(), Message{
+		To: []string{"fcm-token"}, Body: "hi", Metadata: map[string]string{PushBadgeKey: "-1"},
+	})
+	if !errors.Is(err, ErrInvalidMessage) || !IsPermanent(err) {
+		t.Errorf("expected a negative badge to be a permanent validation error, got %v", err)
+	}
+	if _, err := ParseAPNsKey([]byte("not a key")); err == nil {
+		t.Error("expected a non-PEM key to be rejected")
+	}
+}
+
+func TestPushNotifier_RejectsBothServices(t *testing.T) {
+	server := newPushServer(t, nil)
+	notifier := NewAPNsPushNotifier("apple", APNsConfig{
+		Endpoint: server.URL, Topic: "com.example.app", TeamID: "T", KeyID: "K", Key: newTestAPNsKey(t),
+	})
+	notifier.FCM = &FCMConfig{
+		Endpoint:    server.URL,
+		ProjectID:   "demo-project",
+		TokenSource: func(context.Context) (string, error) { return "t", nil },
+	}
+
+	err := notifier.SendMessage(context.Background(), Message{To: []string{"token"}, Body: "hi"})
+	var validation *ValidationError
+	if !errors.As(err, &validation) || validation.Field != "APNs" || !IsPermanent(err) {
+		t.Errorf("expected APNs with FCM to be a permanent validation error, got %v", err)
+	}
+	if len(server.Requests()) != 0 {
+		t.Errorf("expected nothing sent, got %d requests", len(server.Requests()))
+	}
+}
@@ -0,0 +1,333 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is matched by every *RateLimitError
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitError is returned when a send is rejected by a Limiter
+type RateLimitError struct {
+	Key        string        // channel/vendor the limit applies to
+	RetryAfter time.Duration // when a permit is expected to be free
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s: rate limited, retry after %s", e.Key, e.RetryAfter)
+}
+
+// Is makes every RateLimitError match ErrRateLimited
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// Temporary reports true: the same send will succeed later
+func (e *RateLimitError) Temporary() bool {
+	return true
+}
+
+// Limiter decides whether one more send may happen now.
+// Implementations must be safe for concurrent use.
+type Limiter interface {
+	// Take claims a permit if one is available now. Otherwise it claims
+	// nothing and reports how long until a permit may be available.
+	Take() (ok bool, retryAfter time.Duration)
+}
+
+// TokenBucket allows bursts of up to burst sends, refilling at limit per period.
+// It starts full.
+type TokenBucket struct {
+	clock    Clock
+	rate     float64 // tokens per nanosecond
+	capacity float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a token bucket refilling limit tokens every per.
+// Non-positive values default to 1 per second with a burst of 1.
+func NewTokenBucket(limit int, per time.Duration, burst int, clock Clock) *TokenBucket {
+	if clock == nil {
+		clock = SystemClock
+	}
+	limit, per = defaultRate(limit, per)
+	if burst <= 0 {
+		burst = 1
+	}
+	return &TokenBucket{
+		clock:    clock,
+		rate:     float64(limit) / float64(per),
+		capacity: float64(burst),
+		tokens:   float64(burst),
+		last:     clock.Now(),
+	}
+}
+
+func (b *TokenBucket) Take() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	b.tokens = math.Min(b.capacity, b.tokens+float64(now.Sub(b.last))*b.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration(math.Ceil((1 - b.tokens) / b.rate))
+}
+
+// LeakyBucket smooths sends to a constant rate. Every send adds one unit
+// to the bucket, which leaks limit units per period; a send that would
+// overflow capacity is refused. Unlike TokenBucket it starts empty, so
+// with capacity 1 sends are spaced evenly with no initial burst.
+type LeakyBucket struct {
+	clock    Clock
+	interval time.Duration // time for one unit to leak
+	capacity time.Duration // capacity expressed as drain time
+
+	mu sync.Mutex
+	// emptyAt is when the bucket will have fully drained
+	emptyAt time.Time
+}
+
+// NewLeakyBucket creates a leaky bucket draining limit units every per.
+// Non-positive values default to 1 per second with a capacity of 1.
+func NewLeakyBucket(limit int, per time.Duration, capacity int, clock Clock) *LeakyBucket {
+	if clock == nil {
+		clock = SystemClock
+	}
+	limit, per = defaultRate(limit, per)
+	if capacity <= 0 {
+		capacity = 1
+	}
+	interval := per / time.Duration(limit)
+	return &LeakyBucket{
+		clock:    clock,
+		interval: interval,
+		capacity: interval * time.Duration(capacity),
+		emptyAt:  clock.Now(),
+	}
+}
+
+func (b *LeakyBucket) Take() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	emptyAt := b.emptyAt
+	if emptyAt.Before(now) {
+		emptyAt = now
+	}
+
+	// Adding one unit must keep the level within capacity
+	level := emptyAt.Sub(now) + b.interval
+	if level > b.capacity {
+		return false, level - b.capacity
+	}
+	b.emptyAt = emptyAt.Add(b.interval)
+	return true, 0
+}
+
+// SlidingWindow allows at most limit sends in any window-long interval.
+// It keeps a log of send times, like a Redis sorted set trimmed by score.
+type SlidingWindow struct {
+	clock  Clock
+	limit  int
+	window time.Duration
+
+	mu  sync.Mutex
+	log []time.Time // oldest first
+}
+
+// NewSlidingWindow creates a sliding window log limiter.
+// Non-positive values default to 1 per second.
+func NewSlidingWindow(limit int, window time.Duration, clock Clock) *SlidingWindow {
+	if clock == nil {
+		clock = SystemClock
+	}
+	limit, window = defaultRate(limit, window)
+	return &SlidingWindow{clock: clock, limit: limit, window: window}
+}
+
+func (w *SlidingWindow) Take() (bool, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.clock.Now()
+	start := now.Add(-w.window)
+	expired := 0
+	for expired < len(w.log) && !w.log[expired].After(start) {
+		expired++
+	}
+	w.log = w.log[expired:]
+
+	if len(w.log) >= w.limit {
+		return false, w.log[0].Sub(start)
+	}
+	w.log = append(w.log, now)
+	return true, 0
+}
+
+// RateLimitMode decides what happens when no permit is available
+type RateLimitMode int
+
+const (
+	// RateLimitWait blocks until a permit is free or ctx ends
+	RateLimitWait RateLimitMode = iota
+	// RateLimitReject fails immediately with *RateLimitError
+	RateLimitReject
+)
+
+// RateLimitPolicy configures a RateLimitNotifier
+type RateLimitPolicy struct {
+	Limiter Limiter
+	Mode    RateLimitMode
+
+	// MaxWait makes RateLimitWait reject instead when the expected wait is
+	// longer. Zero waits as long as needed.
+	MaxWait time.Duration
+
+	Key   string // reported in errors, defaults to the wrapped vendor name
+	Clock Clock  // used for waiting, defaults to SystemClock
+}
+
+// RateLimitNotifier decorates a Notifier with a Limiter
+type RateLimitNotifier struct {
+	next   Notifier
+	policy RateLimitPolicy
+}
+
+// NewRateLimitNotifier wraps next with the given policy, filling in defaults
+func NewRateLimitNotifier(next Notifier, policy RateLimitPolicy) (*RateLimitNotifier, error) {
+	if policy.Limiter == nil {
+		return nil, errors.New("ratelimit: limiter is required")
+	}
+	if policy.Key == "" {
+		policy.Key = vendorName(next)
+	}
+	if policy.Clock == nil {
+		policy.Clock = SystemClock
+	}
+	return &RateLimitNotifier{next: next, policy: policy}, nil
+}
+
+// VendorName passes through the wrapped notifier's vendor
+func (r *RateLimitNotifier) VendorName() string {
+	return vendorName(r.next)
+}
+
+// Send waits for or rejects on the limiter, then sends
+func (r *RateLimitNotifier) Send(msg string) error {
+	return r.SendContext(context.Background(), msg)
+}
+
+// SendContext is Send where ctx bounds the wait for a permit
+func (r *RateLimitNotifier) SendContext(ctx context.Context, msg string) error {
+	return r.SendMessage(ctx, Message{Body: msg})
+}
+
+// SendMessage rate limits a structured message like SendContext
+func (r *RateLimitNotifier) SendMessage(ctx context.Context, msg Message) error {
+	if err := r.acquire(ctx); err != nil {
+		return err
+	}
+	return SendMessage(ctx, r.next, msg)
+}
+
+func (r *RateLimitNotifier) acquire(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ok, retryAfter := r.policy.Limiter.Take()
+		if ok {
+			return nil
+		}
+		if r.policy.Mode == RateLimitReject || (r.policy.MaxWait > 0 && retryAfter > r.policy.MaxWait) {
+			return &RateLimitError{Key: r.policy.Key, RetryAfter: retryAfter}
+		}
+		select {
+		case <-r.policy.Clock.After(retryAfter):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// RateLimits hands out one shared Limiter per channel and vendor, so every
+// notifier for the same vendor draws from the same budget
+type RateLimits struct {
+	newLimiter func(channel, vendor string) Limiter
+
+	mu       sync.Mutex
+	limiters map[string]Limiter
+}
+
+// NewRateLimits creates a set of limiters. newLimiter builds the limiter
+// for a channel/vendor pair on first use and may return nil for no limit;
+// a nil newLimiter only uses limiters added with Set.
+func NewRateLimits(newLimiter func(channel, vendor string) Limiter) *RateLimits {
+	return &RateLimits{newLimiter: newLimiter, limiters: make(map[string]Limiter)}
+}
+
+// Set installs the limiter for a channel/vendor pair
+func (r *RateLimits) Set(channel, vendor string, limiter Limiter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limiters[rateLimitKey(channel, vendor)] = limiter
+}
+
+// Limiter returns the limiter for a channel/vendor pair, or nil if unlimited
+func (r *RateLimits) Limiter(channel, vendor string) Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := rateLimitKey(channel, vendor)
+	if limiter, ok := r.limiters[key]; ok {
+		return limiter
+	}
+	var limiter Limiter
+	if r.newLimiter != nil {
+		limiter = r.newLimiter(channel, vendor)
+	}
+	r.limiters[key] = limiter
+	return limiter
+}
+
+// Wrap rate limits n with the limiter for channel and n's vendor. The
+// policy's Limiter and Key are filled in; n is returned as is if unlimited.
+func (r *RateLimits) Wrap(channel string, n Notifier, policy RateLimitPolicy) Notifier {
+	vendor := vendorName(n)
+	limiter := r.Limiter(channel, vendor)
+	if limiter == nil {
+		return n
+	}
+	policy.Limiter = limiter
+	policy.Key = rateLimitKey(channel, vendor)
+	wrapped, _ := NewRateLimitNotifier(n, policy)
+	return wrapped
+}
+
+func defaultRate(limit int, per time.Duration) (int, time.Duration) {
+	if limit <= 0 {
+		limit = 1
+	}
+	if per <= 0 {
+		per = time.Second
+	}
+	return limit, per
+}
+
+func rateLimitKey(channel, vendor string) string {
+	return channel + "/" + vendor
+}
@@ -0,0 +1,275 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// takeN calls Take n times and returns how many were allowed
+func takeN(l Limiter, n int) int {
+	allowed := 0
+	for i := 0; i < n; i++ {
+		if ok, _ := l.Take(); ok {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+// ============================================================================
+// LIMITER TESTS
+// ============================================================================
+
+func TestTokenBucket_BurstThenRefill(t *testing.T) {
+	clock := newFakeClock()
+	bucket := NewTokenBucket(10, time.Second, 5, clock)
+
+	if got := takeN(bucket, 8); got != 5 {
+		t.Errorf("expected burst of 5, got %d", got)
+	}
+	ok, retryAfter := bucket.Take()
+	if ok || retryAfter != 100*time.Millisecond {
+		t.Errorf("expected refusal with 100ms retry, got ok=%v retryAfter=%s", ok, retryAfter)
+	}
+
+	clock.Advance(300 * time.Millisecond)
+	if got := takeN(bucket, 5); got != 3 {
+		t.Errorf("expected 3 tokens after 300ms, got %d", got)
+	}
+
+	clock.Advance(time.Hour)
+	if got := takeN(bucket, 10); got != 5 {
+		t.Errorf("expected refill capped at burst 5, got %d", got)
+	}
+}
+
+func TestLeakyBucket_ConstantRate(t *testing.T) {
+	clock := newFakeClock()
+	bucket := NewLeakyBucket(4, time.Second, 1, clock)
+
+	if ok, _ := bucket.Take(); !ok {
+		t.Fatal("expected first send to be allowed")
+	}
+	ok, retryAfter := bucket.Take()
+	if ok || retryAfter != 250*time.Millisecond {
+		t.Errorf("expected refusal with 250ms retry, got ok=%v retryAfter=%s", ok, retryAfter)
+	}
+
+	// Waiting a full second still only allows one send: no burst credit builds up
+	clock.Advance(time.Second)
+	if got := takeN(bucket, 4); got != 1 {
+		t.Errorf("expected 1 send after idle period, got %d", got)
+	}
+}
+
+func TestLeakyBucket_CapacityAbsorbsJitter(t *testing.T) {
+	clock := newFakeClock()
+	bucket := NewLeakyBucket(1, time.Second, 3, clock)
+
+	if got := takeN(bucket, 5); got != 3 {
+		t.Errorf("expected capacity of 3, got %d", got)
+	}
+	clock.Advance(time.Second)
+	if got := takeN(bucket, 5); got != 1 {
+		t.Errorf("expected one unit leaked per second, got %d", got)
+	}
+}
+
+func TestSlidingWindow_LimitPerWindow(t *testing.T) {
+	clock := newFakeClock()
+	window := NewSlidingWindow(3, time.Minute, clock)
+
+	takeN(window, 1)
+	clock.Advance(20 * time.Second)
+	takeN(window, 2)
+
+	ok, retryAfter := window.Take()
+	if ok || retryAfter != 40*time.Second {
+		t.Errorf("expected refusal until the first send expires in 40s, got ok=%v retryAfter=%s", ok, retryAfter)
+	}
+
+	clock.Advance(40 * time.Second)
+	if got := takeN(window, 3); got != 1 {
+		t.Errorf("expected exactly one slot to free up, got %d", got)
+	}
+}
+
+func TestTokenBucket_ConcurrentTake(t *testing.T) {
+	bucket := NewTokenBucket(1, time.Hour, 50, newFakeClock())
+	var allowed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := bucket.Take(); ok {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed.Load() != 50 {
+		t.Errorf("expected exactly 50 permits, got %d", allowed.Load())
+	}
+}
+
+// ============================================================================
+// RATE LIMIT NOTIFIER TESTS
+// ============================================================================
+
+func TestRateLimitNotifier_WaitsForPermit(t *testing.T) {
+	clock := newFakeClock()
+	clock.autoAdvance = true
+	inner := &scriptedNotifier{vendor: "twilio"}
+
+	notifier, err := NewRateLimitNotifier(inner, RateLimitPolicy{
+		Limiter: NewTokenBucket(2, time.Second, 1, clock),
+		Clock:   clock,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := notifier.Send("otp"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if inner.Calls() != 3 {
+		t.Errorf("expected 3 sends, got %d", inner.Calls())
+	}
+	expected := []time.Duration{500 * time.Millisecond, 500 * time.Millisecond}
+	if got := clock.Sleeps(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected waits %v, got %v", expected, got)
+	}
+}
+
+func TestRateLimits_SharedPerVendor(t *testing.T) {
+	clock := newFakeClock()
+	limits := NewRateLimits(func(channel, vendor string) Limiter {
+		if vendor == "unlimited" {
+			return nil
+		}
+		return NewSlidingWindow(1, time.Minute, clock)
+	})
+	policy := RateLimitPolicy{Mode: RateLimitReject}
+
+	first := limits.Wrap("sms", &SmsNotifier{Vendor: "twilio"}, policy)
+	second := limits.Wrap("sms", &SmsNotifier{Vendor: "twilio"}, policy)
+	other := limits.Wrap("sms", &SmsNotifier{Vendor: "nexmo"}, policy)
+
+	if err := first.Send("a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := second.Send("b"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected second twilio notifier to share the limit, got %v", err)
+	}
+	if err := other.Send("c"); err != nil {
+		t.Errorf("expected nexmo to have its own limit, got %v", err)
+	}
+
+	unlimited := &SmsNotifier{Vendor: "unlimited"}
+	if got := limits.Wrap("sms", unlimited, policy); got != Notifier(unlimited) {
+		t.Errorf("expected unlimited vendor to be returned unwrapped, got %T", got)
+	}
+	if limits.Limiter("sms", "twilio") != limits.Limiter("sms", "twilio") {
+		t.Error("expected the same limiter for the same channel and vendor")
+	}
+}
+
+func TestRateLimits_Set(t *testing.T) {
+	limits := NewRateLimits(nil)
+	bucket := NewTokenBucket(1, time.Second, 1, newFakeClock())
+	limits.Set("email", "ses", bucket)
+
+	if limits.Limiter("email", "ses") != Limiter(bucket) {
+		t.Error("expected the installed limiter")
+	}
+	if limits.Limiter("email", "sendgrid") != nil {
+		t.Error("expected no limiter for an unknown vendor")
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestRateLimitNotifier_RejectsWithTypedError(t *testing.T) {
+	clock := newFakeClock()
+	inner := &scriptedNotifier{vendor: "twilio"}
+	notifier, _ := NewRateLimitNotifier(inner, RateLimitPolicy{
+		Limiter: NewSlidingWindow(1, time.Minute, clock),
+		Mode:    RateLimitReject,
+		Clock:   clock,
+	})
+
+	notifier.Send("first")
+	err := notifier.Send("second")
+
+	var limitErr *RateLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected *RateLimitError, got %v", err)
+	}
+	if limitErr.Key != "twilio" || limitErr.RetryAfter != time.Minute {
+		t.Errorf("unexpected error details: %+v", limitErr)
+	}
+	if !errors.Is(err, ErrRateLimited) || !IsRetryable(err) {
+		t.Errorf("expected ErrRateLimited and retryable, got %v", err)
+	}
+	if inner.Calls() != 1 {
+		t.Errorf("expected rejected send not to reach the vendor, got %d calls", inner.Calls())
+	}
+}
+
+func TestRateLimitNotifier_MaxWait(t *testing.T) {
+	clock := newFakeClock()
+	notifier, _ := NewRateLimitNotifier(&scriptedNotifier{}, RateLimitPolicy{
+		Limiter: NewSlidingWindow(1, time.Minute, clock),
+		MaxWait: time.Second,
+		Clock:   clock,
+	})
+
+	notifier.Send("first")
+	if err := notifier.Send("second"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited when the wait exceeds MaxWait, got %v", err)
+	}
+	if len(clock.Sleeps()) != 0 {
+		t.Errorf("expected no waiting, got %v", clock.Sleeps())
+	}
+}
+
+func TestRateLimitNotifier_CancelWhileWaiting(t *testing.T) {
+	clock := newFakeClock()
+	inner := &scriptedNotifier{}
+	notifier, _ := NewRateLimitNotifier(inner, RateLimitPolicy{
+		Limiter: NewTokenBucket(1, time.Minute, 1, clock),
+		Clock:   clock,
+	})
+	notifier.Send("first")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- notifier.SendContext(ctx, "second") }()
+
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if inner.Calls() != 1 {
+		t.Errorf("expected cancelled send not to reach the vendor, got %d calls", inner.Calls())
+	}
+}
+
+func TestNewRateLimitNotifier_RequiresLimiter(t *testing.T) {
+	if _, err := NewRateLimitNotifier(&scriptedNotifier{}, RateLimitPolicy{}); err == nil {
+		t.Error("expected error without a limiter, got nil")
+	}
+}
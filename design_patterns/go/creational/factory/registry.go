@@ -0,0 +1,137 @@
+package factory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// NotifierConstructor builds a Notifier for the given vendor
+type NotifierConstructor func(vendor string) (Notifier, error)
+
+var (
+	// ErrUnknownNotifier is returned when no constructor is registered for a type
+	ErrUnknownNotifier = errors.New("unknown notifier type")
+	// ErrDuplicateNotifier is returned when a type is registered twice
+	ErrDuplicateNotifier = errors.New("notifier type already registered")
+)
+
+// Registry maps notifier types to their constructors.
+// It is safe for concurrent use.
+type Registry struct {
+	mu           sync.RWMutex
+	constructors map[string]NotifierConstructor
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		constructors: make(map[string]NotifierConstructor),
+	}
+}
+
+// Register adds a constructor for notifierType.
+// Registering the same type twice is an error - Unregister it first.
+func (r *Registry) Register(notifierType string, constructor NotifierConstructor) error {
+	if notifierType == "" {
+		return errors.New("notifier type must not be empty")
+	}
+	if constructor == nil {
+		return fmt.Errorf("nil constructor for notifier type: %s", notifierType)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.constructors[notifierType]; exists {
+		return fmt.Errorf("%w: %s", ErrDuplicateNotifier, notifierType)
+	}
+	r.constructors[notifierType] = constructor
+	return nil
+}
+
+// Unregister removes the constructor for notifierType
+func (r *Registry) Unregister(notifierType string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.constructors[notifierType]; !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownNotifier, notifierType)
+	}
+	delete(r.constructors, notifierType)
+	return nil
+}
+
+// Lookup returns the constructor registered for notifierType
+func (r *Registry) Lookup(notifierType string) (NotifierConstructor, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	constructor, ok := r.constructors[notifierType]
+	return constructor, ok
+}
+
+// List returns all registered notifier types in sorted order
+func (r *Registry) List() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.constructors))
+	for notifierType := range r.constructors {
+		types = append(types, notifierType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New creates a Notifier of the given type using its registered constructor
+func (r *Registry) New(notifierType, vendor string) (Notifier, error) {
+	constructor, ok := r.Lookup(notifierType)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownNotifier, notifierType)
+	}
+
+	notifier, err := constructor(vendor)
+	if err != nil {
+		return nil, err
+	}
+	return notifier, nil
+}
+
+// defaultRegistry backs NotifierFactory and the package-level helpers
+var defaultRegistry = newDefaultRegistry()
+
+func newDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register("email", func(vendor string) (Notifier, error) { return NewEmailNotifier(vendor), nil })
+	r.Register("sms", func(vendor string) (Notifier, error) { return NewSmsNotifier(vendor), nil })
+	r.Register("push", func(vendor string) (Notifier, error) { return NewPushNotifier(vendor), nil })
+	r.Register("webhook", newWebhookFromFactory)
+	return r
+}
+
+// DefaultRegistry returns the registry used by NotifierFactory
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// Register adds a constructor to the default registry
+func Register(notifierType string, constructor NotifierConstructor) error {
+	return defaultRegistry.Register(notifierType, constructor)
+}
+
+// Unregister removes a constructor from the default registry
+func Unregister(notifierType string) error {
+	return defaultRegistry.Unregister(notifierType)
+}
+
+// Lookup finds a constructor in the default registry
+func Lookup(notifierType string) (NotifierConstructor, bool) {
+	return defaultRegistry.Lookup(notifierType)
+}
+
+// List returns the notifier types known to the default registry
+func List() []string {
+	return defaultRegistry.List()
+}
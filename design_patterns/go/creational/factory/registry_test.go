@@ -0,0 +1,211 @@
+package factory
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// slackNotifier is a custom notifier plugged in from "outside" the factory
+type slackNotifier struct {
+	workspace string
+}
+
+func (s *slackNotifier) Send(msg string) error {
+	return nil
+}
+
+func newSlackNotifier(vendor string) (Notifier, error) {
+	return &slackNotifier{workspace: vendor}, nil
+}
+
+// ============================================================================
+// REGISTRY TESTS
+// ============================================================================
+
+func TestRegistry_RegisterAndLookup(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register("slack", newSlackNotifier); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	constructor, ok := r.Lookup("slack")
+	if !ok {
+		t.Fatal("expected constructor for 'slack'")
+	}
+
+	notifier, err := constructor("acme")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	slack, ok := notifier.(*slackNotifier)
+	if !ok {
+		t.Fatalf("expected *slackNotifier, got %T", notifier)
+	}
+	if slack.workspace != "acme" {
+		t.Errorf("expected workspace 'acme', got '%s'", slack.workspace)
+	}
+}
+
+func TestRegistry_New(t *testing.T) {
+	r := NewRegistry()
+	r.Register("slack", newSlackNotifier)
+
+	notifier, err := r.New("slack", "acme")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := notifier.(*slackNotifier); !ok {
+		t.Errorf("expected *slackNotifier, got %T", notifier)
+	}
+}
+
+func TestRegistry_ListIsSorted(t *testing.T) {
+	r := NewRegistry()
+	r.Register("sms", newSlackNotifier)
+	r.Register("email", newSlackNotifier)
+	r.Register("push", newSlackNotifier)
+
+	expected := []string{"email", "push", "sms"}
+	if got := r.List(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestRegistry_Unregister(t *testing.T) {
+	r := NewRegistry()
+	r.Register("slack", newSlackNotifier)
+
+	if err := r.Unregister("slack"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := r.Lookup("slack"); ok {
+		t.Error("expected 'slack' to be gone after Unregister")
+	}
+
+	// Can register again once removed
+	if err := r.Register("slack", newSlackNotifier); err != nil {
+		t.Errorf("expected re-register to succeed, got %v", err)
+	}
+}
+
+func TestDefaultRegistry_HasBuiltInNotifiers(t *testing.T) {
+	for _, notifierType := range []string{"email", "sms", "push", "webhook"} {
+		if _, ok := Lookup(notifierType); !ok {
+			t.Errorf("expected built-in notifier type '%s' to be registered", notifierType)
+		}
+	}
+}
+
+func TestNotifierFactory_UsesRegisteredConstructor(t *testing.T) {
+	if err := Register("slack", newSlackNotifier); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer Unregister("slack")
+
+	notifier, err := NotifierFactory("slack", "acme")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := notifier.(*slackNotifier); !ok {
+		t.Errorf("expected *slackNotifier, got %T", notifier)
+	}
+}
+
+func TestRegistry_ConcurrentAccess(t *testing.T) {
+	r := NewRegistry()
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(3)
+		name := fmt.Sprintf("channel-%d", i)
+		go func() {
+			defer wg.Done()
+			r.Register(name, newSlackNotifier)
+		}()
+		go func() {
+			defer wg.Done()
+			r.Lookup(name)
+		}()
+		go func() {
+			defer wg.Done()
+			r.List()
+		}()
+	}
+	wg.Wait()
+
+	if got := len(r.List()); got != 50 {
+		t.Errorf("expected 50 registered types, got %d", got)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestRegistry_DuplicateRegistration(t *testing.T) {
+	r := NewRegistry()
+	r.Register("slack", newSlackNotifier)
+
+	err := r.Register("slack", newSlackNotifier)
+	if !errors.Is(err, ErrDuplicateNotifier) {
+		t.Errorf("expected ErrDuplicateNotifier, got %v", err)
+	}
+}
+
+func TestRegister_BuiltInTypeIsDuplicate(t *testing.T) {
+	err := Register("email", newSlackNotifier)
+	if !errors.Is(err, ErrDuplicateNotifier) {
+		t.Errorf("expected ErrDuplicateNotifier, got %v", err)
+	}
+}
+
+func TestRegistry_InvalidRegistration(t *testing.T) {
+	r := NewRegistry()
+
+	if err := r.Register("", newSlackNotifier); err == nil {
+		t.Error("expected error for empty notifier type, got nil")
+	}
+	if err := r.Register("slack", nil); err == nil {
+		t.Error("expected error for nil constructor, got nil")
+	}
+	if len(r.List()) != 0 {
+		t.Errorf("expected nothing registered, got %v", r.List())
+	}
+}
+
+func TestRegistry_UnregisterUnknown(t *testing.T) {
+	r := NewRegistry()
+
+	err := r.Unregister("slack")
+	if !errors.Is(err, ErrUnknownNotifier) {
+		t.Errorf("expected ErrUnknownNotifier, got %v", err)
+	}
+}
+
+func TestRegistry_NewUnknownType(t *testing.T) {
+	r := NewRegistry()
+
+	notifier, err := r.New("slack", "acme")
+	if !errors.Is(err, ErrUnknownNotifier) {
+		t.Errorf("expected ErrUnknownNotifier, got %v", err)
+	}
+	if notifier != nil {
+		t.Errorf("expected nil notifier, got %T", notifier)
+	}
+}
+
+func TestRegistry_ConstructorError(t *testing.T) {
+	r := NewRegistry()
+	r.Register("broken", func(vendor string) (Notifier, error) {
+		return nil, fmt.Errorf("vendor %s not supported", vendor)
+	})
+
+	notifier, err := r.New("broken", "acme")
+	if err == nil {
+		t.Error("expected constructor error, got nil")
+	}
+	if notifier != nil {
+		t.Errorf("expected nil notifier, got %T", notifier)
+	}
+}
@@ -0,0 +1,187 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy configures how RetryNotifier retries failed sends
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first, defaults to 3
+	BaseDelay   time.Duration // backoff before the second attempt, defaults to 100ms
+	MaxDelay    time.Duration // upper bound for any single backoff, defaults to 10s
+	Multiplier  float64       // growth factor per attempt, defaults to 2
+
+	// Retryable decides whether an error is worth another attempt.
+	// Defaults to IsRetryable.
+	Retryable func(error) bool
+
+	Clock Clock          // defaults to SystemClock
+	Rand  func() float64 // returns [0.0, 1.0) for jitter, defaults to math/rand
+}
+
+// RetryError is returned when every attempt failed, a permanent error was
+// hit, or some recipients of a PartialFailure failed for good
+type RetryError struct {
+	Attempts int
+	Err      error // last error returned by the wrapped notifier, joined with any recipients dropped earlier
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("send failed after %d attempt(s): %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// permanentError marks an error that must not be retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that retrying wrappers give up immediately
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent or is an
+// ErrInvalidMessage validation failure
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p) || errors.Is(err, ErrInvalidMessage)
+}
+
+// IsRetryable is the default retry classification. Errors marked Permanent
+// are never retried; errors exposing Temporary() (like *SMTPError and
+// *WebhookError) are retried only when temporary; anything else is retried.
+func IsRetryable(err error) bool {
+	if err == nil || IsPermanent(err) {
+		return false
+	}
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) {
+		return temporary.Temporary()
+	}
+	return true
+}
+
+// PartialFailure is implemented by errors from sends that reached only some
+// recipients, such as *PushDeliveryError. RetryNotifier resends to the
+// recipients Retry keeps and reports the rest with the final result.
+type PartialFailure interface {
+	error
+	// Retry narrows msg to the recipients worth retrying and returns the
+	// error for the recipients that failed for good, or nil
+	Retry(msg Message) (Message, error)
+	// Failed returns every recipient the send did not reach
+	Failed() []string
+}
+
+// RetryNotifier decorates a Notifier with exponential backoff and full jitter
+type RetryNotifier struct {
+	next   Notifier
+	policy RetryPolicy
+}
+
+// NewRetryNotifier wraps next with the given policy, filling in defaults
+func NewRetryNotifier(next Notifier, policy RetryPolicy) *RetryNotifier {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = 100 * time.Millisecond
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = 10 * time.Second
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 2
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = IsRetryable
+	}
+	if policy.Clock == nil {
+		policy.Clock = SystemClock
+	}
+	if policy.Rand == nil {
+		policy.Rand = rand.Float64
+	}
+	return &RetryNotifier{next: next, policy: policy}
+}
+
+// VendorName passes through the wrapped notifier's vendor
+func (r *RetryNotifier) VendorName() string {
+	return vendorName(r.next)
+}
+
+// Send tries the wrapped notifier until it succeeds, a permanent error is
+// returned or MaxAttempts is reached
+func (r *RetryNotifier) Send(msg string) error {
+	return r.SendContext(context.Background(), msg)
+}
+
+// SendContext is Send bounded by ctx. Cancellation stops the current
+// attempt when the wrapped notifier honours ctx, and always cuts the
+// backoff short; the context error is returned unwrapped.
+func (r *RetryNotifier) SendContext(ctx context.Context, msg string) error {
+	return r.SendMessage(ctx, Message{Body: msg})
+}
+
+// SendMessage retries a structured message like SendContext. After a
+// PartialFailure only the failed recipients are retried.
+func (r *RetryNotifier) SendMessage(ctx context.Context, msg Message) error {
+	var err, settled error // settled: recipients dropped from later attempts
+	for attempt := 1; attempt <= r.policy.MaxAttempts; attempt++ {
+		err = SendMessage(ctx, r.next, msg)
+		if err == nil {
+			if settled != nil {
+				return &RetryError{Attempts: attempt, Err: settled}
+			}
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if !r.policy.Retryable(err) || attempt == r.policy.MaxAttempts {
+			if settled != nil {
+				err = errors.Join(settled, err)
+			}
+			return &RetryError{Attempts: attempt, Err: err}
+		}
+		var partial PartialFailure
+		if errors.As(err, &partial) {
+			var permanent error
+			msg, permanent = partial.Retry(msg)
+			if permanent != nil {
+				settled = errors.Join(settled, permanent)
+			}
+		}
+		select {
+		case <-r.policy.Clock.After(r.backoff(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return &RetryError{Attempts: r.policy.MaxAttempts, Err: err}
+}
+
+// backoff returns the full-jitter delay after the given attempt:
+// a random duration in [0, min(MaxDelay, BaseDelay * Multiplier^(attempt-1)))
+func (r *RetryNotifier) backoff(attempt int) time.Duration {
+	ceiling := float64(r.policy.BaseDelay) * math.Pow(r.policy.Multiplier, float64(attempt-1))
+	if ceiling > float64(r.policy.MaxDelay) {
+		ceiling = float64(r.policy.MaxDelay)
+	}
+	return time.Duration(r.policy.Rand() * ceiling)
+}
@@ -0,0 +1,266 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedNotifier returns the scripted errors in order, then succeeds
+type scriptedNotifier struct {
+	mu     sync.Mutex
+	errs   []error
+	calls  int
+	sent   []string
+	vendor string
+}
+
+func (s *scriptedNotifier) Send(msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func (s *scriptedNotifier) VendorName() string {
+	return s.vendor
+}
+
+func (s *scriptedNotifier) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+var errVendorDown = errors.New("vendor down")
+
+// noJitter makes backoff equal to its ceiling
+func noJitter() float64 { return 1.0 }
+
+// ============================================================================
+// RETRY TESTS
+// ============================================================================
+
+func TestRetryNotifier_SucceedsAfterTransientFailures(t *testing.T) {
+	clock := newFakeClock()
+	clock.autoAdvance = true
+	inner := &scriptedNotifier{errs: []error{errVendorDown, errVendorDown}}
+
+	notifier := NewRetryNotifier(inner, RetryPolicy{MaxAttempts: 5, Clock: clock, Rand: noJitter})
+	if err := notifier.Send("otp 1234"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if inner.Calls() != 3 {
+		t.Errorf("expected 3 attempts, got %d", inner.Calls())
+	}
+	if !reflect.DeepEqual(inner.sent, []string{"otp 1234"}) {
+		t.Errorf("expected message delivered once, got %v", inner.sent)
+	}
+}
+
+func TestRetryNotifier_ExponentialBackoff(t *testing.T) {
+	clock := newFakeClock()
+	clock.autoAdvance = true
+	inner := &scriptedNotifier{errs: []error{errVendorDown, errVendorDown, errVendorDown, errVendorDown, errVendorDown}}
+
+	notifier := NewRetryNotifier(inner, RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    500 * time.Millisecond,
+		Multiplier:  2,
+		Clock:       clock,
+		Rand:        noJitter,
+	})
+	notifier.Send("hello")
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		500 * time.Millisecond, // capped at MaxDelay
+	}
+	if got := clock.Sleeps(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected backoff %v, got %v", expected, got)
+	}
+}
+
+func TestRetryNotifier_FullJitter(t *testing.T) {
+	clock := newFakeClock()
+	clock.autoAdvance = true
+	inner := &scriptedNotifier{errs: []error{errVendorDown, errVendorDown}}
+
+	notifier := NewRetryNotifier(inner, RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+		Clock:       clock,
+		Rand:        func() float64 { return 0.25 },
+	})
+	notifier.Send("hello")
+
+	expected := []time.Duration{250 * time.Millisecond, 500 * time.Millisecond}
+	if got := clock.Sleeps(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected jittered backoff %v, got %v", expected, got)
+	}
+}
+
+func TestRetryNotifier_Defaults(t *testing.T) {
+	notifier := NewRetryNotifier(&scriptedNotifier{}, RetryPolicy{})
+
+	if notifier.policy.MaxAttempts != 3 {
+		t.Errorf("expected default 3 attempts, got %d", notifier.policy.MaxAttempts)
+	}
+	if notifier.policy.BaseDelay != 100*time.Millisecond {
+		t.Errorf("expected default base delay 100ms, got %s", notifier.policy.BaseDelay)
+	}
+	if notifier.policy.Clock != SystemClock {
+		t.Errorf("expected SystemClock by default, got %T", notifier.policy.Clock)
+	}
+}
+
+func TestIsRetryable_Classification(t *testing.T) {
+	testCases := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"plain error", errVendorDown, true},
+		{"permanent", Permanent(errVendorDown), false},
+		{"smtp 421", &SMTPError{Code: 421}, true},
+		{"smtp 550", &SMTPError{Code: 550}, false},
+		{"webhook 503", &WebhookError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"webhook 429", &WebhookError{StatusCode: http.StatusTooManyRequests}, true},
+		{"webhook 400", &WebhookError{StatusCode: http.StatusBadRequest}, false},
+		{"nil", nil, false},
+	}
+
+	for _, tc := range testCases {
+		if got := IsRetryable(tc.err); got != tc.retryable {
+			t.Errorf("%s: expected retryable=%v, got %v", tc.name, tc.retryable, got)
+		}
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestRetryNotifier_GivesUpAfterMaxAttempts(t *testing.T) {
+	clock := newFakeClock()
+	clock.autoAdvance = true
+	inner := &scriptedNotifier{errs: []error{errVendorDown, errVendorDown, errVendorDown, errVendorDown}}
+
+	err := NewRetryNotifier(inner, RetryPolicy{MaxAttempts: 3, Clock: clock}).Send("hello")
+
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("expected *RetryError, got %v", err)
+	}
+	if retryErr.Attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", retryErr.Attempts)
+	}
+	if !errors.Is(err, errVendorDown) {
+		t.Errorf("expected last error to be wrapped, got %v", err)
+	}
+	if inner.Calls() != 3 {
+		t.Errorf("expected 3 calls, got %d", inner.Calls())
+	}
+}
+
+func TestRetryNotifier_PermanentErrorNotRetried(t *testing.T) {
+	clock := newFakeClock()
+	clock.autoAdvance = true
+	inner := &scriptedNotifier{errs: []error{Permanent(errors.New("invalid phone number"))}}
+
+	err := NewRetryNotifier(inner, RetryPolicy{MaxAttempts: 5, Clock: clock}).Send("hello")
+
+	if !IsPermanent(err) {
+		t.Errorf("expected permanent error to surface, got %v", err)
+	}
+	if inner.Calls() != 1 {
+		t.Errorf("expected a single attempt, got %d", inner.Calls())
+	}
+	if len(clock.Sleeps()) != 0 {
+		t.Errorf("expected no backoff, got %v", clock.Sleeps())
+	}
+}
+
+func TestRetryNotifier_CustomRetryable(t *testing.T) {
+	clock := newFakeClock()
+	clock.autoAdvance = true
+	inner := &scriptedNotifier{errs: []error{errVendorDown}}
+
+	notifier := NewRetryNotifier(inner, RetryPolicy{
+		MaxAttempts: 5,
+		Clock:       clock,
+		Retryable:   func(err error) bool { return false },
+	})
+
+	if err := notifier.Send("hello"); err == nil {
+		t.Error("expected error when nothing is retryable, got nil")
+	}
+	if inner.Calls() != 1 {
+		t.Errorf("expected a single attempt, got %d", inner.Calls())
+	}
+}
+
+func TestPermanent_Nil(t *testing.T) {
+	if Permanent(nil) != nil {
+		t.Error("expected Permanent(nil) to be nil")
+	}
+}
+
+func TestRetryNotifier_CancelDuringBackoff(t *testing.T) {
+	clock := newFakeClock()
+	inner := &scriptedNotifier{errs: []error{errVendorDown, errVendorDown}}
+	notifier := NewRetryNotifier(inner, RetryPolicy{MaxAttempts: 5, Clock: clock, Rand: noJitter})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- notifier.SendContext(ctx, "hello") }()
+
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if inner.Calls() != 1 {
+		t.Errorf("expected no attempts after cancel, got %d calls", inner.Calls())
+	}
+}
+
+func TestRetryNotifier_CancelStopsInFlightAttempt(t *testing.T) {
+	inner := newBlockingNotifier()
+	notifier := NewRetryNotifier(inner, RetryPolicy{MaxAttempts: 5, Clock: newFakeClock()})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- notifier.SendContext(ctx, "hello") }()
+
+	<-inner.started
+	cancel()
+
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if err := <-inner.released; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected in-flight attempt to see cancellation, got %v", err)
+	}
+	if len(inner.started) != 0 {
+		t.Error("expected no further attempts after cancel")
+	}
+}
@@ -0,0 +1,461 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownRecipient is returned when a PreferenceStore has no entry
+	ErrUnknownRecipient = errors.New("routing: unknown recipient")
+	// ErrNoRoute is returned when a recipient has no opted-in channel the
+	// router can deliver to
+	ErrNoRoute = errors.New("routing: no usable channel")
+	// ErrRecipientRequired is returned by Router.Send and SendContext: a bare
+	// body names no recipient, so only SendMessage can route
+	ErrRecipientRequired = errors.New("routing: Send has no recipient ID, use SendMessage with To set")
+)
+
+// TimeOfDay is a wall-clock time written as "HH:MM"
+type TimeOfDay int // minutes after midnight
+
+// ParseTimeOfDay parses "HH:MM" in 24-hour notation
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	digit := func(i int) int { return int(s[i] - '0') }
+	valid := len(s) == 5 && s[2] == ':'
+	for _, i := range []int{0, 1, 3, 4} {
+		valid = valid && s[i] >= '0' && s[i] <= '9'
+	}
+	if !valid || digit(0)*10+digit(1) > 23 || digit(3) > 5 {
+		return 0, fmt.Errorf("time of day %q: want HH:MM", s)
+	}
+	return TimeOfDay((digit(0)*10+digit(1))*60 + digit(3)*10 + digit(4)), nil
+}
+
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", int(t)/60, int(t)%60)
+}
+
+// MarshalText writes the time as "HH:MM"
+func (t TimeOfDay) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText parses "HH:MM"
+func (t *TimeOfDay) UnmarshalText(text []byte) error {
+	parsed, err := ParseTimeOfDay(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// QuietHours is a daily window in the recipient's time zone. A window whose
+// End is before its Start wraps midnight, e.g. 22:00-07:00.
+type QuietHours struct {
+	Start TimeOfDay `json:"start"`
+	End   TimeOfDay `json:"end"`
+}
+
+// contains reports whether local falls inside the window
+func (q QuietHours) contains(local time.Time) bool {
+	now := TimeOfDay(local.Hour()*60 + local.Minute())
+	switch {
+	case q.Start == q.End:
+		return false
+	case q.Start < q.End:
+		return now >= q.Start && now < q.End
+	default:
+		return now >= q.Start || now < q.End
+	}
+}
+
+// endAfter returns the first end of the window after local
+func (q QuietHours) endAfter(local time.Time) time.Time {
+	y, m, d := local.Date()
+	end := time.Date(y, m, d, int(q.End)/60, int(q.End)%60, 0, 0, local.Location())
+	if !end.After(local) {
+		end = time.Date(y, m, d+1, int(q.End)/60, int(q.End)%60, 0, 0, local.Location())
+	}
+	return end
+}
+
+// Preferences are one recipient's delivery settings
+type Preferences struct {
+	// Channels are the opted-in channels, most preferred first
+	Channels []string `json:"channels"`
+	// Addresses holds the recipient's address on each channel: an email
+	// address, an E.164 number, device tokens
+	Addresses map[string][]string `json:"addresses"`
+	// TimeZone is an IANA name such as "Europe/Berlin", defaulting to UTC
+	TimeZone   string      `json:"time_zone,omitempty"`
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+	// DoNotDisturbUntil holds every non-critical message until it passes
+	DoNotDisturbUntil time.Time `json:"do_not_disturb_until,omitempty"`
+}
+
+// PreferenceStore looks up recipient preferences
+type PreferenceStore interface {
+	Preferences(recipient string) (Preferences, error)
+}
+
+// PreferenceMap is an in-memory PreferenceStore keyed by recipient ID
+type PreferenceMap map[string]Preferences
+
+// Preferences returns the recipient's entry or ErrUnknownRecipient
+func (m PreferenceMap) Preferences(recipient string) (Preferences, error) {
+	prefs, ok := m[recipient]
+	if !ok {
+		return Preferences{}, fmt.Errorf("%w %q", ErrUnknownRecipient, recipient)
+	}
+	return prefs, nil
+}
+
+// RouteMode decides how many of the usable channels a message goes to
+type RouteMode int
+
+const (
+	// RoutePreferred tries channels in preference order and stops at the
+	// first that succeeds
+	RoutePreferred RouteMode = iota
+	// RouteAll sends to every usable channel
+	RouteAll
+)
+
+// RoutingPolicy configures a Router
+type RoutingPolicy struct {
+	Mode RouteMode
+
+	// QuietChannels are held during quiet hours; other channels, such as
+	// email, are still used. Defaults to sms and push.
+	QuietChannels []string
+
+	Clock Clock // defaults to SystemClock
+}
+
+// RouteDecision is what the router did, or will do, with one message for
+// one recipient
+type RouteDecision struct {
+	Recipient string
+	// Channels are used now, in the order they are tried
+	Channels []string
+	// Deferred channels are held until DeferUntil by quiet hours or
+	// do-not-disturb. In RoutePreferred mode they are a fallback for
+	// Channels: Route drops them once a channel used now succeeds.
+	Deferred   []string
+	DeferUntil time.Time
+	Reason     string // why channels were deferred, empty otherwise
+
+	// Results are filled in by Route, one per channel attempted
+	Results []ChannelResult
+}
+
+// DeferredMessage is a message held by a Router until its time comes
+type DeferredMessage struct {
+	Recipient string
+	Channels  []string
+	Message   Message
+	Until     time.Time
+}
+
+// Router picks channels for each recipient from their preferences, holding
+// messages through quiet hours and do-not-disturb. Critical messages are
+// never held. A Router is safe for concurrent use.
+type Router struct {
+	prefs     PreferenceStore
+	notifiers map[string]Notifier
+	policy    RoutingPolicy
+	quiet     map[string]bool
+
+	mu       sync.Mutex
+	deferred []DeferredMessage
+}
+
+// NewRouter creates a Router delivering through one notifier per channel
+func NewRouter(prefs PreferenceStore, notifiers map[string]Notifier, policy RoutingPolicy) (*Router, error) {
+	if prefs == nil {
+		return nil, errors.New("routing: a preference store is required")
+	}
+	if len(notifiers) == 0 {
+		return nil, errors.New("routing: at least one channel notifier is required")
+	}
+	if policy.QuietChannels == nil {
+		policy.QuietChannels = []string{"sms", "push"}
+	}
+	if policy.Clock == nil {
+		policy.Clock = SystemClock
+	}
+	r := &Router{
+		prefs:     prefs,
+		notifiers: make(map[string]Notifier, len(notifiers)),
+		policy:    policy,
+		quiet:     make(map[string]bool, len(policy.QuietChannels)),
+	}
+	for channel, n := range notifiers {
+		if n == nil {
+			return nil, fmt.Errorf("routing: channel %s has no notifier", channel)
+		}
+		r.notifiers[channel] = n
+	}
+	for _, channel := range policy.QuietChannels {
+		r.quiet[channel] = true
+	}
+	return r, nil
+}
+
+// NewRouterFromFactory builds one notifier per channel with NotifierFactory.
+// vendors maps channel type to vendor.
+func NewRouterFromFactory(prefs PreferenceStore, vendors map[string]string, policy RoutingPolicy) (*Router, error) {
+	notifiers := make(map[string]Notifier, len(vendors))
+	for channel, vendor := range vendors {
+		n, err := NotifierFactory(channel, vendor)
+		if err != nil {
+			return nil, err
+		}
+		notifiers[channel] = n
+	}
+	return NewRouter(prefs, notifiers, policy)
+}
+
+// Plan decides the channels for msg without sending it
+func (r *Router) Plan(recipient string, msg Message) (RouteDecision, error) {
+	decision, _, err := r.plan(recipient, msg)
+	return decision, err
+}
+
+func (r *Router) plan(recipient string, msg Message) (RouteDecision, Preferences, error) {
+	prefs, err := r.prefs.Preferences(recipient)
+	if err != nil {
+		return RouteDecision{}, prefs, Permanent(err)
+	}
+	loc := time.UTC
+	if prefs.TimeZone != "" {
+		if loc, err = time.LoadLocation(prefs.TimeZone); err != nil {
+			return RouteDecision{}, prefs, Permanent(fmt.Errorf("routing: recipient %q: %w", recipient, err))
+		}
+	}
+
+	decision := RouteDecision{Recipient: recipient}
+	var usable []string
+	for _, channel := range prefs.Channels {
+		if _, ok := r.notifiers[channel]; ok && len(prefs.Addresses[channel]) > 0 {
+			usable = append(usable, channel)
+		}
+	}
+	if len(usable) == 0 {
+		return decision, prefs, Permanent(fmt.Errorf("%w for recipient %q", ErrNoRoute, recipient))
+	}
+	if msg.Priority >= PriorityCritical {
+		decision.Channels = usable
+		return decision, prefs, nil
+	}
+
+	now := r.policy.Clock.Now()
+	if now.Before(prefs.DoNotDisturbUntil) {
+		decision.Deferred, decision.DeferUntil = usable, prefs.DoNotDisturbUntil
+		decision.Reason = "do not disturb"
+		return decision, prefs, nil
+	}
+	local := now.In(loc)
+	if prefs.QuietHours == nil || !prefs.QuietHours.contains(local) {
+		decision.Channels = usable
+		return decision, prefs, nil
+	}
+
+	for _, channel := range usable {
+		if r.quiet[channel] {
+			decision.Deferred = append(decision.Deferred, channel)
+		} else {
+			decision.Channels = append(decision.Channels, channel)
+		}
+	}
+	if len(decision.Deferred) > 0 {
+		decision.DeferUntil = prefs.QuietHours.endAfter(local)
+		decision.Reason = "quiet hours"
+	}
+	return decision, prefs, nil
+}
+
+// Route plans msg for recipient, sends it on the channels usable now and
+// holds the rest. msg.To is replaced with the recipient's address on each
+// channel.
+func (r *Router) Route(ctx context.Context, recipient string, msg Message) (RouteDecision, error) {
+	decision, prefs, err := r.plan(recipient, msg)
+	if err != nil {
+		return decision, err
+	}
+	err = r.dispatch(ctx, &decision, prefs, msg)
+	return decision, err
+}
+
+// dispatch sends msg on the decision's channels and holds its deferred
+// ones. In RoutePreferred mode a channel that succeeds now, such as email
+// during quiet hours, makes the hold unnecessary; if every channel fails,
+// the held message is the fallback and the failures are only reported in
+// Results.
+func (r *Router) dispatch(ctx context.Context, decision *RouteDecision, prefs Preferences, msg Message) error {
+	var err error
+	decision.Results, err = r.send(ctx, prefs, decision.Channels, msg)
+	if r.policy.Mode == RoutePreferred && len(decision.Deferred) > 0 {
+		if delivered(decision.Results) {
+			decision.Deferred, decision.DeferUntil, decision.Reason = nil, time.Time{}, ""
+		} else if !isContextError(err) {
+			err = nil
+		}
+	}
+	r.hold(*decision, msg)
+	return err
+}
+
+// delivered reports whether any channel succeeded
+func delivered(results []ChannelResult) bool {
+	for _, result := range results {
+		if result.Err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// hold queues the decision's deferred channels for SendDue
+func (r *Router) hold(decision RouteDecision, msg Message) {
+	if len(decision.Deferred) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deferred = append(r.deferred, DeferredMessage{
+		Recipient: decision.Recipient,
+		Channels:  decision.Deferred,
+		Message:   msg,
+		Until:     decision.DeferUntil,
+	})
+}
+
+// send delivers msg on channels, stopping at the first success in
+// RoutePreferred mode
+func (r *Router) send(ctx context.Context, prefs Preferences, channels []string, msg Message) ([]ChannelResult, error) {
+	var results []ChannelResult
+	var errs []error
+	for _, channel := range channels {
+		n := r.notifiers[channel]
+		channelMsg := msg
+		channelMsg.To = prefs.Addresses[channel]
+
+		start := r.policy.Clock.Now()
+		err := SendMessage(ctx, n, channelMsg)
+		results = append(results, ChannelResult{
+			Channel:  channel,
+			Vendor:   vendorName(n),
+			Err:      err,
+			Duration: r.policy.Clock.Now().Sub(start),
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel, err))
+			if isContextError(err) {
+				break
+			}
+			continue
+		}
+		if r.policy.Mode == RoutePreferred {
+			return results, nil
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// Deferred returns the held messages, earliest first
+func (r *Router) Deferred() []DeferredMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deferred := append([]DeferredMessage(nil), r.deferred...)
+	sort.SliceStable(deferred, func(i, j int) bool { return deferred[i].Until.Before(deferred[j].Until) })
+	return deferred
+}
+
+// SendDue sends every held message whose time has come on the channels it
+// was held for, re-planning each one against the recipient's current
+// preferences. Messages that are still held, for example because
+// do-not-disturb was extended, stay queued.
+func (r *Router) SendDue(ctx context.Context) ([]RouteDecision, error) {
+	now := r.policy.Clock.Now()
+	r.mu.Lock()
+	var due []DeferredMessage
+	kept := r.deferred[:0]
+	for _, d := range r.deferred {
+		if !now.Before(d.Until) {
+			due = append(due, d)
+		} else {
+			kept = append(kept, d)
+		}
+	}
+	r.deferred = kept
+	r.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].Until.Before(due[j].Until) })
+	var decisions []RouteDecision
+	var errs []error
+	for _, d := range due {
+		decision, err := r.resume(ctx, d)
+		decisions = append(decisions, decision)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("recipient %q: %w", d.Recipient, err))
+		}
+	}
+	return decisions, errors.Join(errs...)
+}
+
+// resume re-plans a held message, restricted to the channels it was held for
+func (r *Router) resume(ctx context.Context, d DeferredMessage) (RouteDecision, error) {
+	decision, prefs, err := r.plan(d.Recipient, d.Message)
+	if err != nil {
+		return decision, err
+	}
+	held := make(map[string]bool, len(d.Channels))
+	for _, channel := range d.Channels {
+		held[channel] = true
+	}
+	keep := func(channels []string) []string {
+		var out []string
+		for _, channel := range channels {
+			if held[channel] {
+				out = append(out, channel)
+			}
+		}
+		return out
+	}
+	decision.Channels, decision.Deferred = keep(decision.Channels), keep(decision.Deferred)
+	err = r.dispatch(ctx, &decision, prefs, d.Message)
+	return decision, err
+}
+
+// Send cannot route: a bare body has no recipient ID. It returns
+// ErrRecipientRequired, which is permanent; use SendMessage.
+func (r *Router) Send(msg string) error {
+	return r.SendContext(context.Background(), msg)
+}
+
+// SendContext returns ErrRecipientRequired like Send
+func (r *Router) SendContext(ctx context.Context, msg string) error {
+	return fmt.Errorf("%w: %w", ErrRecipientRequired, ErrInvalidMessage)
+}
+
+// SendMessage routes msg to each recipient ID in msg.To. Held messages are
+// not errors; they go out on a later SendDue.
+func (r *Router) SendMessage(ctx context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return &ValidationError{Channel: "routing", Field: "To", Reason: "needs at least one recipient ID"}
+	}
+	var errs []error
+	for _, recipient := range msg.To {
+		if _, err := r.Route(ctx, recipient, msg); err != nil {
+			errs = append(errs, fmt.Errorf("recipient %q: %w", recipient, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,306 @@
+package factory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestRouter routes through recording email, sms and push notifiers.
+// The fake clock starts at 12:00 UTC, which is 21:00 in Tokyo.
+func newTestRouter(t *testing.T, mode RouteMode, prefs PreferenceMap) (*Router, *fakeClock, map[string]*messageRecorder) {
+	t.Helper()
+	clock := newFakeClock()
+	recorders := map[string]*messageRecorder{
+		"email": {scriptedNotifier: scriptedNotifier{vendor: "sendgrid"}},
+		"sms":   {scriptedNotifier: scriptedNotifier{vendor: "twilio"}},
+		"push":  {scriptedNotifier: scriptedNotifier{vendor: "fcm"}},
+	}
+	notifiers := make(map[string]Notifier, len(recorders))
+	for channel, recorder := range recorders {
+		notifiers[channel] = recorder
+	}
+	router, err := NewRouter(prefs, notifiers, RoutingPolicy{Mode: mode, Clock: clock})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return router, clock, recorders
+}
+
+func tokyoPrefs(channels ...string) Preferences {
+	return Preferences{
+		Channels: channels,
+		Addresses: map[string][]string{
+			"email": {"aiko@example.com"},
+			"sms":   {"+819012345678"},
+			"push":  {"device-1"},
+		},
+		TimeZone:   "Asia/Tokyo",
+		QuietHours: &QuietHours{Start: 21 * 60, End: 8 * 60},
+	}
+}
+
+// ============================================================================
+// ROUTING TESTS
+// ============================================================================
+
+func TestRouter_PreferredOrderWithFallback(t *testing.T) {
+	router, _, recorders := newTestRouter(t, RoutePreferred, PreferenceMap{
+		"u1": {Channels: []string{"sms", "email"}, Addresses: map[string][]string{"sms": {"+15551234567"}, "email": {"u1@example.com"}}},
+	})
+	recorders["sms"].errs = []error{errVendorDown}
+
+	decision, err := router.Route(context.Background(), "u1", Message{Body: "Your code is 1234"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(decision.Results) != 2 || decision.Results[0].Err == nil || decision.Results[1].Channel != "email" {
+		t.Errorf("expected sms to fail over to email, got %+v", decision.Results)
+	}
+	if got := recorders["email"].Messages(); len(got) != 1 || !reflect.DeepEqual(got[0].To, []string{"u1@example.com"}) {
+		t.Errorf("expected email to the recipient's address, got %+v", got)
+	}
+}
+
+func TestRouter_SkipsChannelsWithoutAddressOrNotifier(t *testing.T) {
+	router, _, recorders := newTestRouter(t, RouteAll, PreferenceMap{
+		"u1": {Channels: []string{"voice", "sms", "email"}, Addresses: map[string][]string{"voice": {"+15551234567"}, "email": {"u1@example.com"}}},
+	})
+
+	decision, err := router.Route(context.Background(), "u1", Message{Body: "hello"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decision.Channels, []string{"email"}) || recorders["sms"].Calls() != 0 {
+		t.Errorf("expected only email to be usable, got %v", decision.Channels)
+	}
+}
+
+func TestRouter_QuietHoursPrefersQuietSafeChannel(t *testing.T) {
+	router, _, recorders := newTestRouter(t, RoutePreferred, PreferenceMap{"aiko": tokyoPrefs("sms", "email")})
+
+	decision, err := router.Route(context.Background(), "aiko", Message{Body: "Weekly report"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decision.Channels, []string{"email"}) || decision.Deferred != nil {
+		t.Errorf("expected email now and nothing held, got %+v", decision)
+	}
+	if recorders["sms"].Calls() != 0 || len(recorders["email"].Messages()) != 1 {
+		t.Error("expected no SMS during quiet hours")
+	}
+}
+
+func TestRouter_QuietHoursHoldsWhenQuietSafeChannelFails(t *testing.T) {
+	router, clock, recorders := newTestRouter(t, RoutePreferred, PreferenceMap{"aiko": tokyoPrefs("sms", "email")})
+	recorders["email"].errs = []error{errVendorDown}
+
+	decision, err := router.Route(context.Background(), "aiko", Message{Body: "Weekly report"})
+	if err != nil {
+		t.Fatalf("expected the held message not to be an error, got %v", err)
+	}
+	if len(decision.Results) != 1 || !errors.Is(decision.Results[0].Err, errVendorDown) || !reflect.DeepEqual(decision.Deferred, []string{"sms"}) {
+		t.Fatalf("expected the email failure reported and sms held, got %+v", decision)
+	}
+
+	clock.Advance(11 * time.Hour) // 08:00 in Tokyo
+	if _, err := router.SendDue(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := recorders["sms"].Messages(); len(got) != 1 || got[0].Body != "Weekly report" {
+		t.Errorf("expected the held message sent by SMS in the morning, got %+v", got)
+	}
+}
+
+func TestRouter_QuietHoursDefersUntilMorning(t *testing.T) {
+	router, clock, recorders := newTestRouter(t, RoutePreferred, PreferenceMap{"aiko": tokyoPrefs("sms", "push")})
+
+	decision, err := router.Route(context.Background(), "aiko", Message{Body: "Your parcel arrives tomorrow"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	morning := time.Date(2024, 1, 2, 8, 0, 0, 0, mustLoadLocation(t, "Asia/Tokyo"))
+	if !decision.DeferUntil.Equal(morning) || decision.Reason != "quiet hours" || len(decision.Results) != 0 {
+		t.Fatalf("expected the message held until 08:00 Tokyo time, got %+v", decision)
+	}
+
+	clock.Advance(10 * time.Hour) // 07:00 in Tokyo
+	if decisions, _ := router.SendDue(context.Background()); len(decisions) != 0 {
+		t.Errorf("expected nothing due before quiet hours end, got %+v", decisions)
+	}
+	clock.Advance(time.Hour)
+	decisions, err := router.SendDue(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(decisions) != 1 || !reflect.DeepEqual(decisions[0].Channels, []string{"sms", "push"}) {
+		t.Fatalf("expected the held message to go out on its preferred channel, got %+v", decisions)
+	}
+	if got := recorders["sms"].Messages(); len(got) != 1 || got[0].Body != "Your parcel arrives tomorrow" {
+		t.Errorf("expected one SMS in the morning, got %+v", got)
+	}
+	if recorders["push"].Calls() != 0 || len(router.Deferred()) != 0 {
+		t.Error("expected the first successful channel to finish the message")
+	}
+}
+
+func TestRouter_RouteAllHoldsOnlyQuietChannels(t *testing.T) {
+	router, clock, recorders := newTestRouter(t, RouteAll, PreferenceMap{"aiko": tokyoPrefs("email", "push")})
+
+	decision, _ := router.Route(context.Background(), "aiko", Message{Body: "New login"})
+	if !reflect.DeepEqual(decision.Channels, []string{"email"}) || !reflect.DeepEqual(decision.Deferred, []string{"push"}) {
+		t.Fatalf("expected email now and push held, got %+v", decision)
+	}
+
+	clock.Advance(11 * time.Hour)
+	router.SendDue(context.Background())
+	if len(recorders["email"].Messages()) != 1 || len(recorders["push"].Messages()) != 1 {
+		t.Errorf("expected each channel exactly once, got email %d push %d",
+			len(recorders["email"].Messages()), len(recorders["push"].Messages()))
+	}
+}
+
+func TestRouter_CriticalBypassesQuietHoursAndDoNotDisturb(t *testing.T) {
+	prefs := tokyoPrefs("sms")
+	prefs.DoNotDisturbUntil = time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+	router, _, recorders := newTestRouter(t, RoutePreferred, PreferenceMap{"aiko": prefs})
+
+	decision, err := router.Route(context.Background(), "aiko", Message{Body: "Password reset code 4821", Priority: PriorityCritical})
+	if err != nil || !reflect.DeepEqual(decision.Channels, []string{"sms"}) {
+		t.Fatalf("expected a critical SMS to go out immediately, got %+v (%v)", decision, err)
+	}
+	if recorders["sms"].Calls() != 1 {
+		t.Errorf("expected 1 SMS, got %d", recorders["sms"].Calls())
+	}
+}
+
+func TestRouter_DoNotDisturbHoldsEverything(t *testing.T) {
+	until := time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC)
+	router, clock, recorders := newTestRouter(t, RoutePreferred, PreferenceMap{
+		"u1": {Channels: []string{"email"}, Addresses: map[string][]string{"email": {"u1@example.com"}}, DoNotDisturbUntil: until},
+	})
+
+	if err := router.SendMessage(context.Background(), Message{To: []string{"u1"}, Body: "Newsletter"}); err != nil {
+		t.Fatalf("expected a held message not to be an error, got %v", err)
+	}
+	held := router.Deferred()
+	if len(held) != 1 || !held[0].Until.Equal(until) || recorders["email"].Calls() != 0 {
+		t.Fatalf("expected the email held until do-not-disturb ends, got %+v", held)
+	}
+	clock.Advance(6 * time.Hour)
+	router.SendDue(context.Background())
+	if recorders["email"].Calls() != 1 {
+		t.Errorf("expected the email after do-not-disturb, got %d sends", recorders["email"].Calls())
+	}
+}
+
+func TestQuietHours_Window(t *testing.T) {
+	overnight := QuietHours{Start: 22 * 60, End: 7 * 60}
+	daytime := QuietHours{Start: 9 * 60, End: 17 * 60}
+	at := func(hour, minute int) time.Time { return time.Date(2024, 1, 1, hour, minute, 0, 0, time.UTC) }
+
+	testCases := []struct {
+		name    string
+		window  QuietHours
+		now     time.Time
+		quiet   bool
+		endsDay int
+	}{
+		{"before overnight window", overnight, at(21, 59), false, 0},
+		{"start of overnight window", overnight, at(22, 0), true, 2},
+		{"after midnight", overnight, at(3, 0), true, 1},
+		{"end is exclusive", overnight, at(7, 0), false, 0},
+		{"inside daytime window", daytime, at(12, 0), true, 1},
+		{"after daytime window", daytime, at(17, 30), false, 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.window.contains(tc.now); got != tc.quiet {
+				t.Errorf("expected quiet=%v, got %v", tc.quiet, got)
+			}
+			if tc.quiet {
+				if end := tc.window.endAfter(tc.now); end.Day() != tc.endsDay || TimeOfDay(end.Hour()*60) != tc.window.End {
+					t.Errorf("expected the window to end on day %d at %s, got %v", tc.endsDay, tc.window.End, end)
+				}
+			}
+		})
+	}
+}
+
+func TestPreferences_JSON(t *testing.T) {
+	var prefs Preferences
+	data := `{"channels":["push","email"],"addresses":{"push":["tok"]},"time_zone":"Europe/Berlin","quiet_hours":{"start":"22:30","end":"06:45"}}`
+	if err := json.Unmarshal([]byte(data), &prefs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if prefs.QuietHours == nil || prefs.QuietHours.Start != 22*60+30 || prefs.QuietHours.End.String() != "06:45" {
+		t.Errorf("expected quiet hours 22:30-06:45, got %+v", prefs.QuietHours)
+	}
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestRouter_UnknownRecipientAndNoRoute(t *testing.T) {
+	router, _, _ := newTestRouter(t, RoutePreferred, PreferenceMap{
+		"opted-out": {Addresses: map[string][]string{"email": {"x@example.com"}}},
+	})
+
+	if _, err := router.Route(context.Background(), "nobody", Message{Body: "hi"}); !errors.Is(err, ErrUnknownRecipient) || !IsPermanent(err) {
+		t.Errorf("expected a permanent ErrUnknownRecipient, got %v", err)
+	}
+	if _, err := router.Route(context.Background(), "opted-out", Message{Body: "hi"}); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("expected ErrNoRoute for a recipient with no opted-in channel, got %v", err)
+	}
+	if err := router.Send("no recipient"); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("expected a message without recipients to be rejected, got %v", err)
+	}
+}
+
+func TestRouter_SendNeedsSendMessage(t *testing.T) {
+	router, _, recorders := newTestRouter(t, RoutePreferred, PreferenceMap{"u1": tokyoPrefs("email")})
+
+	for _, err := range []error{router.Send("u1"), router.SendContext(context.Background(), "u1")} {
+		if !errors.Is(err, ErrRecipientRequired) || !IsPermanent(err) {
+			t.Errorf("expected a permanent ErrRecipientRequired, got %v", err)
+		}
+	}
+	if len(recorders["email"].Messages()) != 0 {
+		t.Error("expected nothing sent")
+	}
+	if err := router.SendMessage(context.Background(), Message{To: []string{"u1"}, Body: "hi"}); err != nil {
+		t.Errorf("expected SendMessage to route, got %v", err)
+	}
+}
+
+func TestParseTimeOfDay_Invalid(t *testing.T) {
+	for _, input := range []string{"", "7:00", "24:00", "12:60", "ab:cd", "12-30"} {
+		if _, err := ParseTimeOfDay(input); err == nil {
+			t.Errorf("expected %q to be rejected", input)
+		}
+	}
+}
+
+func TestRouter_AllChannelsFail(t *testing.T) {
+	router, _, recorders := newTestRouter(t, RoutePreferred, PreferenceMap{
+		"u1": {Channels: []string{"sms", "email"}, Addresses: map[string][]string{"sms": {"+15551234567"}, "email": {"u1@example.com"}}},
+	})
+	recorders["sms"].errs = []error{errVendorDown}
+	recorders["email"].errs = []error{errVendorDown}
+
+	decision, err := router.Route(context.Background(), "u1", Message{Body: "hello"})
+	if !errors.Is(err, errVendorDown) || len(decision.Results) != 2 {
+		t.Errorf("expected both channel errors, got %v with %d results", err, len(decision.Results))
+	}
+}
@@ -0,0 +1,237 @@
+package factory
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"unicode/utf16"
+)
+
+// SMSEncoding is the character encoding of an SMS
+type SMSEncoding int
+
+const (
+	// GSM7 is the GSM 03.38 7-bit default alphabet: 160 characters per SMS
+	GSM7 SMSEncoding = iota
+	// UCS2 is 16-bit Unicode: 70 characters per SMS
+	UCS2
+)
+
+func (e SMSEncoding) String() string {
+	if e == UCS2 {
+		return "UCS-2"
+	}
+	return "GSM-7"
+}
+
+// Limits per SMS, in septets for GSM-7 and UTF-16 code units for UCS-2.
+// Concatenated segments lose room to the 6-byte UDH.
+const (
+	gsm7SingleLimit = 160
+	gsm7SegmentSize = 153
+	ucs2SingleLimit = 70
+	ucs2SegmentSize = 67
+
+	maxSMSSegments = 255
+)
+
+// gsm7Basic is the GSM 03.38 default alphabet, minus the escape at 0x1B
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension characters are sent as escape + character, two septets each
+const gsm7Extension = "\f^{}\\[~]|€"
+
+// gsm7Septets returns how many septets r takes in GSM-7, or 0 if it cannot
+// be encoded
+func gsm7Septets(r rune) int {
+	switch {
+	case strings.ContainsRune(gsm7Basic, r):
+		return 1
+	case strings.ContainsRune(gsm7Extension, r):
+		return 2
+	}
+	return 0
+}
+
+// smsTransliterations maps common characters outside GSM-7 to the closest
+// GSM-7 text
+var smsTransliterations = map[rune]string{
+	'‘': "'", '’': "'", '‚': "'", '‛': "'", '′': "'",
+	'“': "\"", '”': "\"", '„': "\"", '″': "\"", '«': "\"", '»': "\"",
+	'–': "-", '—': "-", '‐': "-", '‑': "-", '−': "-",
+	'…': "...", '•': "*", '·': ".",
+	'\u00a0': " ", '\u2009': " ", '\u200b': "", '\t': " ",
+	'á': "a", 'â': "a", 'ã': "a", 'ą': "a", 'ā': "a",
+	'Á': "A", 'À': "A", 'Â': "A", 'Ã': "A", 'Ą': "A",
+	'ç': "Ç", 'ć': "c", 'č': "c", 'Ć': "C", 'Č': "C",
+	'ê': "e", 'ë': "e", 'ę': "e", 'ě': "e", 'ē': "e",
+	'È': "E", 'Ê': "E", 'Ë': "E", 'Ę': "E", 'Ě': "E",
+	'í': "i", 'î': "i", 'ï': "i", 'Í': "I", 'Ì': "I", 'Î': "I", 'Ï': "I",
+	'ł': "l", 'Ł': "L", 'ń': "n", 'ň': "n", 'Ń': "N",
+	'ó': "o", 'ô': "o", 'õ': "o", 'ő': "o", 'Ó': "O", 'Ò': "O", 'Ô': "O", 'Õ': "O", 'Ő': "O",
+	'œ': "oe", 'Œ': "OE", 'ř': "r", 'Ř': "R",
+	'ś': "s", 'š': "s", 'ş': "s", 'Ś': "S", 'Š': "S", 'Ş': "S",
+	'ť': "t", 'Ť': "T",
+	'ú': "u", 'û': "u", 'ű': "u", 'ů': "u", 'Ú': "U", 'Ù': "U", 'Û': "U", 'Ű': "U", 'Ů': "U",
+	'ý': "y", 'ÿ': "y", 'Ý': "Y",
+	'ź': "z", 'ż': "z", 'ž': "z", 'Ź': "Z", 'Ż': "Z", 'Ž': "Z",
+}
+
+// Transliterate replaces characters outside GSM-7 with their closest
+// GSM-7 spelling, or '?' when there is none. It also returns the
+// characters that had no GSM-7 spelling.
+func Transliterate(text string) (string, []rune) {
+	var b strings.Builder
+	var lost []rune
+	for _, r := range text {
+		switch replacement, ok := smsTransliterations[r]; {
+		case gsm7Septets(r) > 0:
+			b.WriteRune(r)
+		case ok:
+			b.WriteString(replacement)
+		default:
+			b.WriteByte('?')
+			lost = append(lost, r)
+		}
+	}
+	return b.String(), lost
+}
+
+// SMSUnicodePolicy decides what happens to text outside GSM-7
+type SMSUnicodePolicy int
+
+const (
+	// SMSAllowUnicode sends the message as UCS-2, at 70 characters per SMS
+	SMSAllowUnicode SMSUnicodePolicy = iota
+	// SMSTransliterate rewrites the text into GSM-7 with Transliterate
+	SMSTransliterate
+	// SMSRejectUnicode fails with a *ValidationError
+	SMSRejectUnicode
+)
+
+// SMSSegment is one SMS of a possibly concatenated message
+type SMSSegment struct {
+	// UDH is the user data header for concatenated messages:
+	// 05 00 03 <reference> <total> <sequence>. It is nil for a single SMS.
+	UDH   []byte
+	Text  string
+	Units int // septets for GSM-7, UTF-16 code units for UCS-2
+}
+
+// SMSPlan describes how a body will be sent, before it is sent
+type SMSPlan struct {
+	Encoding SMSEncoding
+	Text     string // body as it will be sent, after transliteration
+	Units    int    // septets for GSM-7, UTF-16 code units for UCS-2
+	Segments []SMSSegment
+
+	// Cost is segments x recipients x the notifier's PricePerSegment
+	Cost float64
+
+	// Lost lists characters transliteration could only replace with '?'
+	Lost []rune
+}
+
+// SplitSMS picks the encoding for text and splits it into segments, using
+// ref as the concatenation reference. Escape sequences and surrogate pairs
+// are never split across segments.
+func SplitSMS(text string, ref byte) SMSPlan {
+	plan := SMSPlan{Encoding: GSM7, Text: text}
+	for _, r := range text {
+		if gsm7Septets(r) == 0 {
+			plan.Encoding = UCS2
+			break
+		}
+	}
+
+	units := func(r rune) int {
+		if plan.Encoding == UCS2 {
+			return len(utf16.Encode([]rune{r}))
+		}
+		return gsm7Septets(r)
+	}
+	single, segmentSize := gsm7SingleLimit, gsm7SegmentSize
+	if plan.Encoding == UCS2 {
+		single, segmentSize = ucs2SingleLimit, ucs2SegmentSize
+	}
+
+	for _, r := range text {
+		plan.Units += units(r)
+	}
+	if plan.Units <= single {
+		plan.Segments = []SMSSegment{{Text: text, Units: plan.Units}}
+		return plan
+	}
+
+	var current strings.Builder
+	currentUnits := 0
+	for _, r := range text {
+		n := units(r)
+		if currentUnits+n > segmentSize {
+			plan.Segments = append(plan.Segments, SMSSegment{Text: current.String(), Units: currentUnits})
+			current.Reset()
+			currentUnits = 0
+		}
+		current.WriteRune(r)
+		currentUnits += n
+	}
+	plan.Segments = append(plan.Segments, SMSSegment{Text: current.String(), Units: currentUnits})
+
+	total := byte(len(plan.Segments))
+	for i := range plan.Segments {
+		plan.Segments[i].UDH = []byte{0x05, 0x00, 0x03, ref, total, byte(i + 1)}
+	}
+	return plan
+}
+
+// smsReference numbers concatenated messages so handsets can reassemble them
+var smsReference atomic.Uint32
+
+// Plan reports the encoding, segments and cost of sending msg, applying the
+// notifier's Unicode policy and segment limit. SendMessage sends exactly
+// this plan.
+func (e *SmsNotifier) Plan(msg Message) (SMSPlan, error) {
+	if err := msg.Validate("sms"); err != nil {
+		return SMSPlan{}, err
+	}
+
+	text := msg.Body
+	var lost []rune
+	switch e.Unicode {
+	case SMSTransliterate:
+		text, lost = Transliterate(text)
+	case SMSRejectUnicode:
+		for i, r := range []rune(text) {
+			if gsm7Septets(r) == 0 {
+				return SMSPlan{}, &ValidationError{
+					Channel: "sms",
+					Field:   fmt.Sprintf("Body[%d]", i),
+					Value:   string(r),
+					Reason:  "is not in the GSM-7 alphabet",
+				}
+			}
+		}
+	}
+
+	plan := SplitSMS(text, byte(smsReference.Add(1)))
+	plan.Lost = lost
+	// The UDH counts segments in one byte
+	limit := e.MaxSegments
+	if limit <= 0 || limit > maxSMSSegments {
+		limit = maxSMSSegments
+	}
+	if len(plan.Segments) > limit {
+		return SMSPlan{}, &ValidationError{
+			Channel: "sms",
+			Field:   "Body",
+			Reason:  fmt.Sprintf("needs %d %s segments, limit is %d", len(plan.Segments), plan.Encoding, limit),
+		}
+	}
+	recipients := len(msg.To)
+	if recipients == 0 {
+		recipients = 1
+	}
+	plan.Cost = float64(len(plan.Segments)*recipients) * e.PricePerSegment
+	return plan, nil
+}
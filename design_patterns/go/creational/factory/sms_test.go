@@ -0,0 +1,160 @@
+package factory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// ============================================================================
+// SMS ENCODING TESTS
+// ============================================================================
+
+func TestSplitSMS_Encodings(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		encoding SMSEncoding
+		units    int
+		segments int
+	}{
+		{"plain gsm", "Your code is 1234", GSM7, 17, 1},
+		{"gsm accents", "Café à Zürich ÄÖÑÜ", GSM7, 18, 1},
+		{"extension chars count twice", "€10 [ok]", GSM7, 11, 1},
+		{"exactly one sms", strings.Repeat("a", 160), GSM7, 160, 1},
+		{"one over", strings.Repeat("a", 161), GSM7, 161, 2},
+		{"cyrillic", "Привет", UCS2, 6, 1},
+		{"emoji is a surrogate pair", "hi 👋", UCS2, 5, 1},
+		{"ucs2 limit", strings.Repeat("ж", 70), UCS2, 70, 1},
+		{"ucs2 over", strings.Repeat("ж", 71), UCS2, 71, 2},
+		{"three gsm segments", strings.Repeat("a", 153*2+1), GSM7, 307, 3},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			plan := SplitSMS(tc.text, 7)
+			if plan.Encoding != tc.encoding || plan.Units != tc.units || len(plan.Segments) != tc.segments {
+				t.Errorf("expected %s/%d units/%d segments, got %s/%d units/%d segments",
+					tc.encoding, tc.units, tc.segments, plan.Encoding, plan.Units, len(plan.Segments))
+			}
+			var joined strings.Builder
+			for _, segment := range plan.Segments {
+				joined.WriteString(segment.Text)
+			}
+			if joined.String() != tc.text {
+				t.Errorf("expected segments to reassemble the text")
+			}
+		})
+	}
+}
+
+func TestSplitSMS_UDH(t *testing.T) {
+	plan := SplitSMS(strings.Repeat("x", 200), 42)
+
+	if len(plan.Segments) != 2 || plan.Segments[0].Units != 153 || plan.Segments[1].Units != 47 {
+		t.Fatalf("expected 153+47 septets, got %+v", plan.Segments)
+	}
+	for i, segment := range plan.Segments {
+		want := []byte{0x05, 0x00, 0x03, 42, 2, byte(i + 1)}
+		if !bytes.Equal(segment.UDH, want) {
+			t.Errorf("segment %d: expected UDH % x, got % x", i+1, want, segment.UDH)
+		}
+	}
+	if single := SplitSMS("short", 42); single.Segments[0].UDH != nil {
+		t.Errorf("expected no UDH for a single SMS, got % x", single.Segments[0].UDH)
+	}
+}
+
+func TestSplitSMS_KeepsEscapesAndSurrogatesWhole(t *testing.T) {
+	// 152 septets then a two-septet '€' that must move to the next segment
+	gsm := SplitSMS(strings.Repeat("a", 152)+"€"+strings.Repeat("b", 10), 1)
+	if gsm.Segments[0].Units != 152 || !strings.HasPrefix(gsm.Segments[1].Text, "€") {
+		t.Errorf("expected the escape sequence to start segment 2, got %+v", gsm.Segments)
+	}
+
+	// 66 units then an emoji needing two
+	ucs := SplitSMS(strings.Repeat("ж", 66)+"👋"+strings.Repeat("ж", 5), 1)
+	if ucs.Segments[0].Units != 66 || !strings.HasPrefix(ucs.Segments[1].Text, "👋") {
+		t.Errorf("expected the surrogate pair to start segment 2, got %+v", ucs.Segments)
+	}
+	for _, segment := range ucs.Segments {
+		if got := len(utf16.Encode([]rune(segment.Text))); got != segment.Units {
+			t.Errorf("expected %d UTF-16 units, got %d", segment.Units, got)
+		}
+	}
+}
+
+func TestTransliterate(t *testing.T) {
+	text, lost := Transliterate("“Smart” quotes – São Paulo… Łódź ✓")
+	if text != `"Smart" quotes - Sao Paulo... Lodz ?` {
+		t.Errorf("unexpected transliteration: %q", text)
+	}
+	if !reflect.DeepEqual(lost, []rune{'✓'}) {
+		t.Errorf("expected ✓ to be reported lost, got %q", lost)
+	}
+}
+
+func TestSmsNotifier_PlanAppliesPolicy(t *testing.T) {
+	body := "Olá! Your São Paulo order ships today — track it in the app."
+	msg := Message{To: []string{"+5511912345678", "+5511987654321"}, Body: body}
+
+	unicode, err := (&SmsNotifier{Vendor: "twilio", PricePerSegment: 0.05}).Plan(msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if unicode.Encoding != UCS2 || unicode.Text != body {
+		t.Errorf("expected UCS-2 by default, got %s %q", unicode.Encoding, unicode.Text)
+	}
+
+	transliterated, err := (&SmsNotifier{Vendor: "twilio", Unicode: SMSTransliterate, PricePerSegment: 0.05}).Plan(msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if transliterated.Encoding != GSM7 || !strings.Contains(transliterated.Text, "Ola! Your Sao Paulo order ships today - track") {
+		t.Errorf("expected GSM-7 transliteration, got %s %q", transliterated.Encoding, transliterated.Text)
+	}
+	if len(transliterated.Segments) != 1 || transliterated.Cost != 0.10 {
+		t.Errorf("expected 1 segment costing 0.10 for two recipients, got %d segments costing %v",
+			len(transliterated.Segments), transliterated.Cost)
+	}
+}
+
+func TestSmsNotifier_SendsSegments(t *testing.T) {
+	notifier := &SmsNotifier{Vendor: "twilio"}
+	if err := notifier.SendMessage(context.Background(), Message{Body: strings.Repeat("long text ", 40)}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestSmsNotifier_RejectUnicode(t *testing.T) {
+	notifier := &SmsNotifier{Vendor: "twilio", Unicode: SMSRejectUnicode}
+
+	err := notifier.Send("Code 1234 ✓")
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "Body[10]" || validationErr.Value != "✓" {
+		t.Fatalf("expected Body[10] '✓' to be rejected, got %v", err)
+	}
+	if !IsPermanent(err) {
+		t.Error("expected an encoding rejection to be permanent")
+	}
+}
+
+func TestSmsNotifier_MaxSegments(t *testing.T) {
+	notifier := &SmsNotifier{Vendor: "twilio", MaxSegments: 2}
+
+	if err := notifier.Send(strings.Repeat("ж", 140)); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("expected a 3-segment UCS-2 body to be rejected, got %v", err)
+	}
+	if err := notifier.Send(strings.Repeat("a", 300)); err != nil {
+		t.Errorf("expected a 2-segment GSM-7 body to be accepted, got %v", err)
+	}
+	unlimited := &SmsNotifier{Vendor: "twilio"}
+	if _, err := unlimited.Plan(Message{Body: strings.Repeat("a", 153*255+1)}); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("expected more than 255 segments to be rejected, got %v", err)
+	}
+}
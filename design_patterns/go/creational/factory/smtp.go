@@ -0,0 +1,346 @@
+package factory
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoRecipients is returned when an email has nobody to deliver to
+	ErrNoRecipients = errors.New("smtp: no recipients")
+	// ErrStartTLSUnsupported is returned when STARTTLS is required but not offered
+	ErrStartTLSUnsupported = errors.New("smtp: server does not support STARTTLS")
+	// ErrAuthUnsupported is returned when credentials are set but AUTH is not offered
+	ErrAuthUnsupported = errors.New("smtp: server does not support AUTH")
+)
+
+// SMTPConfig describes how EmailNotifier talks to an SMTP server
+type SMTPConfig struct {
+	Host      string // host:port of the SMTP server
+	LocalName string // name sent with EHLO, defaults to "localhost"
+
+	Username string // AUTH PLAIN is used when set
+	Password string
+
+	From    string   // envelope sender and From header
+	To      []string // recipients
+	Subject string
+
+	StartTLS  bool        // require STARTTLS before AUTH and MAIL
+	TLSConfig *tls.Config // used for STARTTLS, ServerName defaults to Host
+
+	Timeout time.Duration // dial timeout and overall deadline, defaults to 30s
+}
+
+// SMTPError is a non-success reply from the SMTP server
+type SMTPError struct {
+	Command string // command that was rejected, e.g. "RCPT TO"
+	Code    int    // SMTP reply code
+	Message string
+}
+
+func (e *SMTPError) Error() string {
+	return fmt.Sprintf("smtp: %s rejected with %d: %s", e.Command, e.Code, e.Message)
+}
+
+// Temporary reports whether the server asked us to try again later (4xx)
+func (e *SMTPError) Temporary() bool {
+	return e.Code >= 400 && e.Code < 500
+}
+
+// NewSMTPEmailNotifier creates an EmailNotifier that delivers over SMTP
+func NewSMTPEmailNotifier(vendor string, config SMTPConfig) *EmailNotifier {
+	return &EmailNotifier{
+		Vendor: vendor,
+		SMTP:   &config,
+	}
+}
+
+// sendSMTP runs a full SMTP transaction for one message.
+// Cancelling ctx closes the connection, which aborts any pending command.
+func (c *SMTPConfig) sendSMTP(ctx context.Context, msg Message) error {
+	// Recipients on the message replace the configured ones
+	if len(msg.To) == 0 && len(msg.CC) == 0 {
+		msg.To = c.To
+	}
+	if msg.Subject == "" {
+		msg.Subject = c.Subject
+	}
+
+	if len(msg.To) == 0 && len(msg.CC) == 0 {
+		return ErrNoRecipients
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("smtp: invalid from address %q: %w", c.From, err)
+	}
+	for _, to := range msg.Recipients() {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("smtp: invalid recipient %q: %w", to, err)
+		}
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	host, _, err := net.SplitHostPort(c.Host)
+	if err != nil {
+		return fmt.Errorf("smtp: invalid host %q: %w", c.Host, err)
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.Host)
+	if err != nil {
+		return fmt.Errorf("smtp: dial %s: %w", c.Host, err)
+	}
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return c.contextError(ctx, mapSMTPError("CONNECT", err))
+	}
+	defer client.Close()
+
+	if err := c.transact(client, host, msg); err != nil {
+		return c.contextError(ctx, err)
+	}
+	return nil
+}
+
+// transact runs the SMTP commands after the greeting
+func (c *SMTPConfig) transact(client *smtp.Client, host string, msg Message) error {
+	localName := c.LocalName
+	if localName == "" {
+		localName = "localhost"
+	}
+	if err := client.Hello(localName); err != nil {
+		return mapSMTPError("EHLO", err)
+	}
+
+	if c.StartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return ErrStartTLSUnsupported
+		}
+		tlsConfig := &tls.Config{ServerName: host}
+		if c.TLSConfig != nil {
+			tlsConfig = c.TLSConfig.Clone()
+			if tlsConfig.ServerName == "" {
+				tlsConfig.ServerName = host
+			}
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return mapSMTPError("STARTTLS", err)
+		}
+	}
+
+	if c.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return ErrAuthUnsupported
+		}
+		auth := smtp.PlainAuth("", c.Username, c.Password, host)
+		if err := client.Auth(auth); err != nil {
+			return mapSMTPError("AUTH", err)
+		}
+	}
+
+	if err := client.Mail(envelopeAddress(c.From)); err != nil {
+		return mapSMTPError("MAIL FROM", err)
+	}
+	for _, to := range msg.Recipients() {
+		if err := client.Rcpt(envelopeAddress(to)); err != nil {
+			return mapSMTPError("RCPT TO", err)
+		}
+	}
+
+	// The DATA writer dot-stuffs lines starting with "." and normalises
+	// line endings to CRLF
+	w, err := client.Data()
+	if err != nil {
+		return mapSMTPError("DATA", err)
+	}
+	if _, err := w.Write(c.buildMessage(host, msg)); err != nil {
+		w.Close()
+		return fmt.Errorf("smtp: write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return mapSMTPError("DATA", err)
+	}
+
+	if err := client.Quit(); err != nil {
+		return mapSMTPError("QUIT", err)
+	}
+	return nil
+}
+
+// buildMessage renders RFC 5322 headers followed by the body. A plain
+// message is sent as text/plain; an HTML body adds multipart/alternative
+// and attachments wrap everything in multipart/mixed.
+func (c *SMTPConfig) buildMessage(host string, msg Message) []byte {
+	var buf bytes.Buffer
+
+	writeHeader := func(name, value string) {
+		buf.WriteString(name + ": " + value + "\r\n")
+	}
+	writeHeader("From", c.From)
+	if len(msg.To) > 0 {
+		writeHeader("To", strings.Join(msg.To, ", "))
+	}
+	if len(msg.CC) > 0 {
+		writeHeader("Cc", strings.Join(msg.CC, ", "))
+	}
+	if msg.Subject != "" {
+		writeHeader("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	}
+	writeHeader("Date", time.Now().Format(time.RFC1123Z))
+	writeHeader("Message-ID", newMessageID(host))
+	switch msg.Priority {
+	case PriorityCritical:
+		writeHeader("X-Priority", "1 (Highest)")
+	case PriorityHigh:
+		writeHeader("X-Priority", "2 (High)")
+	case PriorityBulk:
+		writeHeader("Precedence", "bulk")
+	}
+	writeHeader("MIME-Version", "1.0")
+
+	if msg.HTMLBody == "" && len(msg.Attachments) == 0 {
+		writeHeader("Content-Type", "text/plain; charset=utf-8")
+		writeHeader("Content-Transfer-Encoding", "8bit")
+		buf.WriteString("\r\n")
+		buf.WriteString(msg.Body)
+		if !strings.HasSuffix(msg.Body, "\n") {
+			buf.WriteString("\r\n")
+		}
+		return buf.Bytes()
+	}
+
+	if len(msg.Attachments) == 0 {
+		writeAlternative(&buf, writeHeader, msg)
+		return buf.Bytes()
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	writeHeader("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mixed.Boundary()}))
+	buf.WriteString("\r\n")
+
+	if msg.HTMLBody != "" {
+		var inner bytes.Buffer
+		innerHeader := textproto.MIMEHeader{}
+		writeAlternative(&inner, func(name, value string) { innerHeader.Set(name, value) }, msg)
+		part, _ := mixed.CreatePart(innerHeader)
+		part.Write(inner.Bytes())
+	} else {
+		writeTextPart(mixed, "text/plain", msg.Body)
+	}
+
+	for _, a := range msg.Attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Transfer-Encoding", "base64")
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+		part, _ := mixed.CreatePart(header)
+		writeBase64Lines(part, a.Data)
+	}
+	mixed.Close()
+	return buf.Bytes()
+}
+
+// writeAlternative writes a multipart/alternative entity with the text and
+// HTML bodies. Its Content-Type goes through writeHeader so it can be used
+// both at the top level and nested in multipart/mixed.
+func writeAlternative(buf *bytes.Buffer, writeHeader func(name, value string), msg Message) {
+	var body bytes.Buffer
+	alternative := multipart.NewWriter(&body)
+	writeHeader("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": alternative.Boundary()}))
+	if msg.Body != "" {
+		writeTextPart(alternative, "text/plain", msg.Body)
+	}
+	writeTextPart(alternative, "text/html", msg.HTMLBody)
+	alternative.Close()
+
+	if buf.Len() > 0 {
+		buf.WriteString("\r\n")
+	}
+	buf.Write(body.Bytes())
+}
+
+// writeTextPart adds a quoted-printable UTF-8 text part
+func writeTextPart(w *multipart.Writer, mediaType, text string) {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mediaType+"; charset=utf-8")
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	part, _ := w.CreatePart(header)
+	qp := quotedprintable.NewWriter(part)
+	qp.Write([]byte(text))
+	qp.Close()
+}
+
+// writeBase64Lines encodes data as base64 wrapped at 76 characters per line
+func writeBase64Lines(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		io.WriteString(w, encoded[:76]+"\r\n")
+		encoded = encoded[76:]
+	}
+	io.WriteString(w, encoded+"\r\n")
+}
+
+// contextError prefers ctx's error when the session was torn down by cancellation
+func (c *SMTPConfig) contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("smtp: %w", ctxErr)
+	}
+	return err
+}
+
+// envelopeAddress strips display names for MAIL FROM / RCPT TO
+func envelopeAddress(address string) string {
+	if parsed, err := mail.ParseAddress(address); err == nil {
+		return parsed.Address
+	}
+	return address
+}
+
+func newMessageID(host string) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), host)
+}
+
+// mapSMTPError turns protocol replies into *SMTPError, leaving other errors wrapped
+func mapSMTPError(command string, err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return &SMTPError{Command: command, Code: protoErr.Code, Message: protoErr.Msg}
+	}
+	return fmt.Errorf("smtp: %s: %w", command, err)
+}
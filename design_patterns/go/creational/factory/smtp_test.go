@@ -0,0 +1,442 @@
+package factory
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ============================================================================
+// FAKE SMTP SERVER - speaks just enough SMTP for EmailNotifier
+// ============================================================================
+
+type fakeSMTPServer struct {
+	ln net.Listener
+
+	tlsConfig  *tls.Config       // advertise STARTTLS when set
+	username   string            // advertise AUTH PLAIN when set
+	password   string            //
+	rejectRcpt map[string]int    // recipient -> reply code
+	replies    map[string]string // command -> canned reply
+
+	mu       sync.Mutex
+	commands []string
+	rawData  string // DATA as sent on the wire (dot-stuffed)
+	data     string // DATA after removing dot-stuffing
+	usedTLS  bool
+	authUser string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeSMTPServer{ln: ln, rejectRcpt: map[string]int{}, replies: map[string]string{}}
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeSMTPServer) start() {
+	go func() {
+		for {
+			conn, err := s.ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+}
+
+func (s *fakeSMTPServer) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake.test ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		canned, hasCanned := s.replies[verb]
+		s.mu.Unlock()
+		if hasCanned {
+			reply(canned)
+			continue
+		}
+
+		switch verb {
+		case "EHLO":
+			s.mu.Lock()
+			lines := []string{"fake.test"}
+			if s.tlsConfig != nil && !s.usedTLS {
+				lines = append(lines, "STARTTLS")
+			}
+			if s.username != "" {
+				lines = append(lines, "AUTH PLAIN")
+			}
+			lines = append(lines, "8BITMIME")
+			s.mu.Unlock()
+			for i, l := range lines {
+				sep := "-"
+				if i == len(lines)-1 {
+					sep = " "
+				}
+				reply("250" + sep + l)
+			}
+		case "STARTTLS":
+			reply("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			r = bufio.NewReader(conn)
+			s.mu.Lock()
+			s.usedTLS = true
+			s.mu.Unlock()
+		case "AUTH":
+			parts := strings.Fields(line)
+			decoded, _ := base64.StdEncoding.DecodeString(parts[len(parts)-1])
+			creds := strings.Split(string(decoded), "\x00")
+			if len(creds) == 3 && creds[1] == s.username && creds[2] == s.password {
+				s.mu.Lock()
+				s.authUser = creds[1]
+				s.mu.Unlock()
+				reply("235 authenticated")
+			} else {
+				reply("535 authentication failed")
+			}
+		case "RCPT":
+			addr := strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>")
+			if code, ok := s.rejectRcpt[addr]; ok {
+				reply(strconv.Itoa(code) + " mailbox unavailable")
+			} else {
+				reply("250 ok")
+			}
+		case "DATA":
+			reply("354 end with <CRLF>.<CRLF>")
+			var raw, data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				raw.WriteString(l)
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			s.mu.Lock()
+			s.rawData, s.data = raw.String(), data.String()
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) snapshot() (commands []string, rawData, data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...), s.rawData, s.data
+}
+
+// selfSignedTLS returns a server config and a client config that trusts it
+func selfSignedTLS(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake.test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client = &tls.Config{RootCAs: pool}
+	return server, client
+}
+
+func newTestSMTPConfig(s *fakeSMTPServer) SMTPConfig {
+	return SMTPConfig{
+		Host:    s.addr(),
+		From:    "Alerts <alerts@example.com>",
+		To:      []string{"alice@example.com", "bob@example.com"},
+		Subject: "Disk almost full",
+		Timeout: 5 * time.Second,
+	}
+}
+
+// ============================================================================
+// SMTP DELIVERY TESTS
+// ============================================================================
+
+func TestEmailNotifier_SMTPDelivery(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	server.start()
+
+	notifier := NewSMTPEmailNotifier("postfix", newTestSMTPConfig(server))
+	if err := notifier.Send("disk /var is 95% full"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	commands, _, data := server.snapshot()
+	expected := []string{
+		"EHLO localhost",
+		"MAIL FROM:<alerts@example.com>",
+		"RCPT TO:<alice@example.com>",
+		"RCPT TO:<bob@example.com>",
+		"DATA",
+		"QUIT",
+	}
+	for _, want := range expected {
+		found := false
+		for _, c := range commands {
+			if strings.HasPrefix(c, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected command %q, got %v", want, commands)
+		}
+	}
+
+	for _, header := range []string{
+		"From: Alerts <alerts@example.com>\r\n",
+		"To: alice@example.com, bob@example.com\r\n",
+		"Subject: Disk almost full\r\n",
+		"Date: ",
+		"Message-ID: <",
+		"MIME-Version: 1.0\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+	} {
+		if !strings.Contains(data, header) {
+			t.Errorf("expected header %q in message:\n%s", header, data)
+		}
+	}
+	if !strings.Contains(data, "\r\n\r\ndisk /var is 95% full\r\n") {
+		t.Errorf("expected body after blank line, got:\n%s", data)
+	}
+}
+
+func TestEmailNotifier_SMTPDotStuffing(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	server.start()
+
+	body := "line one\n.\n..leading dots\nlast"
+	notifier := NewSMTPEmailNotifier("postfix", newTestSMTPConfig(server))
+	if err := notifier.Send(body); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, raw, data := server.snapshot()
+	if !strings.Contains(raw, "\r\n..\r\n...leading dots\r\n") {
+		t.Errorf("expected dot-stuffed lines on the wire, got:\n%q", raw)
+	}
+	if !strings.Contains(data, "line one\r\n.\r\n..leading dots\r\nlast\r\n") {
+		t.Errorf("expected original body after un-stuffing, got:\n%q", data)
+	}
+}
+
+func TestEmailNotifier_SMTPAuthPlain(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	server.username, server.password = "alerts", "s3cret"
+	server.start()
+
+	config := newTestSMTPConfig(server)
+	config.Username, config.Password = "alerts", "s3cret"
+
+	if err := NewSMTPEmailNotifier("postfix", config).Send("hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.authUser != "alerts" {
+		t.Errorf("expected AUTH as 'alerts', got '%s'", server.authUser)
+	}
+}
+
+func TestEmailNotifier_SMTPStartTLS(t *testing.T) {
+	serverTLS, clientTLS := selfSignedTLS(t)
+	server := newFakeSMTPServer(t)
+	server.tlsConfig = serverTLS
+	server.username, server.password = "alerts", "s3cret"
+	server.start()
+
+	config := newTestSMTPConfig(server)
+	config.StartTLS = true
+	config.TLSConfig = clientTLS
+	config.Username, config.Password = "alerts", "s3cret"
+
+	if err := NewSMTPEmailNotifier("postfix", config).Send("over tls"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if !server.usedTLS {
+		t.Error("expected STARTTLS to be negotiated")
+	}
+	if !strings.Contains(server.data, "over tls") {
+		t.Errorf("expected message delivered over TLS, got %q", server.data)
+	}
+}
+
+func TestEmailNotifier_WithoutSMTPStillPrints(t *testing.T) {
+	notifier := NewEmailNotifier("gmail")
+	if err := notifier.Send("hello"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestEmailNotifier_SMTPRejectedRecipient(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	server.rejectRcpt["bob@example.com"] = 550
+	server.start()
+
+	err := NewSMTPEmailNotifier("postfix", newTestSMTPConfig(server)).Send("hello")
+
+	var smtpErr *SMTPError
+	if !errors.As(err, &smtpErr) {
+		t.Fatalf("expected *SMTPError, got %v", err)
+	}
+	if smtpErr.Command != "RCPT TO" || smtpErr.Code != 550 {
+		t.Errorf("expected RCPT TO 550, got %s %d", smtpErr.Command, smtpErr.Code)
+	}
+	if smtpErr.Temporary() {
+		t.Error("expected 550 to be permanent")
+	}
+}
+
+func TestEmailNotifier_SMTPTemporaryFailure(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	server.replies["MAIL"] = "451 try again later"
+	server.start()
+
+	err := NewSMTPEmailNotifier("postfix", newTestSMTPConfig(server)).Send("hello")
+
+	var smtpErr *SMTPError
+	if !errors.As(err, &smtpErr) {
+		t.Fatalf("expected *SMTPError, got %v", err)
+	}
+	if !smtpErr.Temporary() {
+		t.Errorf("expected 451 to be temporary, got %v", smtpErr)
+	}
+}
+
+func TestEmailNotifier_SMTPAuthFailure(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	server.username, server.password = "alerts", "s3cret"
+	server.start()
+
+	config := newTestSMTPConfig(server)
+	config.Username, config.Password = "alerts", "wrong"
+
+	err := NewSMTPEmailNotifier("postfix", config).Send("hello")
+	var smtpErr *SMTPError
+	if !errors.As(err, &smtpErr) || smtpErr.Code != 535 {
+		t.Errorf("expected 535 auth failure, got %v", err)
+	}
+}
+
+func TestEmailNotifier_SMTPStartTLSRequiredButMissing(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	server.start()
+
+	config := newTestSMTPConfig(server)
+	config.StartTLS = true
+
+	err := NewSMTPEmailNotifier("postfix", config).Send("hello")
+	if !errors.Is(err, ErrStartTLSUnsupported) {
+		t.Errorf("expected ErrStartTLSUnsupported, got %v", err)
+	}
+}
+
+func TestEmailNotifier_SMTPAuthNotOffered(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	server.start()
+
+	config := newTestSMTPConfig(server)
+	config.Username, config.Password = "alerts", "s3cret"
+
+	err := NewSMTPEmailNotifier("postfix", config).Send("hello")
+	if !errors.Is(err, ErrAuthUnsupported) {
+		t.Errorf("expected ErrAuthUnsupported, got %v", err)
+	}
+}
+
+func TestEmailNotifier_SMTPInvalidConfig(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config SMTPConfig
+	}{
+		{"no recipients", SMTPConfig{Host: "127.0.0.1:1", From: "a@example.com"}},
+		{"bad from", SMTPConfig{Host: "127.0.0.1:1", From: "not-an-address", To: []string{"b@example.com"}}},
+		{"bad recipient", SMTPConfig{Host: "127.0.0.1:1", From: "a@example.com", To: []string{"nope"}}},
+		{"bad host", SMTPConfig{Host: "no-port", From: "a@example.com", To: []string{"b@example.com"}}},
+	}
+
+	for _, tc := range testCases {
+		if err := NewSMTPEmailNotifier("postfix", tc.config).Send("hello"); err == nil {
+			t.Errorf("%s: expected error, got nil", tc.name)
+		}
+	}
+}
+
+func TestEmailNotifier_SMTPConnectionRefused(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+
+	config := SMTPConfig{Host: addr, From: "a@example.com", To: []string{"b@example.com"}, Timeout: time.Second}
+	if err := NewSMTPEmailNotifier("postfix", config).Send("hello"); err == nil {
+		t.Error("expected dial error, got nil")
+	}
+}
@@ -0,0 +1,373 @@
+package factory
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeliveryIDMetadata is the Metadata key that carries a message's delivery ID.
+// WebhookNotifier forwards it, so receivers can quote it in callbacks.
+const DeliveryIDMetadata = "delivery_id"
+
+// ErrUnknownDelivery is returned for a delivery ID the tracker has not seen
+var ErrUnknownDelivery = errors.New("delivery: unknown id")
+
+// DeliveryState is where a message is in its lifecycle
+type DeliveryState int
+
+const (
+	StateQueued DeliveryState = iota
+	StateSending
+	StateSent      // accepted by the vendor
+	StateDelivered // confirmed by a vendor callback
+	StateFailed
+)
+
+func (s DeliveryState) String() string {
+	switch s {
+	case StateQueued:
+		return "queued"
+	case StateSending:
+		return "sending"
+	case StateSent:
+		return "sent"
+	case StateDelivered:
+		return "delivered"
+	case StateFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("state(%d)", int(s))
+}
+
+// MarshalText encodes the state by name, for callbacks and JSON output
+func (s DeliveryState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText parses a state name
+func (s *DeliveryState) UnmarshalText(text []byte) error {
+	for state := StateQueued; state <= StateFailed; state++ {
+		if strings.EqualFold(string(text), state.String()) {
+			*s = state
+			return nil
+		}
+	}
+	return fmt.Errorf("delivery: unknown state %q", text)
+}
+
+// Terminal reports whether no further transitions are allowed
+func (s DeliveryState) Terminal() bool {
+	return s == StateDelivered || s == StateFailed
+}
+
+// deliveryTransitions lists the states each state may move to. A sent
+// message can still fail when the vendor reports a bounce.
+var deliveryTransitions = map[DeliveryState][]DeliveryState{
+	StateQueued:  {StateSending, StateFailed},
+	StateSending: {StateSent, StateFailed},
+	StateSent:    {StateDelivered, StateFailed},
+}
+
+// TransitionError is returned for a state change the lifecycle does not allow
+type TransitionError struct {
+	ID       string
+	From, To DeliveryState
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("delivery: %s cannot move from %s to %s", e.ID, e.From, e.To)
+}
+
+// StatusChange is one entry in a delivery's history
+type StatusChange struct {
+	State  DeliveryState `json:"state"`
+	At     time.Time     `json:"at"`
+	Detail string        `json:"detail,omitempty"`
+}
+
+// DeliveryStatus is the current state and history of one send
+type DeliveryStatus struct {
+	ID         string         `json:"id"`
+	Channel    string         `json:"channel"`
+	Vendor     string         `json:"vendor"`
+	Recipients []string       `json:"recipients,omitempty"`
+	State      DeliveryState  `json:"state"`
+	Error      string         `json:"error,omitempty"` // set when failed
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	History    []StatusChange `json:"history"`
+}
+
+func (s DeliveryStatus) clone() DeliveryStatus {
+	s.Recipients = append([]string(nil), s.Recipients...)
+	s.History = append([]StatusChange(nil), s.History...)
+	return s
+}
+
+// DeliveryTracker records delivery status in memory, indexed by ID and by
+// recipient. It is safe for concurrent use.
+type DeliveryTracker struct {
+	clock Clock
+	newID func() string
+
+	mu          sync.RWMutex
+	deliveries  map[string]*DeliveryStatus
+	byRecipient map[string][]string
+	onChange    []func(DeliveryStatus)
+}
+
+// NewDeliveryTracker creates an empty tracker
+func NewDeliveryTracker(clock Clock) *DeliveryTracker {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &DeliveryTracker{
+		clock:       clock,
+		newID:       randomDeliveryID,
+		deliveries:  make(map[string]*DeliveryStatus),
+		byRecipient: make(map[string][]string),
+	}
+}
+
+func randomDeliveryID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// OnChange registers fn to be called after every state change, including
+// creation. fn runs synchronously and must not call back into the tracker's
+// write methods.
+func (t *DeliveryTracker) OnChange(fn func(DeliveryStatus)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onChange = append(t.onChange, fn)
+}
+
+// Create records a new queued delivery for msg and returns its ID.
+// Put the ID in msg.Metadata[DeliveryIDMetadata] so a TrackingNotifier
+// further down the pipeline continues this record.
+func (t *DeliveryTracker) Create(channel, vendor string, msg Message) string {
+	now := t.clock.Now()
+	status := &DeliveryStatus{
+		ID:         t.newID(),
+		Channel:    channel,
+		Vendor:     vendor,
+		Recipients: msg.Recipients(),
+		State:      StateQueued,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		History:    []StatusChange{{State: StateQueued, At: now}},
+	}
+
+	t.mu.Lock()
+	t.deliveries[status.ID] = status
+	for _, recipient := range status.Recipients {
+		t.byRecipient[recipient] = append(t.byRecipient[recipient], status.ID)
+	}
+	snapshot, hooks := status.clone(), t.onChange
+	t.mu.Unlock()
+
+	notifyStatus(hooks, snapshot)
+	return status.ID
+}
+
+// Transition moves a delivery to state. detail is kept in the history and,
+// for StateFailed, becomes the status error.
+func (t *DeliveryTracker) Transition(id string, state DeliveryState, detail string) error {
+	t.mu.Lock()
+	status, ok := t.deliveries[id]
+	if !ok {
+		t.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrUnknownDelivery, id)
+	}
+	if !allowedTransition(status.State, state) {
+		t.mu.Unlock()
+		return &TransitionError{ID: id, From: status.State, To: state}
+	}
+
+	now := t.clock.Now()
+	status.State = state
+	status.UpdatedAt = now
+	status.History = append(status.History, StatusChange{State: state, At: now, Detail: detail})
+	if state == StateFailed {
+		status.Error = detail
+	}
+	snapshot, hooks := status.clone(), t.onChange
+	t.mu.Unlock()
+
+	notifyStatus(hooks, snapshot)
+	return nil
+}
+
+func allowedTransition(from, to DeliveryState) bool {
+	for _, next := range deliveryTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func notifyStatus(hooks []func(DeliveryStatus), status DeliveryStatus) {
+	for _, hook := range hooks {
+		hook(status)
+	}
+}
+
+// Get returns the status of one delivery
+func (t *DeliveryTracker) Get(id string) (DeliveryStatus, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	status, ok := t.deliveries[id]
+	if !ok {
+		return DeliveryStatus{}, fmt.Errorf("%w: %s", ErrUnknownDelivery, id)
+	}
+	return status.clone(), nil
+}
+
+// ByRecipient returns every delivery addressed to recipient, oldest first
+func (t *DeliveryTracker) ByRecipient(recipient string) []DeliveryStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var statuses []DeliveryStatus
+	for _, id := range t.byRecipient[recipient] {
+		statuses = append(statuses, t.deliveries[id].clone())
+	}
+	return statuses
+}
+
+// DeliveryReceipt is a vendor's report about a delivery
+type DeliveryReceipt struct {
+	ID     string        `json:"id"`
+	State  DeliveryState `json:"state"` // "delivered" or "failed"
+	Detail string        `json:"detail,omitempty"`
+}
+
+// Receipt applies a vendor callback. Only delivered and failed are accepted,
+// since earlier states are reported by the sender itself.
+func (t *DeliveryTracker) Receipt(receipt DeliveryReceipt) error {
+	if !receipt.State.Terminal() {
+		return fmt.Errorf("delivery: receipt for %s must be delivered or failed, got %s", receipt.ID, receipt.State)
+	}
+	return t.Transition(receipt.ID, receipt.State, receipt.Detail)
+}
+
+// CallbackHandler returns an http.Handler that accepts vendor receipts as a
+// JSON DeliveryReceipt POSTed to it. When secret is set the request must be
+// signed like a webhook payload (see SignWebhook).
+//
+// Responses: 204 applied, 400 malformed, 401 bad signature, 404 unknown ID,
+// 409 transition not allowed.
+func (t *DeliveryTracker) CallbackHandler(secret string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if secret != "" {
+			err := VerifyWebhook(secret, r.Header.Get(WebhookTimestampHeader), r.Header.Get(WebhookSignatureHeader),
+				body, 5*time.Minute, t.clock.Now())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		var receipt DeliveryReceipt
+		if err := json.Unmarshal(body, &receipt); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = t.Receipt(receipt)
+		var transitionErr *TransitionError
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, ErrUnknownDelivery):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.As(err, &transitionErr):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	})
+}
+
+// TrackingNotifier records a delivery status for every message it sends
+type TrackingNotifier struct {
+	next    Notifier
+	channel string
+	tracker *DeliveryTracker
+}
+
+// NewTrackingNotifier wraps next, recording its sends as channel in tracker
+func NewTrackingNotifier(next Notifier, channel string, tracker *DeliveryTracker) *TrackingNotifier {
+	return &TrackingNotifier{next: next, channel: channel, tracker: tracker}
+}
+
+// VendorName passes through the wrapped notifier's vendor
+func (n *TrackingNotifier) VendorName() string {
+	return vendorName(n.next)
+}
+
+// Send sends msg and records its status
+func (n *TrackingNotifier) Send(msg string) error {
+	return n.SendContext(context.Background(), msg)
+}
+
+// SendContext is Send bounded by ctx
+func (n *TrackingNotifier) SendContext(ctx context.Context, msg string) error {
+	return n.SendMessage(ctx, Message{Body: msg})
+}
+
+// SendMessage sends msg and records its status
+func (n *TrackingNotifier) SendMessage(ctx context.Context, msg Message) error {
+	_, err := n.Track(ctx, msg)
+	return err
+}
+
+// Track sends msg and returns its delivery ID, which is set even when the
+// send fails. If msg already carries the ID of a queued delivery in
+// Metadata[DeliveryIDMetadata], that record is continued; otherwise a new
+// one is created. The ID is forwarded to the wrapped notifier in Metadata.
+func (n *TrackingNotifier) Track(ctx context.Context, msg Message) (string, error) {
+	id := msg.Metadata[DeliveryIDMetadata]
+	if status, err := n.tracker.Get(id); id == "" || err != nil || status.State != StateQueued {
+		id = n.tracker.Create(n.channel, n.VendorName(), msg)
+	}
+
+	metadata := make(map[string]string, len(msg.Metadata)+1)
+	for k, v := range msg.Metadata {
+		metadata[k] = v
+	}
+	metadata[DeliveryIDMetadata] = id
+	msg.Metadata = metadata
+
+	if err := n.tracker.Transition(id, StateSending, ""); err != nil {
+		return id, err
+	}
+	sendErr := SendMessage(ctx, n.next, msg)
+	if sendErr != nil {
+		if err := n.tracker.Transition(id, StateFailed, sendErr.Error()); err != nil {
+			return id, errors.Join(sendErr, err)
+		}
+		return id, sendErr
+	}
+	return id, n.tracker.Transition(id, StateSent, "")
+}
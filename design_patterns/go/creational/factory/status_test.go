@@ -0,0 +1,209 @@
+package factory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postReceipt(t *testing.T, handler http.Handler, body, secret string, signedAt time.Time) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/callbacks", strings.NewReader(body))
+	if secret != "" {
+		timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, timestamp)
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(secret, timestamp, []byte(body)))
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+// ============================================================================
+// DELIVERY STATUS TESTS
+// ============================================================================
+
+func TestTrackingNotifier_RecordsLifecycle(t *testing.T) {
+	clock := newFakeClock()
+	tracker := NewDeliveryTracker(clock)
+	inner := &messageRecorder{scriptedNotifier: scriptedNotifier{vendor: "twilio"}}
+	notifier := NewTrackingNotifier(inner, "sms", tracker)
+
+	id, err := notifier.Track(context.Background(), Message{To: []string{"+14155552671"}, Body: "code 1234"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clock.Advance(time.Minute)
+	if err := tracker.Receipt(DeliveryReceipt{ID: id, State: StateDelivered}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status, err := tracker.Get(id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var states []DeliveryState
+	for _, change := range status.History {
+		states = append(states, change.State)
+	}
+	want := []DeliveryState{StateQueued, StateSending, StateSent, StateDelivered}
+	if !reflect.DeepEqual(states, want) {
+		t.Errorf("expected history %v, got %v", want, states)
+	}
+	if status.Channel != "sms" || status.Vendor != "twilio" || status.State != StateDelivered {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	if !status.UpdatedAt.Equal(status.CreatedAt.Add(time.Minute)) {
+		t.Errorf("expected UpdatedAt to follow the receipt, got %v", status.UpdatedAt)
+	}
+	if got := inner.Messages()[0].Metadata[DeliveryIDMetadata]; got != id {
+		t.Errorf("expected delivery ID %q forwarded in metadata, got %q", id, got)
+	}
+}
+
+func TestDeliveryTracker_ByRecipient(t *testing.T) {
+	tracker := NewDeliveryTracker(nil)
+	notifier := NewTrackingNotifier(&messageRecorder{}, "email", tracker)
+
+	first, _ := notifier.Track(context.Background(), Message{To: []string{"alice@example.com"}, Body: "one"})
+	notifier.Track(context.Background(), Message{To: []string{"bob@example.com"}, Body: "two"})
+	third, _ := notifier.Track(context.Background(), Message{To: []string{"bob@example.com"}, CC: []string{"alice@example.com"}, Body: "three"})
+
+	statuses := tracker.ByRecipient("alice@example.com")
+	if len(statuses) != 2 || statuses[0].ID != first || statuses[1].ID != third {
+		t.Errorf("expected deliveries %s and %s, got %+v", first, third, statuses)
+	}
+	if got := tracker.ByRecipient("nobody@example.com"); len(got) != 0 {
+		t.Errorf("expected no deliveries, got %+v", got)
+	}
+}
+
+func TestTrackingNotifier_ContinuesQueuedRecord(t *testing.T) {
+	tracker := NewDeliveryTracker(nil)
+	notifier := NewTrackingNotifier(&scriptedNotifier{}, "push", tracker)
+	d, _ := NewDispatcher(DispatcherConfig{Notifier: notifier})
+
+	msg := Message{To: []string{"device-1"}, Body: "ping"}
+	id := tracker.Create("push", "fcm", msg)
+	msg.Metadata = map[string]string{DeliveryIDMetadata: id}
+	d.EnqueueMessage(context.Background(), msg)
+	d.Shutdown(context.Background())
+
+	status, _ := tracker.Get(id)
+	if status.State != StateSent || len(status.History) != 3 {
+		t.Errorf("expected the queued record to be continued to sent, got %+v", status)
+	}
+	if got := tracker.ByRecipient("device-1"); len(got) != 1 {
+		t.Errorf("expected a single record, got %d", len(got))
+	}
+}
+
+func TestDeliveryTracker_OnChange(t *testing.T) {
+	tracker := NewDeliveryTracker(nil)
+	var seen []string
+	tracker.OnChange(func(s DeliveryStatus) { seen = append(seen, s.State.String()) })
+
+	NewTrackingNotifier(&scriptedNotifier{errs: []error{errVendorDown}}, "sms", tracker).Send("hello")
+
+	if want := []string{"queued", "sending", "failed"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("expected %v, got %v", want, seen)
+	}
+}
+
+func TestDeliveryTracker_CallbackHandler(t *testing.T) {
+	clock := newFakeClock()
+	tracker := NewDeliveryTracker(clock)
+	id, _ := NewTrackingNotifier(&scriptedNotifier{}, "email", tracker).Track(context.Background(), Message{Body: "hi"})
+	handler := tracker.CallbackHandler("topsecret")
+
+	body := `{"id":"` + id + `","state":"failed","detail":"mailbox full"}`
+	if code := postReceipt(t, handler, body, "topsecret", clock.Now()); code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", code)
+	}
+	status, _ := tracker.Get(id)
+	if status.State != StateFailed || status.Error != "mailbox full" {
+		t.Errorf("expected bounce to be recorded, got %+v", status)
+	}
+
+	encoded, _ := json.Marshal(status)
+	if !strings.Contains(string(encoded), `"state":"failed"`) {
+		t.Errorf("expected states to encode by name, got %s", encoded)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestTrackingNotifier_FailedSend(t *testing.T) {
+	tracker := NewDeliveryTracker(nil)
+	notifier := NewTrackingNotifier(&scriptedNotifier{errs: []error{errVendorDown}}, "sms", tracker)
+
+	id, err := notifier.Track(context.Background(), Message{Body: "hello"})
+	if !errors.Is(err, errVendorDown) {
+		t.Fatalf("expected vendor error, got %v", err)
+	}
+	status, _ := tracker.Get(id)
+	if status.State != StateFailed || status.Error != "vendor down" {
+		t.Errorf("expected failed status, got %+v", status)
+	}
+}
+
+func TestDeliveryTracker_InvalidTransitions(t *testing.T) {
+	tracker := NewDeliveryTracker(nil)
+	id := tracker.Create("sms", "twilio", Message{Body: "hello"})
+
+	var transitionErr *TransitionError
+	if err := tracker.Transition(id, StateDelivered, ""); !errors.As(err, &transitionErr) {
+		t.Errorf("expected TransitionError for queued -> delivered, got %v", err)
+	}
+	tracker.Transition(id, StateFailed, "cancelled")
+	if err := tracker.Transition(id, StateSending, ""); !errors.As(err, &transitionErr) || transitionErr.From != StateFailed {
+		t.Errorf("expected failed to be terminal, got %v", err)
+	}
+	if err := tracker.Receipt(DeliveryReceipt{ID: id, State: StateSent}); err == nil {
+		t.Error("expected receipts to be limited to delivered or failed, got nil")
+	}
+	if _, err := tracker.Get("missing"); !errors.Is(err, ErrUnknownDelivery) {
+		t.Errorf("expected ErrUnknownDelivery, got %v", err)
+	}
+}
+
+func TestDeliveryTracker_CallbackHandlerRejects(t *testing.T) {
+	clock := newFakeClock()
+	tracker := NewDeliveryTracker(clock)
+	queued := tracker.Create("sms", "twilio", Message{Body: "hello"})
+	handler := tracker.CallbackHandler("topsecret")
+
+	testCases := []struct {
+		name   string
+		body   string
+		secret string
+		want   int
+	}{
+		{"bad signature", `{"id":"` + queued + `","state":"delivered"}`, "wrong", http.StatusUnauthorized},
+		{"malformed", `{"id":`, "topsecret", http.StatusBadRequest},
+		{"unknown state", `{"id":"` + queued + `","state":"bounced"}`, "topsecret", http.StatusBadRequest},
+		{"unknown id", `{"id":"missing","state":"delivered"}`, "topsecret", http.StatusNotFound},
+		{"not yet sent", `{"id":"` + queued + `","state":"delivered"}`, "topsecret", http.StatusConflict},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if code := postReceipt(t, handler, tc.body, tc.secret, clock.Now()); code != tc.want {
+				t.Errorf("expected %d, got %d", tc.want, code)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/callbacks", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,382 @@
+package factory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+)
+
+var (
+	// ErrTemplateNotFound is returned when no locale in the fallback chain has the template
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrMissingVariable is matched by every *MissingVariableError
+	ErrMissingVariable = errors.New("missing template variable")
+)
+
+// MissingVariableError lists the variables a template uses but the data lacks
+type MissingVariableError struct {
+	Template  string // name/channel/locale
+	Variables []string
+}
+
+func (e *MissingVariableError) Error() string {
+	return fmt.Sprintf("template %s: missing variable(s): %s", e.Template, strings.Join(e.Variables, ", "))
+}
+
+// Is makes every MissingVariableError match ErrMissingVariable
+func (e *MissingVariableError) Is(target error) bool {
+	return target == ErrMissingVariable
+}
+
+// TemplateSource is the raw text of one template. Subject and Body use
+// text/template; HTML uses html/template so event data is escaped.
+type TemplateSource struct {
+	Subject string
+	Body    string
+	HTML    string
+}
+
+type templateKey struct {
+	name, channel, locale string
+}
+
+func (k templateKey) String() string {
+	return k.name + "/" + k.channel + "/" + k.locale
+}
+
+type compiledTemplate struct {
+	subject *template.Template
+	body    *template.Template
+	html    *htmltemplate.Template
+
+	// variables are the top-level data keys referenced by any part,
+	// collected before html/template rewrites its tree on first use
+	variables []string
+}
+
+// Templates holds named templates per channel and locale.
+// Lookups fall back from "pt-BR" to "pt" and then to the default locale.
+type Templates struct {
+	defaultLocale string
+
+	mu        sync.RWMutex
+	templates map[templateKey]*compiledTemplate
+}
+
+// NewTemplates creates an empty template set. defaultLocale defaults to "en".
+func NewTemplates(defaultLocale string) *Templates {
+	if defaultLocale == "" {
+		defaultLocale = "en"
+	}
+	return &Templates{
+		defaultLocale: normalizeLocale(defaultLocale),
+		templates:     make(map[templateKey]*compiledTemplate),
+	}
+}
+
+// Add parses and stores a template, replacing any previous one with the same key
+func (t *Templates) Add(name, channel, locale string, source TemplateSource) error {
+	if name == "" || channel == "" || locale == "" {
+		return errors.New("template: name, channel and locale are required")
+	}
+	if source.Body == "" && source.HTML == "" {
+		return fmt.Errorf("template %s/%s/%s: body or HTML is required", name, channel, locale)
+	}
+
+	key := templateKey{name: name, channel: channel, locale: normalizeLocale(locale)}
+	compiled := &compiledTemplate{}
+	used := make(map[string]bool)
+	var err error
+	if source.Subject != "" {
+		if compiled.subject, err = template.New("subject").Option("missingkey=error").Parse(source.Subject); err != nil {
+			return fmt.Errorf("template %s: subject: %w", key, err)
+		}
+		collectRootFields(compiled.subject.Tree.Root, true, used)
+	}
+	if source.Body != "" {
+		if compiled.body, err = template.New("body").Option("missingkey=error").Parse(source.Body); err != nil {
+			return fmt.Errorf("template %s: body: %w", key, err)
+		}
+		collectRootFields(compiled.body.Tree.Root, true, used)
+	}
+	if source.HTML != "" {
+		if compiled.html, err = htmltemplate.New("html").Option("missingkey=error").Parse(source.HTML); err != nil {
+			return fmt.Errorf("template %s: html: %w", key, err)
+		}
+		collectRootFields(compiled.html.Tree.Root, true, used)
+	}
+	for name := range used {
+		compiled.variables = append(compiled.variables, name)
+	}
+	sort.Strings(compiled.variables)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.templates[key] = compiled
+	return nil
+}
+
+// LoadFS adds every template found under fsys laid out as
+//
+//	<channel>/<locale>/<name>.subject.tmpl
+//	<channel>/<locale>/<name>.txt.tmpl
+//	<channel>/<locale>/<name>.html.tmpl
+//
+// Files that do not match the layout are ignored.
+func (t *Templates) LoadFS(fsys fs.FS) error {
+	sources := make(map[templateKey]*TemplateSource)
+	var order []templateKey
+
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		parts := strings.Split(p, "/")
+		if len(parts) != 3 || !strings.HasSuffix(parts[2], ".tmpl") {
+			return nil
+		}
+		base := strings.TrimSuffix(parts[2], ".tmpl")
+		ext := path.Ext(base)
+		name := strings.TrimSuffix(base, ext)
+		if name == "" {
+			return nil
+		}
+
+		key := templateKey{name: name, channel: parts[0], locale: parts[1]}
+		source, ok := sources[key]
+		if !ok {
+			source = &TemplateSource{}
+			sources[key] = source
+			order = append(order, key)
+		}
+
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		switch ext {
+		case ".subject":
+			source.Subject = string(content)
+		case ".txt":
+			source.Body = string(content)
+		case ".html":
+			source.HTML = string(content)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("template: load: %w", err)
+	}
+
+	for _, key := range order {
+		if err := t.Add(key.name, key.channel, key.locale, *sources[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Render looks up a template through the locale fallback chain and renders
+// it into a Message. Every variable the template reads from the top level
+// of data is checked first, so a *MissingVariableError lists all of them.
+func (t *Templates) Render(name, channel, locale string, data any) (Message, error) {
+	key, compiled, ok := t.lookup(name, channel, locale)
+	if !ok {
+		return Message{}, fmt.Errorf("%w: %s/%s/%s", ErrTemplateNotFound, name, channel, locale)
+	}
+
+	if missing := compiled.missingVariables(data); len(missing) > 0 {
+		return Message{}, &MissingVariableError{Template: key.String(), Variables: missing}
+	}
+
+	var msg Message
+	var err error
+	if compiled.subject != nil {
+		if msg.Subject, err = execute(compiled.subject, data); err != nil {
+			return Message{}, fmt.Errorf("template %s: subject: %w", key, err)
+		}
+		msg.Subject = strings.TrimSpace(msg.Subject)
+	}
+	if compiled.body != nil {
+		if msg.Body, err = execute(compiled.body, data); err != nil {
+			return Message{}, fmt.Errorf("template %s: body: %w", key, err)
+		}
+	}
+	if compiled.html != nil {
+		if msg.HTMLBody, err = execute(compiled.html, data); err != nil {
+			return Message{}, fmt.Errorf("template %s: html: %w", key, err)
+		}
+	}
+	return msg, nil
+}
+
+// Send renders a template, taking name, channel and locale in the same
+// order as Render, and sends it to the given recipients through n.
+// Nothing is sent when rendering fails.
+func (t *Templates) Send(ctx context.Context, n Notifier, name, channel, locale string, data any, to ...string) error {
+	msg, err := t.Render(name, channel, locale, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	return SendMessage(ctx, n, msg)
+}
+
+// Locales returns the locales tried for a requested locale, most specific first
+func (t *Templates) Locales(locale string) []string {
+	var chain []string
+	seen := make(map[string]bool)
+	add := func(l string) {
+		if l != "" && !seen[l] {
+			seen[l] = true
+			chain = append(chain, l)
+		}
+	}
+
+	l := normalizeLocale(locale)
+	for l != "" {
+		add(l)
+		i := strings.LastIndex(l, "-")
+		if i < 0 {
+			break
+		}
+		l = l[:i]
+	}
+	add(t.defaultLocale)
+	return chain
+}
+
+func (t *Templates) lookup(name, channel, locale string) (templateKey, *compiledTemplate, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, l := range t.Locales(locale) {
+		key := templateKey{name: name, channel: channel, locale: l}
+		if compiled, ok := t.templates[key]; ok {
+			return key, compiled, true
+		}
+	}
+	return templateKey{}, nil, false
+}
+
+// normalizeLocale turns "pt_BR" and "PT-br" into "pt-br"
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// execute renders a text or HTML template to a string
+func execute(tmpl interface {
+	Execute(w io.Writer, data any) error
+}, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// missingVariables returns the template's variables that data does not provide
+func (c *compiledTemplate) missingVariables(data any) []string {
+	var missing []string
+	for _, name := range c.variables {
+		if !hasVariable(data, name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// collectRootFields records the first identifier of every field evaluated
+// against the top-level data. Inside range and with, dot is rebound, so only
+// $-rooted references are collected there.
+func collectRootFields(node parse.Node, root bool, used map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectRootFields(child, root, used)
+		}
+	case *parse.ActionNode:
+		collectRootFields(n.Pipe, root, used)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectRootFields(cmd, root, used)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectRootFields(arg, root, used)
+		}
+	case *parse.ChainNode:
+		collectRootFields(n.Node, root, used)
+	case *parse.FieldNode:
+		if root {
+			used[n.Ident[0]] = true
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			used[n.Ident[1]] = true
+		}
+	case *parse.IfNode:
+		collectRootFields(n.Pipe, root, used)
+		collectRootFields(n.List, root, used)
+		collectRootFields(n.ElseList, root, used)
+	case *parse.RangeNode:
+		collectRootFields(n.Pipe, root, used)
+		collectRootFields(n.List, false, used)
+		collectRootFields(n.ElseList, root, used)
+	case *parse.WithNode:
+		collectRootFields(n.Pipe, root, used)
+		collectRootFields(n.List, false, used)
+		collectRootFields(n.ElseList, root, used)
+	case *parse.TemplateNode:
+		collectRootFields(n.Pipe, root, used)
+	}
+}
+
+// hasVariable reports whether data has a map key, field or method called name.
+// Data of other kinds is left for template execution to reject.
+func hasVariable(data any, name string) bool {
+	v := reflect.ValueOf(data)
+	if !v.IsValid() {
+		return false
+	}
+	if v.Type().NumMethod() > 0 && v.MethodByName(name).IsValid() {
+		return true
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return true
+		}
+		return v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key())).IsValid()
+	case reflect.Struct:
+		if v.FieldByName(name).IsValid() {
+			return true
+		}
+		return v.MethodByName(name).IsValid()
+	}
+	return true
+}
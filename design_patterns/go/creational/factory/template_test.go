@@ -0,0 +1,230 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func newTestTemplates(t *testing.T) *Templates {
+	t.Helper()
+	templates := NewTemplates("en")
+	err := templates.LoadFS(fstest.MapFS{
+		"email/en/welcome.subject.tmpl": {Data: []byte("Welcome, {{.Name}}!\n")},
+		"email/en/welcome.txt.tmpl":     {Data: []byte("Hi {{.Name}}, your plan is {{.Plan}}.")},
+		"email/en/welcome.html.tmpl":    {Data: []byte("<p>Hi {{.Name}}, your plan is <b>{{.Plan}}</b>.</p>")},
+		"email/pt/welcome.subject.tmpl": {Data: []byte("Bem-vindo, {{.Name}}!")},
+		"email/pt/welcome.txt.tmpl":     {Data: []byte("Olá {{.Name}}, seu plano é {{.Plan}}.")},
+		"sms/en/welcome.txt.tmpl":       {Data: []byte("Welcome {{.Name}}! Reply STOP to opt out.")},
+		"sms/en/README.md":              {Data: []byte("ignored")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected load error: %v", err)
+	}
+	return templates
+}
+
+// ============================================================================
+// TEMPLATE TESTS
+// ============================================================================
+
+func TestTemplates_RenderPerChannel(t *testing.T) {
+	templates := newTestTemplates(t)
+	data := map[string]any{"Name": "Ana", "Plan": "pro"}
+
+	email, err := templates.Render("welcome", "email", "en", data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if email.Subject != "Welcome, Ana!" {
+		t.Errorf("expected trimmed subject, got '%s'", email.Subject)
+	}
+	if email.Body != "Hi Ana, your plan is pro." {
+		t.Errorf("unexpected body: '%s'", email.Body)
+	}
+	if email.HTMLBody != "<p>Hi Ana, your plan is <b>pro</b>.</p>" {
+		t.Errorf("unexpected HTML body: '%s'", email.HTMLBody)
+	}
+
+	sms, err := templates.Render("welcome", "sms", "en", data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sms.Body != "Welcome Ana! Reply STOP to opt out." || sms.HTMLBody != "" || sms.Subject != "" {
+		t.Errorf("unexpected SMS message: %+v", sms)
+	}
+}
+
+func TestTemplates_LocaleFallback(t *testing.T) {
+	templates := newTestTemplates(t)
+	data := map[string]any{"Name": "Ana", "Plan": "pro"}
+
+	testCases := []struct {
+		locale  string
+		channel string
+		subject string
+	}{
+		{"pt", "email", "Bem-vindo, Ana!"},
+		{"pt-BR", "email", "Bem-vindo, Ana!"},
+		{"pt_br", "email", "Bem-vindo, Ana!"},
+		{"fr-CA", "email", "Welcome, Ana!"},
+		{"", "email", "Welcome, Ana!"},
+	}
+	for _, tc := range testCases {
+		msg, err := templates.Render("welcome", tc.channel, tc.locale, data)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.locale, err)
+			continue
+		}
+		if msg.Subject != tc.subject {
+			t.Errorf("%s: expected '%s', got '%s'", tc.locale, tc.subject, msg.Subject)
+		}
+	}
+
+	// sms has no pt template, so pt-BR falls back to en
+	msg, err := templates.Render("welcome", "sms", "pt-BR", data)
+	if err != nil || !strings.HasPrefix(msg.Body, "Welcome Ana!") {
+		t.Errorf("expected English SMS fallback, got %+v, %v", msg, err)
+	}
+}
+
+func TestTemplates_Locales(t *testing.T) {
+	templates := NewTemplates("en-US")
+	expected := []string{"zh-hant-tw", "zh-hant", "zh", "en-us"}
+	if got := templates.Locales("zh-Hant-TW"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestTemplates_HTMLEscapesData(t *testing.T) {
+	templates := newTestTemplates(t)
+	msg, err := templates.Render("welcome", "email", "en", map[string]any{"Name": "<script>", "Plan": "pro"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(msg.HTMLBody, "<script>") {
+		t.Errorf("expected HTML body to be escaped, got '%s'", msg.HTMLBody)
+	}
+	if !strings.Contains(msg.Body, "<script>") {
+		t.Errorf("expected text body to be left as is, got '%s'", msg.Body)
+	}
+}
+
+func TestTemplates_StructDataAndScopes(t *testing.T) {
+	type order struct {
+		Customer string
+		Items    []string
+	}
+	templates := NewTemplates("en")
+	err := templates.Add("order", "sms", "en", TemplateSource{
+		Body: "{{.Customer}}:{{range .Items}} {{.}}{{end}}{{with .Customer}} ({{.}}){{end}}",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	msg, err := templates.Render("order", "sms", "en", order{Customer: "Ana", Items: []string{"tea", "cake"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.Body != "Ana: tea cake (Ana)" {
+		t.Errorf("unexpected body: '%s'", msg.Body)
+	}
+}
+
+func TestTemplates_SendRendersAndDelivers(t *testing.T) {
+	templates := newTestTemplates(t)
+	inner := &messageRecorder{}
+
+	err := templates.Send(context.Background(), inner, "welcome", "sms", "en", map[string]string{"Name": "Ana"}, "+14155552671")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := inner.Messages()
+	if len(got) != 1 || got[0].Body != "Welcome Ana! Reply STOP to opt out." || got[0].To[0] != "+14155552671" {
+		t.Errorf("unexpected messages: %+v", got)
+	}
+}
+
+func TestTemplates_ConcurrentRender(t *testing.T) {
+	templates := newTestTemplates(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := templates.Render("welcome", "email", "en", map[string]any{"Name": "Ana", "Plan": "pro"}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestTemplates_MissingVariables(t *testing.T) {
+	templates := newTestTemplates(t)
+
+	_, err := templates.Render("welcome", "email", "en", map[string]any{})
+	if !errors.Is(err, ErrMissingVariable) {
+		t.Fatalf("expected ErrMissingVariable, got %v", err)
+	}
+	var missingErr *MissingVariableError
+	if !errors.As(err, &missingErr) {
+		t.Fatalf("expected *MissingVariableError, got %v", err)
+	}
+	if !reflect.DeepEqual(missingErr.Variables, []string{"Name", "Plan"}) {
+		t.Errorf("expected Name and Plan to be reported, got %v", missingErr.Variables)
+	}
+	if missingErr.Template != "welcome/email/en" {
+		t.Errorf("expected template 'welcome/email/en', got '%s'", missingErr.Template)
+	}
+
+	type user struct{ Name string }
+	if _, err := templates.Render("welcome", "email", "en", user{Name: "Ana"}); !errors.Is(err, ErrMissingVariable) {
+		t.Errorf("expected ErrMissingVariable for struct without Plan, got %v", err)
+	}
+}
+
+func TestTemplates_NothingSentOnRenderError(t *testing.T) {
+	templates := newTestTemplates(t)
+	inner := &messageRecorder{}
+
+	err := templates.Send(context.Background(), inner, "welcome", "sms", "en", nil, "+14155552671")
+	if !errors.Is(err, ErrMissingVariable) {
+		t.Errorf("expected ErrMissingVariable, got %v", err)
+	}
+	if inner.Calls() != 0 {
+		t.Errorf("expected nothing sent, got %d calls", inner.Calls())
+	}
+}
+
+func TestTemplates_NotFound(t *testing.T) {
+	templates := newTestTemplates(t)
+
+	if _, err := templates.Render("welcome", "push", "en", nil); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("expected ErrTemplateNotFound for unknown channel, got %v", err)
+	}
+	if _, err := templates.Render("goodbye", "email", "pt", nil); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("expected ErrTemplateNotFound for unknown name, got %v", err)
+	}
+}
+
+func TestTemplates_AddInvalid(t *testing.T) {
+	templates := NewTemplates("")
+
+	if err := templates.Add("", "sms", "en", TemplateSource{Body: "x"}); err == nil {
+		t.Error("expected error for missing name, got nil")
+	}
+	if err := templates.Add("empty", "sms", "en", TemplateSource{Subject: "only a subject"}); err == nil {
+		t.Error("expected error for missing body, got nil")
+	}
+	if err := templates.Add("broken", "sms", "en", TemplateSource{Body: "{{.Name"}); err == nil {
+		t.Error("expected parse error, got nil")
+	}
+}
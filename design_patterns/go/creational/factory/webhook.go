@@ -0,0 +1,213 @@
+package factory
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader carries "sha256=<hex hmac>" of "<timestamp>.<body>"
+	WebhookSignatureHeader = "X-Notifier-Signature"
+	// WebhookTimestampHeader carries the unix time the payload was signed at
+	WebhookTimestampHeader = "X-Notifier-Timestamp"
+	// WebhookSecretEnv names the environment variable holding the signing
+	// secret for webhooks built by NotifierFactory
+	WebhookSecretEnv = "WEBHOOK_SECRET"
+)
+
+var (
+	// ErrInvalidSignature is returned when a webhook signature does not match
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	// ErrStaleTimestamp is returned when a webhook is outside the replay window
+	ErrStaleTimestamp = errors.New("webhook: timestamp outside tolerance")
+	// ErrWebhookSecretRequired is returned by NotifierFactory("webhook", url)
+	// when WebhookSecretEnv is unset, so factory-built webhooks are never unsigned
+	ErrWebhookSecretRequired = errors.New("webhook: signing secret required, set " + WebhookSecretEnv)
+)
+
+// WebhookPayload is the JSON body POSTed by WebhookNotifier
+type WebhookPayload struct {
+	Channel   string `json:"channel"`
+	Vendor    string `json:"vendor"`
+	Message   string `json:"message"`
+	Timestamp int64  `json:"timestamp"`
+
+	// Set from the structured Message when present
+	To          []string          `json:"to,omitempty"`
+	Subject     string            `json:"subject,omitempty"`
+	Priority    string            `json:"priority,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Attachments []Attachment      `json:"attachments,omitempty"`
+
+	IdempotencyKey string `json:"idempotency_key,omitempty"` // lets receivers drop duplicates
+}
+
+// WebhookError is returned when the endpoint answers with a non-2xx status
+type WebhookError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *WebhookError) Error() string {
+	return fmt.Sprintf("webhook: unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether the endpoint may accept a later retry
+// (timeouts, throttling and server errors)
+func (e *WebhookError) Temporary() bool {
+	return e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= 500
+}
+
+// WebhookNotifier sends notifications as signed HTTP POSTs
+type WebhookNotifier struct {
+	Vendor string
+	URL    string
+	Secret string // payloads are signed with HMAC-SHA256 when set
+	Client *http.Client
+
+	now func() time.Time
+}
+
+// NewWebhookNotifier creates a WebhookNotifier for the given endpoint
+func NewWebhookNotifier(vendor, endpoint, secret string) *WebhookNotifier {
+	return &WebhookNotifier{
+		Vendor: vendor,
+		URL:    endpoint,
+		Secret: secret,
+		Client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+	}
+}
+
+// VendorName returns the vendor backing this notifier
+func (w *WebhookNotifier) VendorName() string {
+	return w.Vendor
+}
+
+// newWebhookFromFactory backs NotifierFactory("webhook", url).
+// The vendor argument is the endpoint URL and payloads are signed with the
+// secret in WebhookSecretEnv. Use NewWebhookNotifier for unsigned webhooks.
+func newWebhookFromFactory(endpoint string) (Notifier, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("webhook: invalid endpoint URL: %q", endpoint)
+	}
+	secret := os.Getenv(WebhookSecretEnv)
+	if secret == "" {
+		return nil, ErrWebhookSecretRequired
+	}
+	return NewWebhookNotifier(parsed.Host, endpoint, secret), nil
+}
+
+// Send POSTs the message as JSON and fails on any non-2xx response
+func (w *WebhookNotifier) Send(msg string) error {
+	return w.SendContext(context.Background(), msg)
+}
+
+// SendContext posts the payload, abandoning the request when ctx ends
+func (w *WebhookNotifier) SendContext(ctx context.Context, msg string) error {
+	return w.SendMessage(ctx, Message{Body: msg})
+}
+
+// SendMessage posts a structured message; Body becomes the "message" field
+func (w *WebhookNotifier) SendMessage(ctx context.Context, msg Message) error {
+	if err := msg.Validate("webhook"); err != nil {
+		return err
+	}
+
+	now := time.Now
+	if w.now != nil {
+		now = w.now
+	}
+	timestamp := now().Unix()
+
+	payload := WebhookPayload{
+		Channel:     "webhook",
+		Vendor:      w.Vendor,
+		Message:     msg.Body,
+		Timestamp:   timestamp,
+		To:          msg.To,
+		Subject:     msg.Subject,
+		Metadata:    msg.Metadata,
+		Attachments: msg.Attachments,
+
+		IdempotencyKey: msg.IdempotencyKey,
+	}
+	if msg.Priority != PriorityNormal {
+		payload.Priority = msg.Priority.String()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("webhook: encode payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		ts := strconv.FormatInt(timestamp, 10)
+		req.Header.Set(WebhookTimestampHeader, ts)
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(w.Secret, ts, body))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: post %s: %w", w.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &WebhookError{StatusCode: resp.StatusCode, Body: string(snippet)}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// SignWebhook returns the signature header value for a timestamp and body
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks a received webhook. Receivers should call it with the
+// raw request body; requests older than tolerance are rejected as replays.
+func VerifyWebhook(secret, timestamp, signature string, body []byte, tolerance time.Duration, now time.Time) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrStaleTimestamp, timestamp)
+	}
+	age := now.Sub(time.Unix(ts, 0))
+	if age < -tolerance || age > tolerance {
+		return ErrStaleTimestamp
+	}
+
+	expected := SignWebhook(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package factory
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// capturedWebhook records one request received by a test endpoint
+type capturedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func newWebhookServer(t *testing.T, status int) (*httptest.Server, chan capturedWebhook) {
+	t.Helper()
+	received := make(chan capturedWebhook, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- capturedWebhook{header: r.Header.Clone(), body: body}
+		w.WriteHeader(status)
+		w.Write([]byte("downstream says hi"))
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+// ============================================================================
+// WEBHOOK TESTS
+// ============================================================================
+
+func TestWebhookNotifier_PostsSignedJSON(t *testing.T) {
+	server, received := newWebhookServer(t, http.StatusOK)
+	fixed := time.Unix(1700000000, 0)
+
+	notifier := NewWebhookNotifier("billing", server.URL, "topsecret")
+	notifier.now = func() time.Time { return fixed }
+
+	if err := notifier.Send("invoice paid"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := <-received
+	if ct := req.header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json, got '%s'", ct)
+	}
+
+	var payload WebhookPayload
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatalf("invalid JSON payload: %v", err)
+	}
+	if payload.Message != "invoice paid" || payload.Vendor != "billing" || payload.Channel != "webhook" {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+	if payload.Timestamp != fixed.Unix() {
+		t.Errorf("expected timestamp %d, got %d", fixed.Unix(), payload.Timestamp)
+	}
+
+	ts := req.header.Get(WebhookTimestampHeader)
+	if ts != strconv.FormatInt(fixed.Unix(), 10) {
+		t.Errorf("expected timestamp header %d, got '%s'", fixed.Unix(), ts)
+	}
+	sig := req.header.Get(WebhookSignatureHeader)
+	if err := VerifyWebhook("topsecret", ts, sig, req.body, 5*time.Minute, fixed); err != nil {
+		t.Errorf("expected signature to verify, got %v", err)
+	}
+}
+
+func TestWebhookNotifier_UnsignedWithoutSecret(t *testing.T) {
+	server, received := newWebhookServer(t, http.StatusAccepted)
+
+	if err := NewWebhookNotifier("billing", server.URL, "").Send("hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := <-received
+	if sig := req.header.Get(WebhookSignatureHeader); sig != "" {
+		t.Errorf("expected no signature without secret, got '%s'", sig)
+	}
+}
+
+func TestNotifierFactory_ReturnsWebhookNotifier(t *testing.T) {
+	server, received := newWebhookServer(t, http.StatusNoContent)
+	t.Setenv(WebhookSecretEnv, "s3cret")
+
+	notifier, err := NotifierFactory("webhook", server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	webhook, ok := notifier.(*WebhookNotifier)
+	if !ok {
+		t.Fatalf("expected *WebhookNotifier, got %T", notifier)
+	}
+	if webhook.URL != server.URL {
+		t.Errorf("expected URL '%s', got '%s'", server.URL, webhook.URL)
+	}
+
+	if err := notifier.Send("hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := <-received
+	ts, sig := req.header.Get(WebhookTimestampHeader), req.header.Get(WebhookSignatureHeader)
+	if err := VerifyWebhook("s3cret", ts, sig, req.body, 5*time.Minute, time.Now()); err != nil {
+		t.Errorf("expected the payload signed with %s, got %v", WebhookSecretEnv, err)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestNotifierFactory_WebhookRequiresSecret(t *testing.T) {
+	t.Setenv(WebhookSecretEnv, "")
+
+	if _, err := NotifierFactory("webhook", "https://hooks.example.com/notify"); !errors.Is(err, ErrWebhookSecretRequired) {
+		t.Errorf("expected ErrWebhookSecretRequired, got %v", err)
+	}
+}
+
+func TestWebhookNotifier_Non2xxIsError(t *testing.T) {
+	for _, status := range []int{http.StatusMovedPermanently, http.StatusBadRequest, http.StatusInternalServerError} {
+		server, _ := newWebhookServer(t, status)
+
+		err := NewWebhookNotifier("billing", server.URL, "").Send("hello")
+
+		var webhookErr *WebhookError
+		if !errors.As(err, &webhookErr) {
+			t.Fatalf("status %d: expected *WebhookError, got %v", status, err)
+		}
+		if webhookErr.StatusCode != status {
+			t.Errorf("expected status %d, got %d", status, webhookErr.StatusCode)
+		}
+		if webhookErr.Body != "downstream says hi" {
+			t.Errorf("expected response body in error, got '%s'", webhookErr.Body)
+		}
+	}
+}
+
+func TestWebhookNotifier_ConnectionError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	endpoint := server.URL
+	server.Close()
+
+	if err := NewWebhookNotifier("billing", endpoint, "").Send("hello"); err == nil {
+		t.Error("expected connection error, got nil")
+	}
+}
+
+func TestNotifierFactory_WebhookInvalidURL(t *testing.T) {
+	for _, endpoint := range []string{"", "not a url", "ftp://example.com/hook", "http://"} {
+		notifier, err := NotifierFactory("webhook", endpoint)
+		if err == nil {
+			t.Errorf("expected error for endpoint %q, got nil", endpoint)
+		}
+		if notifier != nil {
+			t.Errorf("expected nil notifier for %q, got %T", endpoint, notifier)
+		}
+	}
+}
+
+func TestVerifyWebhook_RejectsTamperingAndReplay(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"message":"hello"}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := SignWebhook("topsecret", ts, body)
+
+	if err := VerifyWebhook("topsecret", ts, sig, []byte(`{"message":"evil"}`), time.Minute, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for tampered body, got %v", err)
+	}
+	if err := VerifyWebhook("wrong", ts, sig, body, time.Minute, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for wrong secret, got %v", err)
+	}
+	if err := VerifyWebhook("topsecret", ts, sig, body, time.Minute, now.Add(10*time.Minute)); !errors.Is(err, ErrStaleTimestamp) {
+		t.Errorf("expected ErrStaleTimestamp for replayed request, got %v", err)
+	}
+	if err := VerifyWebhook("topsecret", "yesterday", sig, body, time.Minute, now); !errors.Is(err, ErrStaleTimestamp) {
+		t.Errorf("expected ErrStaleTimestamp for bad timestamp, got %v", err)
+	}
+}
@@ -0,0 +1,328 @@
+package factory
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML decodes the subset of YAML used by config files into the same
+// shapes encoding/json produces with UseNumber: map[string]any, []any,
+// string, json.Number, bool and nil.
+//
+// Supported: block mappings and sequences, plain, single- and double-quoted
+// scalars, flow sequences of scalars ([a, b]), empty flow collections and
+// comments. Anchors, tags, block scalars and multiple documents are not.
+func parseYAML(data []byte) (any, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(string(data), "\n") {
+		num := i + 1
+		raw = strings.TrimRight(raw, " \r")
+		trimmed := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tabs are not allowed in indentation", num)
+		}
+		text, err := stripYAMLComment(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: line %d: %w", num, err)
+		}
+		if text == "" {
+			continue
+		}
+		if text == "---" && len(p.lines) == 0 {
+			continue
+		}
+		if text == "---" || text == "..." {
+			return nil, fmt.Errorf("yaml: line %d: multiple documents are not supported", num)
+		}
+		p.lines = append(p.lines, yamlLine{indent: len(raw) - len(trimmed), text: text, num: num})
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+	if p.lines[0].indent != 0 {
+		return nil, fmt.Errorf("yaml: line %d: document must not be indented", p.lines[0].num)
+	}
+	node, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("yaml: line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return node, nil
+}
+
+type yamlLine struct {
+	indent int
+	text   string
+	num    int
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseBlock parses the mapping or sequence starting at the current line
+func (p *yamlParser) parseBlock() (any, error) {
+	line := p.lines[p.pos]
+	if isYAMLSeqItem(line.text) {
+		return p.parseSeq(line.indent)
+	}
+	return p.parseMap(line.indent)
+}
+
+func (p *yamlParser) parseMap(indent int) (any, error) {
+	m := make(map[string]any)
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		if isYAMLSeqItem(line.text) {
+			return nil, fmt.Errorf("yaml: line %d: expected a mapping key, got a sequence item", line.num)
+		}
+		key, rest, err := splitYAMLKey(line.text)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: line %d: %w", line.num, err)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("yaml: line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+
+		if rest != "" {
+			if m[key], err = parseYAMLValue(rest); err != nil {
+				return nil, fmt.Errorf("yaml: line %d: %w", line.num, err)
+			}
+			continue
+		}
+		// A nested block is indented further; a sequence may also sit at
+		// the key's own indentation
+		if p.pos < len(p.lines) {
+			next := p.lines[p.pos]
+			if next.indent > indent || (next.indent == indent && isYAMLSeqItem(next.text)) {
+				if m[key], err = p.parseBlock(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		m[key] = nil
+	}
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return nil, fmt.Errorf("yaml: line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return m, nil
+}
+
+func (p *yamlParser) parseSeq(indent int) (any, error) {
+	seq := []any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSeqItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		content := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+
+		switch {
+		case content == "":
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				item, err := p.parseBlock()
+				if err != nil {
+					return nil, err
+				}
+				seq = append(seq, item)
+			} else {
+				seq = append(seq, nil)
+			}
+		case isYAMLSeqItem(content) || isYAMLMapEntry(content):
+			// "- key: value" starts a block whose other lines are aligned
+			// with key, so re-read this line at that column
+			p.lines[p.pos] = yamlLine{indent: indent + len(line.text) - len(content), text: content, num: line.num}
+			item, err := p.parseBlock()
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, item)
+		default:
+			item, err := parseYAMLValue(content)
+			if err != nil {
+				return nil, fmt.Errorf("yaml: line %d: %w", line.num, err)
+			}
+			seq = append(seq, item)
+			p.pos++
+		}
+	}
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return nil, fmt.Errorf("yaml: line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return seq, nil
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func isYAMLMapEntry(text string) bool {
+	if text[0] == '[' || text[0] == '{' {
+		return false
+	}
+	_, _, err := splitYAMLKey(text)
+	return err == nil
+}
+
+// splitYAMLKey splits "key: value" into its key and the raw value
+func splitYAMLKey(text string) (key, rest string, err error) {
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quoted key")
+		}
+		if key, err = unquoteYAML(text[:end+1]); err != nil {
+			return "", "", err
+		}
+		after := text[end+1:]
+		if after != ":" && !strings.HasPrefix(after, ": ") {
+			return "", "", fmt.Errorf("expected ':' after key %q", key)
+		}
+		return key, strings.TrimSpace(after[1:]), nil
+	}
+
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", fmt.Errorf("expected 'key: value', got %q", text)
+		}
+		i = len(text) - 1
+	}
+	return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), nil
+}
+
+// parseYAMLValue parses an inline value: a scalar or a flow sequence
+func parseYAMLValue(text string) (any, error) {
+	switch {
+	case text == "{}":
+		return map[string]any{}, nil
+	case text[0] == '{':
+		return nil, fmt.Errorf("flow mappings are not supported, use an indented block")
+	case text[0] == '[':
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("unterminated flow sequence %q", text)
+		}
+		seq := []any{}
+		inner := strings.TrimSpace(text[1 : len(text)-1])
+		if inner == "" {
+			return seq, nil
+		}
+		parts, err := splitFlowItems(inner)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range parts {
+			if part == "" || part[0] == '[' || part[0] == '{' {
+				return nil, fmt.Errorf("unsupported flow sequence item %q", part)
+			}
+			item, err := parseYAMLScalar(part)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, item)
+		}
+		return seq, nil
+	case text[0] == '&' || text[0] == '*' || text[0] == '!':
+		return nil, fmt.Errorf("anchors, aliases and tags are not supported")
+	case text == "|" || text == ">" || strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">"):
+		return nil, fmt.Errorf("block scalars are not supported, use a quoted string")
+	}
+	return parseYAMLScalar(text)
+}
+
+// parseYAMLScalar resolves a quoted string, null, bool, number or plain string
+func parseYAMLScalar(text string) (any, error) {
+	if text[0] == '"' || text[0] == '\'' {
+		if closingQuote(text) != len(text)-1 {
+			return nil, fmt.Errorf("malformed quoted string %s", text)
+		}
+		return unquoteYAML(text)
+	}
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if _, err := strconv.ParseFloat(text, 64); err == nil && !strings.ContainsAny(text, "xXnN") {
+		return json.Number(text), nil
+	}
+	return text, nil
+}
+
+func splitFlowItems(text string) ([]string, error) {
+	var items []string
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '"', '\'':
+			end := closingQuote(text[i:])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted string in %q", text)
+			}
+			i += end
+		case ',':
+			items = append(items, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	return append(items, strings.TrimSpace(text[start:])), nil
+}
+
+// closingQuote returns the index of the quote closing the string that opens
+// text, or -1
+func closingQuote(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case quote == '\'' && text[i] == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func unquoteYAML(text string) (string, error) {
+	if text[0] == '\'' {
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+	s, err := strconv.Unquote(text)
+	if err != nil {
+		return "", fmt.Errorf("malformed quoted string %s", text)
+	}
+	return s, nil
+}
+
+// stripYAMLComment removes a trailing "# comment" outside quotes
+func stripYAMLComment(text string) (string, error) {
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '"', '\'':
+			// Quotes only open a string at the start of a value
+			if i > 0 && text[i-1] != ' ' && text[i-1] != '[' && text[i-1] != ',' {
+				continue
+			}
+			end := closingQuote(text[i:])
+			if end < 0 {
+				return "", fmt.Errorf("unterminated quoted string")
+			}
+			i += end
+		case '#':
+			if i == 0 || text[i-1] == ' ' {
+				return strings.TrimRight(text[:i], " "), nil
+			}
+		}
+	}
+	return text, nil
+}
@@ -0,0 +1,89 @@
+package factory
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// YAML TESTS
+// ============================================================================
+
+func TestParseYAML_Shapes(t *testing.T) {
+	doc := `---
+plain: hello world
+quoted: "tab\there"   # comment
+single: 'it''s'
+url: https://example.com/a#b
+number: 42
+float: 1.5
+yes: true
+nothing: ~
+empty:
+"quoted key": x
+flow: [a, "b, c", 3]
+none: []
+list:
+- one
+- two
+items:
+  - name: first
+    tags: [x]
+  - name: second
+  -
+    - nested
+`
+	got, err := parseYAML([]byte(doc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]any{
+		"plain":      "hello world",
+		"quoted":     "tab\there",
+		"single":     "it's",
+		"url":        "https://example.com/a#b",
+		"number":     json.Number("42"),
+		"float":      json.Number("1.5"),
+		"yes":        true,
+		"nothing":    nil,
+		"empty":      nil,
+		"quoted key": "x",
+		"flow":       []any{"a", "b, c", json.Number("3")},
+		"none":       []any{},
+		"list":       []any{"one", "two"},
+		"items": []any{
+			map[string]any{"name": "first", "tags": []any{"x"}},
+			map[string]any{"name": "second"},
+			[]any{"nested"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %#v, got %#v", want, got)
+	}
+}
+
+// ==================== NEGATIVE TEST CASES ====================
+
+func TestParseYAML_Unsupported(t *testing.T) {
+	testCases := map[string]string{
+		"anchor: &a x\n":        "anchors",
+		"a: {b: c}\n":           "flow mappings",
+		"a: 1\n---\nb: 2\n":     "multiple documents",
+		"a: \"open\n":           "unterminated",
+		"  a: 1\n":              "must not be indented",
+		"a:\n  - x\n  b: y\n":   "line 3: unexpected indentation",
+		"just a scalar line\n":  "expected 'key: value'",
+		"a:\n  b: 1\n - c\n":    "line 3",
+		"list:\n- a\n  - b\n":   "unexpected indentation",
+		"a: [x, [y]]\n":         "unsupported flow sequence item",
+		"'a' b: 1\n":            "expected ':'",
+		"a: \"bad \\q escape\"": "malformed quoted string",
+	}
+	for doc, want := range testCases {
+		if _, err := parseYAML([]byte(doc)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected error containing %q, got %v", doc, want, err)
+		}
+	}
+}